@@ -0,0 +1,62 @@
+package ebpf
+
+import (
+	"errors"
+	"fmt"
+)
+
+// BloomFilterMap is a set of values which may return false positives.
+//
+// A bloom filter has no keys, values can only be added but not removed.
+// Contains never misses a value which was added. It may however report
+// values which were never added, with a probability that increases with
+// the number of values relative to MaxEntries, since the kernel sizes
+// the filter according to MaxEntries.
+//
+// Iterating the map returns an error, since it has no keys.
+//
+// Requires at least Linux 5.16.
+type BloomFilterMap struct {
+	m *Map
+}
+
+// NewBloomFilterMap wraps a Map of type BloomFilter.
+//
+// The map isn't copied, closing it invalidates the BloomFilterMap.
+func NewBloomFilterMap(m *Map) (*BloomFilterMap, error) {
+	if m.Type() != BloomFilter {
+		return nil, fmt.Errorf("%s is not a bloom filter", m)
+	}
+	return &BloomFilterMap{m}, nil
+}
+
+// Map returns the underlying Map.
+func (bf *BloomFilterMap) Map() *Map {
+	return bf.m
+}
+
+// Add inserts a value into the filter.
+func (bf *BloomFilterMap) Add(value interface{}) error {
+	return bf.m.Update(nil, value, UpdateAny)
+}
+
+// Contains tests whether a value may have been added to the filter.
+//
+// Returns false if the value definitely wasn't added. A return value of
+// true may be a false positive.
+func (bf *BloomFilterMap) Contains(value interface{}) (bool, error) {
+	// The kernel reads the value to test from the value buffer.
+	valuePtr, err := bf.m.marshalValue(value)
+	if err != nil {
+		return false, fmt.Errorf("can't marshal value: %w", err)
+	}
+
+	err = bf.m.lookup(nil, valuePtr)
+	if errors.Is(err, ErrKeyNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
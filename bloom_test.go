@@ -0,0 +1,57 @@
+package ebpf
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf/internal/testutils"
+)
+
+func TestBloomFilterMap(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.16", "bloom filter map")
+
+	m, err := NewMap(&MapSpec{
+		Type:       BloomFilter,
+		ValueSize:  4,
+		MaxEntries: 16,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	bf, err := NewBloomFilterMap(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bf.Add(uint32(42)); err != nil {
+		t.Fatal("Can't add value:", err)
+	}
+
+	if ok, err := bf.Contains(uint32(42)); err != nil {
+		t.Fatal("Can't test value:", err)
+	} else if !ok {
+		t.Error("Expected filter to contain 42")
+	}
+
+	if ok, err := bf.Contains(uint32(23)); err != nil {
+		t.Fatal("Can't test value:", err)
+	} else if ok {
+		t.Error("Expected filter to not contain 23")
+	}
+
+	var value uint32
+	entries := m.Iterate()
+	if entries.Next(nil, &value) {
+		t.Error("Next returns true for a bloom filter")
+	}
+	if entries.Err() == nil {
+		t.Error("Iterating a bloom filter doesn't return an error")
+	}
+
+	hash := createHash()
+	defer hash.Close()
+	if _, err := NewBloomFilterMap(hash); err == nil {
+		t.Error("NewBloomFilterMap accepts a hash map")
+	}
+}
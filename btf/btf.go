@@ -360,6 +360,25 @@ func fixupDatasec(rawTypes []rawType, rawStrings stringTable, sectionSizes map[s
 	return nil
 }
 
+// WriteTo implements io.WriterTo.
+//
+// It writes the type and string sections in the BTF wire format, using
+// the byte order the spec was loaded with. Extended information like
+// func and line infos is not included.
+//
+// The output is re-emitted from the raw BTF the spec was loaded from,
+// not encoded from its Types. This is equivalent since a Spec can't be
+// modified: FindType, TypeByName and TypeByID all return copies.
+func (s *Spec) WriteTo(w io.Writer) (int64, error) {
+	buf, err := s.marshal(marshalOpts{ByteOrder: s.byteOrder})
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := w.Write(buf)
+	return int64(n), err
+}
+
 type marshalOpts struct {
 	ByteOrder        binary.ByteOrder
 	StripFuncLinkage bool
@@ -491,7 +510,7 @@ func (s *Spec) FindType(name string, typ Type) error {
 	}
 
 	if candidate == nil {
-		return fmt.Errorf("type %s: %w", name, ErrNotFound)
+		return &TypeNotFoundError{Name: name}
 	}
 
 	cpy, _ := copyType(candidate, nil)
@@ -500,6 +519,72 @@ func (s *Spec) FindType(name string, typ Type) error {
 	return nil
 }
 
+// TypeByName returns a copy of the type with the given name, regardless
+// of its kind.
+//
+// Returns a *TypeNotFoundError if no type with the name exists in spec,
+// or an error if there are multiple types with the name.
+func (s *Spec) TypeByName(name string) (Type, error) {
+	var candidate Type
+	for _, typ := range s.namedTypes[essentialName(name)] {
+		if typ.name() != name {
+			continue
+		}
+
+		if candidate != nil {
+			return nil, fmt.Errorf("type %s: multiple candidates", name)
+		}
+
+		candidate = typ
+	}
+
+	if candidate == nil {
+		return nil, &TypeNotFoundError{Name: name}
+	}
+
+	cpy, _ := copyType(candidate, nil)
+	return cpy, nil
+}
+
+// TypeByID returns a copy of the type with the given ID.
+//
+// Returns a *TypeNotFoundError if spec doesn't contain the ID.
+func (s *Spec) TypeByID(id TypeID) (Type, error) {
+	if int(id) >= len(s.types) {
+		return nil, &TypeNotFoundError{ID: id}
+	}
+
+	if id == 0 {
+		return &Void{}, nil
+	}
+
+	cpy, _ := copyType(s.types[id], nil)
+	return cpy, nil
+}
+
+// TypeNotFoundError is returned when a Spec doesn't contain a type.
+//
+// It wraps ErrNotFound. Use errors.As to tell a missing type apart from
+// missing BTF, which is reported as ErrNotFound on its own.
+type TypeNotFoundError struct {
+	// Name of the type, empty if it was looked up by ID.
+	Name string
+	// ID of the type, zero if it was looked up by name.
+	ID TypeID
+}
+
+func (e *TypeNotFoundError) Error() string {
+	if e.Name != "" {
+		return fmt.Sprintf("type %s: %s", e.Name, ErrNotFound)
+	}
+	return fmt.Sprintf("type ID %d: %s", e.ID, ErrNotFound)
+}
+
+// Unwrap returns ErrNotFound.
+func (e *TypeNotFoundError) Unwrap() error {
+	return ErrNotFound
+}
+
 // Handle is a reference to BTF loaded into the kernel.
 type Handle struct {
 	fd *internal.FD
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"os"
 	"testing"
 
@@ -14,30 +15,38 @@ import (
 	"github.com/cilium/ebpf/internal/testutils"
 )
 
-func TestParseVmlinux(t *testing.T) {
+func vmlinuxTestdataSpec(tb testing.TB) *Spec {
+	tb.Helper()
+
 	fh, err := os.Open("testdata/vmlinux-btf.gz")
 	if err != nil {
-		t.Fatal(err)
+		tb.Fatal(err)
 	}
 	defer fh.Close()
 
 	rd, err := gzip.NewReader(fh)
 	if err != nil {
-		t.Fatal(err)
+		tb.Fatal(err)
 	}
 
 	buf, err := ioutil.ReadAll(rd)
 	if err != nil {
-		t.Fatal(err)
+		tb.Fatal(err)
 	}
 
 	spec, err := loadNakedSpec(bytes.NewReader(buf), binary.LittleEndian, nil, nil)
 	if err != nil {
-		t.Fatal("Can't load BTF:", err)
+		tb.Fatal("Can't load BTF:", err)
 	}
 
+	return spec
+}
+
+func TestParseVmlinux(t *testing.T) {
+	spec := vmlinuxTestdataSpec(t)
+
 	var iphdr Struct
-	err = spec.FindType("iphdr", &iphdr)
+	err := spec.FindType("iphdr", &iphdr)
 	if err != nil {
 		t.Fatalf("unable to find `iphdr` struct: %s", err)
 	}
@@ -66,6 +75,84 @@ func TestParseVmlinux(t *testing.T) {
 	}
 }
 
+func TestTypeByName(t *testing.T) {
+	spec := vmlinuxTestdataSpec(t)
+
+	typ, err := spec.TypeByName("iphdr")
+	if err != nil {
+		t.Fatal("Can't find iphdr:", err)
+	}
+
+	iphdr, ok := typ.(*Struct)
+	if !ok {
+		t.Fatalf("Expected iphdr to be a *Struct, got %T", typ)
+	}
+
+	typ, err = spec.TypeByID(iphdr.ID())
+	if err != nil {
+		t.Fatal("Can't find iphdr by ID:", err)
+	}
+	if byID, ok := typ.(*Struct); !ok || byID.Name != "iphdr" {
+		t.Error("TypeByID returns", typ)
+	}
+
+	if typ, err := spec.TypeByID(0); err != nil {
+		t.Error("Can't get Void:", err)
+	} else if _, ok := typ.(*Void); !ok {
+		t.Errorf("Expected type ID 0 to be Void, got %T", typ)
+	}
+
+	var notFound *TypeNotFoundError
+	if _, err := spec.TypeByName("totally_bogus_type"); !errors.As(err, &notFound) {
+		t.Error("TypeByName doesn't return TypeNotFoundError:", err)
+	} else if notFound.Name != "totally_bogus_type" {
+		t.Error("TypeNotFoundError has wrong name:", notFound.Name)
+	}
+
+	if _, err := spec.TypeByID(math.MaxUint32); !errors.As(err, &notFound) {
+		t.Error("TypeByID doesn't return TypeNotFoundError:", err)
+	}
+	if !errors.Is(notFound, ErrNotFound) {
+		t.Error("TypeNotFoundError doesn't wrap ErrNotFound")
+	}
+}
+
+func TestSpecWriteTo(t *testing.T) {
+	spec := vmlinuxTestdataSpec(t)
+
+	var buf bytes.Buffer
+	n, err := spec.WriteTo(&buf)
+	if err != nil {
+		t.Fatal("Can't write BTF:", err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("WriteTo returns %d, but wrote %d bytes", n, buf.Len())
+	}
+
+	written := buf.Bytes()
+	decoded, err := loadNakedSpec(bytes.NewReader(written), binary.LittleEndian, nil, nil)
+	if err != nil {
+		t.Fatal("Can't load written BTF:", err)
+	}
+
+	if len(decoded.types) != len(spec.types) {
+		t.Errorf("Expected %d types, got %d", len(spec.types), len(decoded.types))
+	}
+
+	var iphdr Struct
+	if err := decoded.FindType("iphdr", &iphdr); err != nil {
+		t.Error("Can't find iphdr in written BTF:", err)
+	}
+
+	buf.Reset()
+	if _, err := decoded.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), written) {
+		t.Error("BTF doesn't round trip")
+	}
+}
+
 func TestParseCurrentKernelBTF(t *testing.T) {
 	spec, err := loadKernelSpec()
 	testutils.SkipIfNotSupported(t, err)
@@ -79,7 +166,7 @@ func TestParseCurrentKernelBTF(t *testing.T) {
 }
 
 func TestLoadSpecFromElf(t *testing.T) {
-	testutils.Files(t, testutils.Glob(t, "../../testdata/loader-e*.elf"), func(t *testing.T, file string) {
+	testutils.Files(t, testutils.Glob(t, "../testdata/loader-e*.elf"), func(t *testing.T, file string) {
 		fh, err := os.Open(file)
 		if err != nil {
 			t.Fatal(err)
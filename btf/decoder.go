@@ -0,0 +1,266 @@
+package btf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/cilium/ebpf/internal"
+)
+
+// Decoder converts raw memory into Go values, using BTF to interpret it.
+//
+// It allows inspecting map keys and values without knowing their layout
+// ahead of time. The zero value decodes using the native byte order.
+type Decoder struct {
+	// ByteOrder of the raw memory. Defaults to the native endianness.
+	ByteOrder binary.ByteOrder
+}
+
+// Decode interprets buf as an instance of typ.
+//
+// Structs and unions decode to map[string]interface{} keyed by member
+// name, with the members of anonymous structs and unions merged into
+// the parent. Arrays decode to []interface{}. Signed integers decode to
+// int64, unsigned integers and pointers to uint64, booleans to bool and
+// floats to float32 or float64. Enums decode to the name of the matching
+// value. If there is none they decode like an integer of the same size and
+// signedness.
+//
+// Returns an error if buf is too short for typ, or if typ is unsized.
+func (d *Decoder) Decode(typ Type, buf []byte) (interface{}, error) {
+	bo := d.ByteOrder
+	if bo == nil {
+		bo = internal.NativeEndian
+	}
+
+	return decodeType(bo, typ, buf, 0)
+}
+
+func decodeType(bo binary.ByteOrder, typ Type, buf []byte, depth int) (interface{}, error) {
+	if depth > maxTypeDepth {
+		return nil, fmt.Errorf("type %s: exceeded type depth", typ)
+	}
+
+	typ, err := skipQualifiersAndTypedefs(typ)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := Sizeof(typ)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(buf) < size {
+		return nil, fmt.Errorf("type %s: need %d bytes, have %d", typ, size, len(buf))
+	}
+	buf = buf[:size]
+
+	switch v := typ.(type) {
+	case *Int:
+		return decodeInt(bo, v, buf)
+
+	case *Float:
+		switch v.Size {
+		case 4:
+			return math.Float32frombits(bo.Uint32(buf)), nil
+		case 8:
+			return math.Float64frombits(bo.Uint64(buf)), nil
+		}
+		return nil, fmt.Errorf("float of %d bytes: %w", v.Size, ErrNotSupported)
+
+	case *Pointer:
+		return bo.Uint64(buf), nil
+
+	case *Enum:
+		value, err := decodeInt(bo, v.intType(), buf)
+		if err != nil {
+			return nil, fmt.Errorf("enum %s: %w", v.Name, err)
+		}
+
+		for _, ev := range v.Values {
+			// Values are stored as 32 bits in BTF, regardless of the
+			// size of the enum.
+			var match bool
+			switch value := value.(type) {
+			case int64:
+				match = value == int64(ev.Value)
+			case uint64:
+				match = value == uint64(uint32(ev.Value))
+			}
+			if match {
+				return string(ev.Name), nil
+			}
+		}
+		return value, nil
+
+	case *Array:
+		elemSize, err := Sizeof(v.Type)
+		if err != nil {
+			return nil, err
+		}
+
+		elems := make([]interface{}, 0, v.Nelems)
+		for i := 0; i < int(v.Nelems); i++ {
+			elem, err := decodeType(bo, v.Type, buf[i*elemSize:], depth+1)
+			if err != nil {
+				return nil, fmt.Errorf("index %d: %w", i, err)
+			}
+			elems = append(elems, elem)
+		}
+		return elems, nil
+
+	case composite:
+		fields := make(map[string]interface{})
+		if err := decodeMembers(bo, v.members(), buf, fields, depth); err != nil {
+			return nil, fmt.Errorf("type %s: %w", typ, err)
+		}
+		return fields, nil
+
+	default:
+		return nil, fmt.Errorf("can't decode %s: %w", typ, ErrNotSupported)
+	}
+}
+
+func decodeMembers(bo binary.ByteOrder, members []Member, buf []byte, fields map[string]interface{}, depth int) error {
+	for _, member := range members {
+		var (
+			value interface{}
+			err   error
+		)
+
+		if member.BitfieldSize > 0 {
+			value, err = decodeBitfield(bo, member, buf)
+		} else {
+			if member.Offset%8 != 0 {
+				return fmt.Errorf("member %s: offset %d isn't byte aligned", member.Name, member.Offset)
+			}
+
+			offset := int(member.Offset / 8)
+			if offset > len(buf) {
+				return fmt.Errorf("member %s: offset %d is out of bounds", member.Name, offset)
+			}
+
+			value, err = decodeType(bo, member.Type, buf[offset:], depth+1)
+		}
+		if err != nil {
+			return fmt.Errorf("member %s: %w", member.Name, err)
+		}
+
+		if member.Name != "" {
+			fields[string(member.Name)] = value
+			continue
+		}
+
+		// Merge the members of anonymous structs and unions.
+		if nested, ok := value.(map[string]interface{}); ok {
+			for name, value := range nested {
+				fields[name] = value
+			}
+		}
+	}
+
+	return nil
+}
+
+func decodeInt(bo binary.ByteOrder, i *Int, buf []byte) (interface{}, error) {
+	var value uint64
+	switch i.Size {
+	case 1:
+		value = uint64(buf[0])
+	case 2:
+		value = uint64(bo.Uint16(buf))
+	case 4:
+		value = uint64(bo.Uint32(buf))
+	case 8:
+		value = bo.Uint64(buf)
+	default:
+		return nil, fmt.Errorf("integer of %d bytes: %w", i.Size, ErrNotSupported)
+	}
+
+	return intValue(i, value, i.Size*8), nil
+}
+
+// decodeBitfield extracts a member which doesn't occupy whole bytes.
+func decodeBitfield(bo binary.ByteOrder, member Member, buf []byte) (interface{}, error) {
+	typ, err := skipQualifiersAndTypedefs(member.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	var i *Int
+	switch v := typ.(type) {
+	case *Int:
+		i = v
+	case *Enum:
+		i = v.intType()
+	default:
+		return nil, fmt.Errorf("bitfield of type %s: %w", typ, ErrNotSupported)
+	}
+
+	bits := member.BitfieldSize
+	if bits > 64 {
+		return nil, fmt.Errorf("bitfield of %d bits: %w", bits, ErrNotSupported)
+	}
+
+	start := member.Offset / 8
+	end := (member.Offset + bits + 7) / 8
+	if int(end) > len(buf) || end-start > 8 {
+		return nil, fmt.Errorf("bitfield at bit %d is out of bounds", member.Offset)
+	}
+
+	// Load the bytes spanning the bitfield into an integer.
+	var (
+		raw   uint64
+		chunk = buf[start:end]
+		shift = member.Offset % 8
+	)
+	if bo == binary.LittleEndian {
+		for j := len(chunk) - 1; j >= 0; j-- {
+			raw = raw<<8 | uint64(chunk[j])
+		}
+	} else {
+		for _, b := range chunk {
+			raw = raw<<8 | uint64(b)
+		}
+		// Bits are numbered starting from the most significant one.
+		shift = uint32(len(chunk))*8 - shift - bits
+	}
+
+	value := raw >> shift
+	if bits < 64 {
+		value &= 1<<bits - 1
+	}
+
+	return intValue(i, value, bits), nil
+}
+
+// intValue converts the lowest bits of value according to the encoding of i.
+func intValue(i *Int, value uint64, bits uint32) interface{} {
+	switch {
+	case i.Encoding&Bool != 0:
+		return value != 0
+	case i.Encoding&Signed != 0:
+		// Sign extend.
+		shift := 64 - bits
+		return int64(value<<shift) >> shift
+	default:
+		return value
+	}
+}
+
+func skipQualifiersAndTypedefs(typ Type) (Type, error) {
+	for i := 0; i < maxTypeDepth; i++ {
+		switch v := typ.(type) {
+		case *Typedef:
+			typ = v.Type
+		case qualifier:
+			typ = v.qualify()
+		default:
+			return typ, nil
+		}
+	}
+
+	return nil, fmt.Errorf("type %s: exceeded type depth", typ)
+}
@@ -0,0 +1,128 @@
+package btf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestDecoder(t *testing.T) {
+	type value struct {
+		A    uint32
+		B    int16
+		C    bool
+		_    [1]byte
+		D    float64
+		Keys [2]int8
+	}
+
+	var enc Encoder
+	typ, err := enc.Encode(value{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, bo := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		var buf bytes.Buffer
+		in := value{A: 42, B: -2, C: true, D: 1.5, Keys: [2]int8{-1, 1}}
+		if err := binary.Write(&buf, bo, in); err != nil {
+			t.Fatal(err)
+		}
+		// Go adds trailing padding to the struct which binary.Write omits.
+		buf.Write(make([]byte, 6))
+
+		dec := Decoder{ByteOrder: bo}
+		out, err := dec.Decode(typ, buf.Bytes())
+		if err != nil {
+			t.Fatal(bo, err)
+		}
+
+		qt.Assert(t, out, qt.DeepEquals, map[string]interface{}{
+			"A":    uint64(42),
+			"B":    int64(-2),
+			"C":    true,
+			"D":    float64(1.5),
+			"Keys": []interface{}{int64(-1), int64(1)},
+		})
+
+		if _, err := dec.Decode(typ, buf.Bytes()[:4]); err == nil {
+			t.Error(bo, "Decode accepts a short buffer")
+		}
+	}
+}
+
+func TestDecoderBitfieldsAndEnums(t *testing.T) {
+	u8 := &Int{Name: "u8", Size: 1}
+	s32 := &Int{Name: "s32", Size: 4, Encoding: Signed}
+	enum := &Enum{Name: "e", Values: []EnumValue{{Name: "ONE", Value: 1}}}
+
+	typ := &Struct{Name: "s", Size: 8, Members: []Member{
+		{Name: "lo", Type: u8, Offset: 0, BitfieldSize: 3},
+		{Name: "hi", Type: s32, Offset: 3, BitfieldSize: 5},
+		{Type: &Union{Size: 4, Members: []Member{
+			{Name: "e", Type: enum},
+		}}, Offset: 32},
+	}}
+
+	var dec Decoder
+	bo := binary.LittleEndian
+	dec.ByteOrder = bo
+
+	// lo = 5, hi = -1 (0b11111)
+	buf := []byte{0xfd, 0, 0, 0, 1, 0, 0, 0}
+	out, err := dec.Decode(typ, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	qt.Assert(t, out, qt.DeepEquals, map[string]interface{}{
+		"lo": uint64(5),
+		"hi": int64(-1),
+		"e":  "ONE",
+	})
+
+	bo.PutUint32(buf[4:], 2)
+	out, err = dec.Decode(typ, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := out.(map[string]interface{})["e"]; e != uint64(2) {
+		t.Errorf("Expected unknown enum value to decode to uint64(2), got %T(%v)", e, e)
+	}
+}
+
+func TestDecoderEnumSizes(t *testing.T) {
+	var dec Decoder
+	dec.ByteOrder = binary.LittleEndian
+
+	for _, test := range []struct {
+		name string
+		enum *Enum
+		buf  []byte
+		want interface{}
+	}{
+		{"u8", &Enum{Size: 1}, []byte{0xff}, uint64(0xff)},
+		{"u8 match", &Enum{Size: 1, Values: []EnumValue{{"MAX", 0xff}}}, []byte{0xff}, "MAX"},
+		{"u32 match", &Enum{Values: []EnumValue{{"MAX", -1}}}, []byte{0xff, 0xff, 0xff, 0xff}, "MAX"},
+		{"s64", &Enum{Size: 8, Signed: true}, []byte{0xfe, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, int64(-2)},
+		{"s64 match", &Enum{Size: 8, Signed: true, Values: []EnumValue{{"MINUS_ONE", -1}}},
+			[]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, "MINUS_ONE"},
+		{"u64", &Enum{Size: 8, Values: []EnumValue{{"MAX", -1}}},
+			[]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, uint64(math.MaxUint64)},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			out, err := dec.Decode(test.enum, test.buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			qt.Assert(t, out, qt.Equals, test.want)
+		})
+	}
+
+	if _, err := dec.Decode(&Enum{Size: 8}, make([]byte, 4)); err == nil {
+		t.Error("Decode accepts a short buffer for an 8 byte enum")
+	}
+}
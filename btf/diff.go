@@ -0,0 +1,223 @@
+package btf
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// ChangeKind is the kind of a Change between two Specs.
+type ChangeKind int
+
+const (
+	// TypeAdded is a type which only exists in the other Spec.
+	TypeAdded ChangeKind = iota + 1
+	// TypeRemoved is a type which only exists in the original Spec.
+	TypeRemoved
+	// TypeSizeChanged is a type which has a different size.
+	TypeSizeChanged
+	// FieldAdded is a member which only exists in the other Spec.
+	FieldAdded
+	// FieldRemoved is a member which only exists in the original Spec.
+	FieldRemoved
+	// FieldRenamed is a member which has a different name at the same
+	// offset.
+	FieldRenamed
+	// FieldMoved is a member which has a different offset.
+	FieldMoved
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case TypeAdded:
+		return "type added"
+	case TypeRemoved:
+		return "type removed"
+	case TypeSizeChanged:
+		return "size changed"
+	case FieldAdded:
+		return "field added"
+	case FieldRemoved:
+		return "field removed"
+	case FieldRenamed:
+		return "field renamed"
+	case FieldMoved:
+		return "field moved"
+	default:
+		return fmt.Sprintf("ChangeKind(%d)", int(k))
+	}
+}
+
+// Change describes how a named type differs between two Specs.
+type Change struct {
+	Kind ChangeKind
+	// Old is the type in the original Spec, nil for TypeAdded.
+	Old Type
+	// New is the type in the other Spec, nil for TypeRemoved.
+	New Type
+	// Field is the name of the affected member of a Struct or Union.
+	// For FieldRenamed it is the name in the original Spec.
+	Field string
+	// NewField is the name of the member in the other Spec. Only set for
+	// FieldRenamed.
+	NewField string
+}
+
+func (c Change) String() string {
+	typ := c.Old
+	if typ == nil {
+		typ = c.New
+	}
+
+	switch c.Kind {
+	case TypeSizeChanged:
+		return fmt.Sprintf("%s: %s from %d to %d bytes", typ, c.Kind, c.Old.(sizer).size(), c.New.(sizer).size())
+	case FieldAdded, FieldRemoved, FieldMoved:
+		return fmt.Sprintf("%s: %s %s", typ, c.Kind, c.Field)
+	case FieldRenamed:
+		return fmt.Sprintf("%s: %s %s to %s", typ, c.Kind, c.Field, c.NewField)
+	default:
+		return fmt.Sprintf("%s: %s", typ, c.Kind)
+	}
+}
+
+// Diff returns the changes to named types which turn s into other.
+//
+// Types are matched by kind and name. If a Spec contains multiple types
+// of the same kind and name only the first one is compared. Changes to
+// anonymous types are only reported via the named types that contain them.
+//
+// The types in the returned changes are shared with the Specs and must not
+// be modified.
+func (s *Spec) Diff(other *Spec) ([]Change, error) {
+	if other == nil {
+		return nil, fmt.Errorf("diff: other spec is nil")
+	}
+
+	oldTypes, oldKeys := s.typesByKindAndName()
+	newTypes, newKeys := other.typesByKindAndName()
+
+	var changes []Change
+	for _, key := range oldKeys {
+		oldType := oldTypes[key]
+		newType, ok := newTypes[key]
+		if !ok {
+			changes = append(changes, Change{Kind: TypeRemoved, Old: oldType})
+			continue
+		}
+
+		changes = append(changes, diffType(oldType, newType)...)
+	}
+
+	for _, key := range newKeys {
+		if _, ok := oldTypes[key]; !ok {
+			changes = append(changes, Change{Kind: TypeAdded, New: newTypes[key]})
+		}
+	}
+
+	return changes, nil
+}
+
+type kindAndName struct {
+	kind reflect.Type
+	name string
+}
+
+// typesByKindAndName returns the named types of the spec and their keys,
+// in order of type ID.
+func (s *Spec) typesByKindAndName() (map[kindAndName]namedType, []kindAndName) {
+	var (
+		types = make(map[kindAndName]namedType)
+		keys  []kindAndName
+	)
+
+	for _, typ := range s.types {
+		named, ok := typ.(namedType)
+		if !ok || named.name() == "" {
+			continue
+		}
+
+		key := kindAndName{reflect.TypeOf(typ), named.name()}
+		if _, ok := types[key]; ok {
+			continue
+		}
+
+		types[key] = named
+		keys = append(keys, key)
+	}
+
+	return types, keys
+}
+
+func diffType(oldType, newType Type) []Change {
+	var changes []Change
+
+	if oldSizer, ok := oldType.(sizer); ok {
+		if oldSizer.size() != newType.(sizer).size() {
+			changes = append(changes, Change{Kind: TypeSizeChanged, Old: oldType, New: newType})
+		}
+	}
+
+	oldComposite, ok := oldType.(composite)
+	if !ok {
+		return changes
+	}
+
+	var (
+		oldMembers = membersByName(oldComposite.members())
+		newMembers = membersByName(newType.(composite).members())
+		removed    []Member
+	)
+
+	for _, member := range oldComposite.members() {
+		if member.Name == "" {
+			continue
+		}
+
+		newMember, ok := newMembers[string(member.Name)]
+		if !ok {
+			removed = append(removed, member)
+			continue
+		}
+
+		if newMember.Offset != member.Offset {
+			changes = append(changes, Change{Kind: FieldMoved, Old: oldType, New: newType, Field: string(member.Name)})
+		}
+	}
+
+	for _, member := range newType.(composite).members() {
+		if _, ok := oldMembers[string(member.Name)]; ok || member.Name == "" {
+			continue
+		}
+
+		// A removed member at the same offset is assumed to be renamed.
+		renamed := false
+		for i, oldMember := range removed {
+			if oldMember.Offset == member.Offset {
+				removed = append(removed[:i], removed[i+1:]...)
+				changes = append(changes, Change{Kind: FieldRenamed, Old: oldType, New: newType, Field: string(oldMember.Name), NewField: string(member.Name)})
+				renamed = true
+				break
+			}
+		}
+
+		if !renamed {
+			changes = append(changes, Change{Kind: FieldAdded, Old: oldType, New: newType, Field: string(member.Name)})
+		}
+	}
+
+	for _, member := range removed {
+		changes = append(changes, Change{Kind: FieldRemoved, Old: oldType, New: newType, Field: string(member.Name)})
+	}
+
+	return changes
+}
+
+func membersByName(members []Member) map[string]Member {
+	byName := make(map[string]Member, len(members))
+	for _, member := range members {
+		if member.Name != "" {
+			byName[string(member.Name)] = member
+		}
+	}
+	return byName
+}
@@ -0,0 +1,153 @@
+package btf
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestSpecDiff(t *testing.T) {
+	u32 := &Int{TypeID: 1, Name: "u32", Size: 4}
+	u64 := &Int{TypeID: 2, Name: "u64", Size: 8}
+
+	oldSpec := &Spec{types: []Type{
+		(*Void)(nil),
+		u32,
+		u64,
+		&Struct{TypeID: 3, Name: "foo", Size: 16, Members: []Member{
+			{Name: "a", Type: u32, Offset: 0},
+			{Name: "b", Type: u32, Offset: 32},
+			{Name: "c", Type: u64, Offset: 64},
+		}},
+		&Struct{TypeID: 4, Name: "gone", Size: 4},
+		&Struct{TypeID: 5, Name: "same", Size: 4, Members: []Member{
+			{Name: "a", Type: u32},
+		}},
+	}}
+
+	newSpec := &Spec{types: []Type{
+		(*Void)(nil),
+		u32,
+		u64,
+		&Struct{TypeID: 3, Name: "foo", Size: 24, Members: []Member{
+			{Name: "a", Type: u32, Offset: 0},
+			{Name: "renamed", Type: u32, Offset: 32},
+			{Name: "added", Type: u64, Offset: 64},
+			{Name: "c", Type: u64, Offset: 128},
+		}},
+		&Struct{TypeID: 4, Name: "same", Size: 4, Members: []Member{
+			{Name: "a", Type: u32},
+		}},
+		// Same name, different kind.
+		&Union{TypeID: 5, Name: "gone", Size: 4},
+	}}
+
+	changes, err := oldSpec.Diff(newSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type result struct {
+		kind            ChangeKind
+		name            string
+		field, newField string
+	}
+
+	want := []result{
+		{TypeSizeChanged, "foo", "", ""},
+		{FieldMoved, "foo", "c", ""},
+		{FieldRenamed, "foo", "b", "renamed"},
+		{FieldAdded, "foo", "added", ""},
+		{TypeRemoved, "gone", "", ""},
+		{TypeAdded, "gone", "", ""},
+	}
+
+	if len(changes) != len(want) {
+		t.Fatalf("Expected %d changes, got %d: %v", len(want), len(changes), changes)
+	}
+
+	for i, change := range changes {
+		typ := change.Old
+		if typ == nil {
+			typ = change.New
+		}
+
+		got := result{change.Kind, typ.(namedType).name(), change.Field, change.NewField}
+		if got != want[i] {
+			t.Errorf("Change %d: expected %v, got %v (%s)", i, want[i], got, change)
+		}
+	}
+
+	if _, ok := changes[5].New.(*Union); !ok {
+		t.Errorf("Expected added type to be a union, got %s", changes[5].New)
+	}
+
+	changes, err = oldSpec.Diff(oldSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Error("Expected no changes when comparing a spec with itself, got", changes)
+	}
+}
+
+func TestSpecDiffRemovedField(t *testing.T) {
+	u32 := &Int{TypeID: 1, Name: "u32", Size: 4}
+
+	oldSpec := &Spec{types: []Type{
+		(*Void)(nil),
+		u32,
+		&Union{TypeID: 2, Name: "bar", Size: 4, Members: []Member{
+			{Name: "a", Type: u32},
+			{Name: "b", Type: u32},
+		}},
+	}}
+
+	newSpec := &Spec{types: []Type{
+		(*Void)(nil),
+		u32,
+		&Union{TypeID: 2, Name: "bar", Size: 4, Members: []Member{
+			{Name: "a", Type: u32},
+		}},
+	}}
+
+	changes, err := oldSpec.Diff(newSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(changes) != 1 || changes[0].Kind != FieldRemoved || changes[0].Field != "b" {
+		t.Error("Expected b to be removed, got", changes)
+	}
+}
+
+// Compare the types of two kernels, for example in CI before upgrading.
+func ExampleSpec_Diff() {
+	load := func(path string) *Spec {
+		f, err := os.Open(path)
+		if err != nil {
+			panic(err)
+		}
+		defer f.Close()
+
+		spec, err := LoadSpecFromReader(f)
+		if err != nil {
+			panic(err)
+		}
+		return spec
+	}
+
+	oldSpec := load("/usr/lib/debug/boot/vmlinux-5.10")
+	newSpec := load("/usr/lib/debug/boot/vmlinux-5.15")
+
+	changes, err := oldSpec.Diff(newSpec)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, change := range changes {
+		if change.Kind == FieldMoved || change.Kind == FieldRemoved {
+			fmt.Println(change)
+		}
+	}
+}
@@ -3,6 +3,5 @@
 // The canonical documentation lives in the Linux kernel repository and is
 // available at https://www.kernel.org/doc/html/latest/bpf/btf.html
 //
-// The API is very much unstable. You should only use this via the main
-// ebpf library.
+// The API is not yet stable and may change between releases.
 package btf
@@ -0,0 +1,209 @@
+package btf
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Encoder converts Go types into BTF.
+//
+// Types are encoded with the layout BPF uses: pointers and uintptr are
+// 8 bytes wide, and struct members are aligned to their natural alignment
+// like in C. Structs whose in-memory layout on the current architecture
+// differs from that, for example because they contain pointers on a 32-bit
+// platform, can't be encoded since their values couldn't be copied into a
+// map verbatim. Encoding the same Go type repeatedly returns the same Type,
+// which makes it possible to encode recursive types.
+//
+// The zero value is ready to use.
+type Encoder struct {
+	types map[reflect.Type]Type
+}
+
+// Encode returns the BTF for the type of v.
+//
+// Booleans, integers, floats, arrays, structs and pointers are supported.
+// Members of a struct can be renamed with an `ebpf:"name"` tag, and
+// omitted with `ebpf:"-"`. Returns an error for other types like strings,
+// slices or maps, since they have no equivalent in C.
+func (enc *Encoder) Encode(v interface{}) (Type, error) {
+	if v == nil {
+		return nil, fmt.Errorf("can't encode nil")
+	}
+
+	return enc.encode(reflect.TypeOf(v))
+}
+
+func (enc *Encoder) encode(typ reflect.Type) (Type, error) {
+	if enc.types == nil {
+		enc.types = make(map[reflect.Type]Type)
+	}
+
+	if cached, ok := enc.types[typ]; ok {
+		return cached, nil
+	}
+
+	bpfSize, _, err := bpfLayout(typ)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		name   = Name(typ.Name())
+		size   = uint32(bpfSize)
+		result Type
+	)
+
+	switch typ.Kind() {
+	case reflect.Bool:
+		result = &Int{Name: name, Size: size, Encoding: Bool, Bits: byte(size * 8)}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		result = &Int{Name: name, Size: size, Encoding: Signed, Bits: byte(size * 8)}
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		// The size of uintptr is taken from bpfLayout.
+		result = &Int{Name: name, Size: size, Bits: byte(size * 8)}
+
+	case reflect.Float32, reflect.Float64:
+		result = &Float{Name: name, Size: size}
+
+	case reflect.Array:
+		elem, err := enc.encode(typ.Elem())
+		if err != nil {
+			return nil, fmt.Errorf("array of %s: %w", typ.Elem(), err)
+		}
+		result = &Array{Type: elem, Nelems: uint32(typ.Len())}
+
+	case reflect.Ptr:
+		// Cache the pointer before encoding its target, in case the
+		// target refers back to it.
+		ptr := &Pointer{}
+		enc.types[typ] = ptr
+
+		target, err := enc.encode(typ.Elem())
+		if err != nil {
+			delete(enc.types, typ)
+			return nil, fmt.Errorf("pointer to %s: %w", typ.Elem(), err)
+		}
+		ptr.Target = target
+		return ptr, nil
+
+	case reflect.Struct:
+		if uint64(typ.Size()) != bpfSize {
+			return nil, fmt.Errorf("struct %s: size is %d bytes instead of %d in BPF", typ, typ.Size(), bpfSize)
+		}
+
+		s := &Struct{Name: name, Size: size}
+		enc.types[typ] = s
+
+		members, err := enc.encodeMembers(typ)
+		if err != nil {
+			delete(enc.types, typ)
+			return nil, fmt.Errorf("struct %s: %w", typ, err)
+		}
+		s.Members = members
+		return s, nil
+
+	default:
+		return nil, fmt.Errorf("can't encode %s: %w", typ, ErrNotSupported)
+	}
+
+	enc.types[typ] = result
+	return result, nil
+}
+
+func (enc *Encoder) encodeMembers(typ reflect.Type) ([]Member, error) {
+	var (
+		members []Member
+		offset  uint64
+	)
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+
+		size, align, err := bpfLayout(field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.Name, err)
+		}
+
+		offset = alignUp(offset, align)
+		if uint64(field.Offset) != offset {
+			return nil, fmt.Errorf("field %s: offset is %d instead of %d in BPF", field.Name, field.Offset, offset)
+		}
+		fieldOffset := offset
+		offset += size
+
+		if field.Name == "_" {
+			// Padding.
+			continue
+		}
+
+		name := field.Name
+		if tag, ok := field.Tag.Lookup("ebpf"); ok {
+			if tag == "-" {
+				continue
+			}
+			name = tag
+		}
+
+		member, err := enc.encode(field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.Name, err)
+		}
+
+		members = append(members, Member{
+			Name:   Name(name),
+			Type:   member,
+			Offset: uint32(fieldOffset) * 8,
+		})
+	}
+
+	return members, nil
+}
+
+// bpfPointerSize is the size of a pointer in BPF, regardless of the host.
+const bpfPointerSize = 8
+
+// bpfLayout returns the size and alignment of typ in BPF.
+func bpfLayout(typ reflect.Type) (size, align uint64, err error) {
+	switch typ.Kind() {
+	case reflect.Ptr, reflect.Uintptr:
+		return bpfPointerSize, bpfPointerSize, nil
+
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return uint64(typ.Size()), uint64(typ.Size()), nil
+
+	case reflect.Array:
+		size, align, err := bpfLayout(typ.Elem())
+		if err != nil {
+			return 0, 0, err
+		}
+		return size * uint64(typ.Len()), align, nil
+
+	case reflect.Struct:
+		var offset uint64
+		align := uint64(1)
+		for i := 0; i < typ.NumField(); i++ {
+			fieldSize, fieldAlign, err := bpfLayout(typ.Field(i).Type)
+			if err != nil {
+				return 0, 0, err
+			}
+
+			offset = alignUp(offset, fieldAlign) + fieldSize
+			if fieldAlign > align {
+				align = fieldAlign
+			}
+		}
+		return alignUp(offset, align), align, nil
+
+	default:
+		return 0, 0, fmt.Errorf("can't encode %s: %w", typ, ErrNotSupported)
+	}
+}
+
+func alignUp(n, align uint64) uint64 {
+	return (n + align - 1) / align * align
+}
@@ -0,0 +1,153 @@
+package btf
+
+import (
+	"errors"
+	"testing"
+	"unsafe"
+)
+
+func TestEncoder(t *testing.T) {
+	if unsafe.Sizeof(uintptr(0)) != 8 {
+		t.Skip("Structs containing pointers can only be encoded on 64-bit platforms")
+	}
+
+	type node struct {
+		Next  *node `ebpf:"next"`
+		Value uint32
+		_     [4]byte
+		Ready bool
+		Score float64
+		Keys  [2]int16
+		Skip  uint64 `ebpf:"-"`
+	}
+
+	var enc Encoder
+	typ, err := enc.Encode(node{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, ok := typ.(*Struct)
+	if !ok {
+		t.Fatalf("Expected *Struct, got %T", typ)
+	}
+
+	if s.Name != "node" {
+		t.Error("Expected name node, got", s.Name)
+	}
+	if s.Size != 48 {
+		t.Error("Expected size 48, got", s.Size)
+	}
+
+	want := []struct {
+		name   string
+		offset uint32
+	}{
+		{"next", 0},
+		{"Value", 64},
+		{"Ready", 128},
+		{"Score", 192},
+		{"Keys", 256},
+	}
+
+	if len(s.Members) != len(want) {
+		t.Fatalf("Expected %d members, got %d", len(want), len(s.Members))
+	}
+
+	for i, member := range s.Members {
+		if string(member.Name) != want[i].name || member.Offset != want[i].offset {
+			t.Errorf("Member %d: expected %s at bit %d, got %s at bit %d", i, want[i].name, want[i].offset, member.Name, member.Offset)
+		}
+	}
+
+	ptr, ok := s.Members[0].Type.(*Pointer)
+	if !ok || ptr.Target != s {
+		t.Error("Pointer doesn't refer back to the struct:", s.Members[0].Type)
+	}
+
+	if i, ok := s.Members[1].Type.(*Int); !ok || i.Size != 4 || i.Encoding != 0 {
+		t.Error("Expected uint32, got", s.Members[1].Type)
+	}
+
+	if i, ok := s.Members[2].Type.(*Int); !ok || i.Encoding != Bool {
+		t.Error("Expected bool, got", s.Members[2].Type)
+	}
+
+	if f, ok := s.Members[3].Type.(*Float); !ok || f.Size != 8 {
+		t.Error("Expected float64, got", s.Members[3].Type)
+	}
+
+	arr, ok := s.Members[4].Type.(*Array)
+	if !ok || arr.Nelems != 2 {
+		t.Fatal("Expected array of two elements, got", s.Members[4].Type)
+	}
+	if i, ok := arr.Type.(*Int); !ok || i.Size != 2 || i.Encoding != Signed {
+		t.Error("Expected int16, got", arr.Type)
+	}
+
+	if size, err := Sizeof(s); err != nil || size != 48 {
+		t.Errorf("Sizeof returns %d, %v", size, err)
+	}
+
+	again, err := enc.Encode(&node{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.(*Pointer).Target != s {
+		t.Error("Encoding the same type twice returns a different Type")
+	}
+}
+
+func TestEncoderPointerSize(t *testing.T) {
+	var enc Encoder
+	typ, err := enc.Encode(uintptr(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i, ok := typ.(*Int); !ok || i.Size != 8 {
+		t.Error("Expected an 8 byte integer for uintptr, got", typ)
+	}
+
+	type withPointer struct {
+		A uint32
+		P *uint32
+	}
+
+	typ, err = enc.Encode(withPointer{})
+	if unsafe.Sizeof(uintptr(0)) != 8 {
+		// The Go layout of the struct doesn't match BPF.
+		if err == nil {
+			t.Error("Encoding a struct with a 4 byte pointer doesn't return an error")
+		}
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := typ.(*Struct)
+	if s.Size != 16 {
+		t.Error("Expected size 16, got", s.Size)
+	}
+	if s.Members[1].Offset != 64 {
+		t.Error("Expected pointer at bit 64, got", s.Members[1].Offset)
+	}
+}
+
+func TestEncoderUnsupported(t *testing.T) {
+	var enc Encoder
+	for _, v := range []interface{}{
+		"string",
+		[]byte{},
+		map[int]int{},
+		struct{ S string }{},
+	} {
+		if _, err := enc.Encode(v); !errors.Is(err, ErrNotSupported) {
+			t.Errorf("Encoding %T doesn't return ErrNotSupported: %v", v, err)
+		}
+	}
+
+	if _, err := enc.Encode(nil); err == nil {
+		t.Error("Encoding nil doesn't return an error")
+	}
+}
@@ -0,0 +1,250 @@
+package btf
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/cilium/ebpf/internal"
+)
+
+// arraySizeTypeName is the name of the integer used as the index type of
+// arrays, which Array doesn't carry. Same as libbpf.
+const arraySizeTypeName = "__ARRAY_SIZE_TYPE__"
+
+// NewMapFromTypes creates BTF which describes a map with the given
+// key and value types.
+//
+// The types and everything they refer to are encoded into a new Spec,
+// so they don't have to come from an existing one. Function types,
+// variables and data sections are not supported.
+func NewMapFromTypes(key, value Type) (*Map, error) {
+	enc := newTypeEncoder()
+
+	keyID, err := enc.add(key)
+	if err != nil {
+		return nil, fmt.Errorf("key: %w", err)
+	}
+
+	valueID, err := enc.add(value)
+	if err != nil {
+		return nil, fmt.Errorf("value: %w", err)
+	}
+
+	raw, err := enc.marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	spec, err := loadNakedSpec(bytes.NewReader(raw), internal.NativeEndian, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decode generated BTF: %w", err)
+	}
+
+	m := NewMap(spec, spec.types[keyID], spec.types[valueID])
+	return &m, nil
+}
+
+// typeEncoder turns a graph of Types into raw BTF.
+type typeEncoder struct {
+	ids       map[Type]TypeID
+	pending   []Type
+	rawTypes  []rawType
+	strings   bytes.Buffer
+	stringOff map[string]uint32
+	indexType TypeID
+}
+
+func newTypeEncoder() *typeEncoder {
+	enc := &typeEncoder{
+		ids:       make(map[Type]TypeID),
+		stringOff: make(map[string]uint32),
+	}
+	// The string at offset zero is always empty.
+	enc.strings.WriteByte(0)
+	enc.stringOff[""] = 0
+	return enc
+}
+
+// add assigns IDs to typ and all types reachable from it.
+func (enc *typeEncoder) add(typ Type) (TypeID, error) {
+	id := enc.id(typ)
+
+	for len(enc.pending) > 0 {
+		next := enc.pending[0]
+		enc.pending = enc.pending[1:]
+
+		raw, err := enc.encode(next)
+		if err != nil {
+			return 0, err
+		}
+
+		enc.rawTypes[enc.ids[next]-1] = raw
+	}
+
+	return id, nil
+}
+
+// id returns the ID of a type, allocating one if necessary.
+func (enc *typeEncoder) id(typ Type) TypeID {
+	if _, ok := typ.(*Void); ok || typ == nil {
+		return 0
+	}
+
+	if id, ok := enc.ids[typ]; ok {
+		return id
+	}
+
+	enc.rawTypes = append(enc.rawTypes, rawType{})
+	id := TypeID(len(enc.rawTypes))
+	enc.ids[typ] = id
+	enc.pending = append(enc.pending, typ)
+	return id
+}
+
+func (enc *typeEncoder) name(n Name) uint32 {
+	if off, ok := enc.stringOff[string(n)]; ok {
+		return off
+	}
+
+	off := uint32(enc.strings.Len())
+	enc.strings.WriteString(string(n))
+	enc.strings.WriteByte(0)
+	enc.stringOff[string(n)] = off
+	return off
+}
+
+func (enc *typeEncoder) arrayIndexType() TypeID {
+	if enc.indexType == 0 {
+		enc.indexType = enc.id(&Int{Name: arraySizeTypeName, Size: 4, Bits: 32})
+	}
+	return enc.indexType
+}
+
+func (enc *typeEncoder) encode(typ Type) (rawType, error) {
+	var raw rawType
+
+	switch v := typ.(type) {
+	case *Int:
+		raw.SetKind(kindInt)
+		raw.NameOff = enc.name(v.Name)
+		raw.SizeType = v.Size
+		bits := v.Bits
+		if bits == 0 {
+			bits = byte(v.Size * 8)
+		}
+		data := uint32(v.Encoding)<<24 | (v.Offset&0xff)<<16 | uint32(bits)
+		raw.data = &data
+
+	case *Pointer:
+		raw.SetKind(kindPointer)
+		raw.SizeType = uint32(enc.id(v.Target))
+
+	case *Array:
+		raw.SetKind(kindArray)
+		raw.data = &btfArray{
+			Type:      enc.id(v.Type),
+			IndexType: enc.arrayIndexType(),
+			Nelems:    v.Nelems,
+		}
+
+	case *Struct:
+		raw.SetKind(kindStruct)
+		raw.NameOff = enc.name(v.Name)
+		raw.SizeType = v.Size
+		raw.data = enc.members(&raw, v.Members)
+
+	case *Union:
+		raw.SetKind(kindUnion)
+		raw.NameOff = enc.name(v.Name)
+		raw.SizeType = v.Size
+		raw.data = enc.members(&raw, v.Members)
+
+	case *Enum:
+		// Signed isn't encoded, kernels before 6.0 reject enums with the
+		// kind flag set.
+		raw.SetKind(kindEnum)
+		raw.NameOff = enc.name(v.Name)
+		raw.SizeType = v.size()
+		values := make([]btfEnum, 0, len(v.Values))
+		for _, value := range v.Values {
+			values = append(values, btfEnum{enc.name(value.Name), value.Value})
+		}
+		raw.SetVlen(len(values))
+		raw.data = values
+
+	case *Fwd:
+		raw.SetKind(kindForward)
+		raw.NameOff = enc.name(v.Name)
+		if v.Kind == FwdUnion {
+			raw.setInfo(1, btfTypeKindFlagMask, btfTypeKindFlagShift)
+		}
+
+	case *Typedef:
+		raw.SetKind(kindTypedef)
+		raw.NameOff = enc.name(v.Name)
+		raw.SizeType = uint32(enc.id(v.Type))
+
+	case *Volatile:
+		raw.SetKind(kindVolatile)
+		raw.SizeType = uint32(enc.id(v.Type))
+
+	case *Const:
+		raw.SetKind(kindConst)
+		raw.SizeType = uint32(enc.id(v.Type))
+
+	case *Restrict:
+		raw.SetKind(kindRestrict)
+		raw.SizeType = uint32(enc.id(v.Type))
+
+	case *Float:
+		raw.SetKind(kindFloat)
+		raw.NameOff = enc.name(v.Name)
+		raw.SizeType = v.Size
+
+	default:
+		return rawType{}, fmt.Errorf("can't encode %s: %w", typ, ErrNotSupported)
+	}
+
+	return raw, nil
+}
+
+func (enc *typeEncoder) members(raw *rawType, members []Member) []btfMember {
+	var bitfields bool
+	for _, member := range members {
+		if member.BitfieldSize > 0 {
+			bitfields = true
+			break
+		}
+	}
+
+	if bitfields {
+		// The member offset contains the bitfield size if kind_flag is set.
+		raw.setInfo(1, btfTypeKindFlagMask, btfTypeKindFlagShift)
+	}
+
+	btfMembers := make([]btfMember, 0, len(members))
+	for _, member := range members {
+		offset := member.Offset
+		if bitfields {
+			offset |= member.BitfieldSize << 24
+		}
+
+		btfMembers = append(btfMembers, btfMember{
+			NameOff: enc.name(member.Name),
+			Type:    enc.id(member.Type),
+			Offset:  offset,
+		})
+	}
+
+	raw.SetVlen(len(btfMembers))
+	return btfMembers
+}
+
+func (enc *typeEncoder) marshal() ([]byte, error) {
+	spec := &Spec{
+		rawTypes: enc.rawTypes,
+		strings:  stringTable(enc.strings.Bytes()),
+	}
+
+	return spec.marshal(marshalOpts{ByteOrder: internal.NativeEndian})
+}
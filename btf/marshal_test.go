@@ -0,0 +1,66 @@
+package btf
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cilium/ebpf/internal/testutils"
+)
+
+func TestNewMapFromTypes(t *testing.T) {
+	u32 := &Int{Name: "u32", Size: 4}
+	fwd := &Fwd{Name: "opaque", Kind: FwdUnion}
+	enum := &Enum{Name: "e", Values: []EnumValue{{Name: "ONE", Value: 1}}}
+
+	value := &Struct{Name: "value", Size: 24, Members: []Member{
+		{Name: "a", Type: &Typedef{Name: "u32_t", Type: &Const{Type: u32}}},
+		{Name: "flags", Type: u32, Offset: 32, BitfieldSize: 3},
+		{Name: "e", Type: enum, Offset: 64},
+		{Name: "arr", Type: &Array{Type: u32, Nelems: 2}, Offset: 96},
+		{Name: "ptr", Type: &Pointer{Target: fwd}, Offset: 128},
+	}}
+
+	m, err := NewMapFromTypes(u32, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if key, ok := MapKey(m).(*Int); !ok || key.Name != "u32" || key.Size != 4 {
+		t.Error("Unexpected key type", MapKey(m))
+	}
+
+	decoded, ok := MapValue(m).(*Struct)
+	if !ok || decoded.Name != "value" || decoded.Size != 24 {
+		t.Fatal("Unexpected value type", MapValue(m))
+	}
+
+	if decoded.Members[0].Type.(*Typedef).Type.(*Const).Type != MapKey(m) {
+		t.Error("Key and value don't share the u32 type")
+	}
+	if flags := decoded.Members[1]; flags.Offset != 32 || flags.BitfieldSize != 3 {
+		t.Errorf("Bitfield is at %d with size %d", flags.Offset, flags.BitfieldSize)
+	}
+	if e := decoded.Members[2].Type.(*Enum); len(e.Values) != 1 || e.Values[0].Name != "ONE" {
+		t.Error("Unexpected enum values", e.Values)
+	}
+	if arr := decoded.Members[3].Type.(*Array); arr.Nelems != 2 {
+		t.Error("Unexpected number of array elements", arr.Nelems)
+	}
+	if fwd := decoded.Members[4].Type.(*Pointer).Target.(*Fwd); fwd.Kind != FwdUnion {
+		t.Error("Forward declaration isn't a union")
+	}
+
+	h, err := NewHandle(MapSpec(m))
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal("Kernel rejects generated BTF:", err)
+	}
+	h.Close()
+}
+
+func TestNewMapFromTypesUnsupported(t *testing.T) {
+	_, err := NewMapFromTypes(&Int{Name: "u32", Size: 4}, &FuncProto{Return: &Void{}})
+	if !errors.Is(err, ErrNotSupported) {
+		t.Error("Expected ErrNotSupported for FuncProto, got", err)
+	}
+}
@@ -247,6 +247,11 @@ type Member struct {
 type Enum struct {
 	TypeID
 	Name
+	// Size of the enum in bytes. Zero is the same as 4.
+	Size uint32
+	// Signed is true if the values of the enum are signed. Only recorded
+	// in BTF by Linux 6.0 and later, enums are unsigned otherwise.
+	Signed bool
 	Values []EnumValue
 }
 
@@ -262,7 +267,22 @@ type EnumValue struct {
 	Value int32
 }
 
-func (e *Enum) size() uint32    { return 4 }
+func (e *Enum) size() uint32 {
+	if e.Size == 0 {
+		return 4
+	}
+	return e.Size
+}
+
+// intType returns the integer representation of the enum.
+func (e *Enum) intType() *Int {
+	i := &Int{Size: e.size(), Bits: byte(e.size() * 8)}
+	if e.Signed {
+		i.Encoding = Signed
+	}
+	return i
+}
+
 func (e *Enum) walk(*typeDeque) {}
 func (e *Enum) copy() Type {
 	cpy := *e
@@ -794,7 +814,7 @@ func inflateRawTypes(rawTypes []rawType, rawStrings stringTable) (types []Type,
 					Value: btfVal.Val,
 				})
 			}
-			typ = &Enum{id, name, vals}
+			typ = &Enum{id, name, raw.Size(), raw.KindFlag(), vals}
 
 		case kindForward:
 			if raw.KindFlag() {
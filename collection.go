@@ -5,21 +5,40 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math"
+	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
 	"strings"
 
 	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/btf"
 	"github.com/cilium/ebpf/internal"
-	"github.com/cilium/ebpf/internal/btf"
 )
 
 // CollectionOptions control loading a collection into the kernel.
 //
 // Maps and Programs are passed to NewMapWithOptions and NewProgramsWithOptions.
 type CollectionOptions struct {
-	Maps     MapOptions
+	Maps MapOptions
+	// Programs applies to all programs in the collection, including the
+	// verifier log settings.
 	Programs ProgramOptions
+
+	// MapOverrides changes the MapSpec of individual maps before they are
+	// created, keyed by map name. The CollectionSpec isn't modified.
+	MapOverrides map[string]MapOverride
+}
+
+// MapOverride replaces properties of a MapSpec when loading a collection.
+//
+// Nil fields leave the corresponding property of the MapSpec unchanged, which
+// allows overriding a property with zero.
+type MapOverride struct {
+	MaxEntries *uint32
+	Flags      *uint32
 }
 
 // CollectionSpec describes a collection.
@@ -27,6 +46,10 @@ type CollectionSpec struct {
 	Maps     map[string]*MapSpec
 	Programs map[string]*ProgramSpec
 
+	// StructOps are kernel structs implemented by Programs. They are not
+	// loaded by NewCollection, use NewStructOpsMap instead.
+	StructOps map[string]*StructOpsSpec
+
 	// ByteOrder specifies whether the ELF was compiled for
 	// big-endian or little-endian architectures.
 	ByteOrder binary.ByteOrder
@@ -41,6 +64,7 @@ func (cs *CollectionSpec) Copy() *CollectionSpec {
 	cpy := CollectionSpec{
 		Maps:      make(map[string]*MapSpec, len(cs.Maps)),
 		Programs:  make(map[string]*ProgramSpec, len(cs.Programs)),
+		StructOps: make(map[string]*StructOpsSpec, len(cs.StructOps)),
 		ByteOrder: cs.ByteOrder,
 	}
 
@@ -52,17 +76,67 @@ func (cs *CollectionSpec) Copy() *CollectionSpec {
 		cpy.Programs[name] = spec.Copy()
 	}
 
+	for name, spec := range cs.StructOps {
+		cpy.StructOps[name] = spec.Copy()
+	}
+
 	return &cpy
 }
 
+// MapSpec returns the MapSpec with the given name.
+//
+// Returns a *NotFoundError if the spec doesn't contain such a map.
+func (cs *CollectionSpec) MapSpec(name string) (*MapSpec, error) {
+	spec := cs.Maps[name]
+	if spec == nil {
+		return nil, &NotFoundError{"map", name}
+	}
+	return spec, nil
+}
+
+// ProgramSpec returns the ProgramSpec with the given name.
+//
+// Returns a *NotFoundError if the spec doesn't contain such a program.
+func (cs *CollectionSpec) ProgramSpec(name string) (*ProgramSpec, error) {
+	spec := cs.Programs[name]
+	if spec == nil {
+		return nil, &NotFoundError{"program", name}
+	}
+	return spec, nil
+}
+
+// NotFoundError is returned when a map or program is missing from a
+// CollectionSpec.
+type NotFoundError struct {
+	// Kind is either "map" or "program".
+	Kind string
+	Name string
+}
+
+func (nfe *NotFoundError) Error() string {
+	return fmt.Sprintf("missing %s %q", nfe.Kind, nfe.Name)
+}
+
+// Unwrap returns os.ErrNotExist.
+func (nfe *NotFoundError) Unwrap() error {
+	return os.ErrNotExist
+}
+
 // RewriteMaps replaces all references to specific maps.
 //
 // Use this function to use pre-existing maps instead of creating new ones
 // when calling NewCollection. Any named maps are removed from CollectionSpec.Maps.
 //
-// Returns an error if a named map isn't used in at least one program.
+// Returns an error if a named map isn't used in at least one program, or
+// if it isn't compatible with the MapSpec of the same name.
 func (cs *CollectionSpec) RewriteMaps(maps map[string]*Map) error {
 	for symbol, m := range maps {
+		if spec := cs.Maps[symbol]; spec != nil {
+			if err := spec.Compatible(m); err != nil {
+				return fmt.Errorf("map %s: %w", symbol, err)
+			}
+		}
+
 		// have we seen a program that uses this symbol / map
 		seen := false
 		fd := m.FD()
@@ -162,15 +236,15 @@ func (cs *CollectionSpec) Assign(to interface{}) error {
 	valueOf := func(typ reflect.Type, name string) (reflect.Value, error) {
 		switch typ {
 		case reflect.TypeOf((*ProgramSpec)(nil)):
-			p := cs.Programs[name]
-			if p == nil {
-				return reflect.Value{}, fmt.Errorf("missing program %q", name)
+			p, err := cs.ProgramSpec(name)
+			if err != nil {
+				return reflect.Value{}, err
 			}
 			return reflect.ValueOf(p), nil
 		case reflect.TypeOf((*MapSpec)(nil)):
-			m := cs.Maps[name]
-			if m == nil {
-				return reflect.Value{}, fmt.Errorf("missing map %q", name)
+			m, err := cs.MapSpec(name)
+			if err != nil {
+				return reflect.Value{}, err
 			}
 			return reflect.ValueOf(m), nil
 		default:
@@ -208,7 +282,10 @@ func (cs *CollectionSpec) LoadAndAssign(to interface{}, opts *CollectionOptions)
 		opts = &CollectionOptions{}
 	}
 
-	loader := newCollectionLoader(cs, opts)
+	loader, err := newCollectionLoader(cs, opts)
+	if err != nil {
+		return err
+	}
 	defer loader.close()
 
 	valueOf := func(typ reflect.Type, name string) (reflect.Value, error) {
@@ -252,6 +329,9 @@ func (cs *CollectionSpec) LoadAndAssign(to interface{}, opts *CollectionOptions)
 type Collection struct {
 	Programs map[string]*Program
 	Maps     map[string]*Map
+
+	// Layout of data sections like .data and .bss, by map name.
+	dataSections map[string]*btf.Datasec
 }
 
 // NewCollection creates a Collection from a specification.
@@ -261,7 +341,10 @@ func NewCollection(spec *CollectionSpec) (*Collection, error) {
 
 // NewCollectionWithOptions creates a Collection from a specification.
 func NewCollectionWithOptions(spec *CollectionSpec, opts CollectionOptions) (*Collection, error) {
-	loader := newCollectionLoader(spec, &opts)
+	loader, err := newCollectionLoader(spec, &opts)
+	if err != nil {
+		return nil, err
+	}
 	defer loader.close()
 
 	// Create maps first, as their fds need to be linked into programs.
@@ -288,11 +371,26 @@ func NewCollectionWithOptions(spec *CollectionSpec, opts CollectionOptions) (*Co
 	loader.finalize()
 
 	return &Collection{
-		progs,
-		maps,
+		Programs:     progs,
+		Maps:         maps,
+		dataSections: dataSections(spec),
 	}, nil
 }
 
+// dataSections returns the layout of the data section maps in spec.
+func dataSections(spec *CollectionSpec) map[string]*btf.Datasec {
+	sections := make(map[string]*btf.Datasec)
+	for name, mapSpec := range spec.Maps {
+		if mapSpec.BTF == nil {
+			continue
+		}
+		if ds, ok := btf.MapValue(mapSpec.BTF).(*btf.Datasec); ok {
+			sections[name] = ds
+		}
+	}
+	return sections
+}
+
 type handleCache struct {
 	btfHandles map[*btf.Spec]*btf.Handle
 	btfSpecs   map[io.ReaderAt]*btf.Spec
@@ -347,14 +445,20 @@ type collectionLoader struct {
 	handles  *handleCache
 }
 
-func newCollectionLoader(coll *CollectionSpec, opts *CollectionOptions) *collectionLoader {
+func newCollectionLoader(coll *CollectionSpec, opts *CollectionOptions) (*collectionLoader, error) {
+	for name := range opts.MapOverrides {
+		if _, ok := coll.Maps[name]; !ok {
+			return nil, fmt.Errorf("override for missing map %s", name)
+		}
+	}
+
 	return &collectionLoader{
 		coll,
 		opts,
 		make(map[string]*Map),
 		make(map[string]*Program),
 		newHandleCache(),
-	}
+	}, nil
 }
 
 // finalize should be called when all the collectionLoader's resources
@@ -383,6 +487,16 @@ func (cl *collectionLoader) loadMap(mapName string) (*Map, error) {
 		return nil, fmt.Errorf("missing map %s", mapName)
 	}
 
+	if override, ok := cl.opts.MapOverrides[mapName]; ok {
+		mapSpec = mapSpec.Copy()
+		if override.MaxEntries != nil {
+			mapSpec.MaxEntries = *override.MaxEntries
+		}
+		if override.Flags != nil {
+			mapSpec.Flags = *override.Flags
+		}
+	}
+
 	m, err := newMapWithOptions(mapSpec, cl.opts.Maps, cl.handles)
 	if err != nil {
 		return nil, fmt.Errorf("map %s: %w", mapName, err)
@@ -489,6 +603,121 @@ func LoadCollection(file string) (*Collection, error) {
 	return NewCollection(spec)
 }
 
+// LoadPinnedCollection restores a Collection which was pinned to dir using
+// Collection.Pin.
+//
+// If spec is not nil, it is used to determine which objects to load: every
+// map and program in spec must be pinned in dir, and must be compatible with
+// its MapSpec or ProgramSpec. No programs are loaded into the kernel.
+// Otherwise all maps and programs pinned in dir are loaded, other files are
+// ignored, and GlobalData is unavailable since there is no BTF.
+func LoadPinnedCollection(dir string, spec *CollectionSpec, opts *LoadPinOptions) (_ *Collection, err error) {
+	coll := &Collection{
+		Programs: make(map[string]*Program),
+		Maps:     make(map[string]*Map),
+	}
+	defer func() {
+		if err != nil {
+			coll.Close()
+		}
+	}()
+
+	if spec != nil {
+		for name, mapSpec := range spec.Maps {
+			m, err := loadPinnedObject(filepath.Join(dir, name), opts, internal.BPFMapObject)
+			if err != nil {
+				return nil, fmt.Errorf("map %s: %w", name, err)
+			}
+			coll.Maps[name] = m.(*Map)
+
+			if err := mapSpec.Compatible(coll.Maps[name]); err != nil {
+				return nil, fmt.Errorf("map %s: %w", name, err)
+			}
+		}
+
+		for name, progSpec := range spec.Programs {
+			p, err := loadPinnedObject(filepath.Join(dir, name), opts, internal.BPFProgObject)
+			if err != nil {
+				return nil, fmt.Errorf("program %s: %w", name, err)
+			}
+			coll.Programs[name] = p.(*Program)
+
+			if typ := coll.Programs[name].Type(); typ != progSpec.Type {
+				return nil, fmt.Errorf("program %s: expected type %v, got %v", name, progSpec.Type, typ)
+			}
+		}
+
+		coll.dataSections = dataSections(spec)
+		return coll, nil
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		name := entry.Name()
+		obj, err := loadPinnedObject(filepath.Join(dir, name), opts, "")
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		switch obj := obj.(type) {
+		case *Map:
+			coll.Maps[name] = obj
+		case *Program:
+			coll.Programs[name] = obj
+		}
+	}
+
+	return coll, nil
+}
+
+// loadPinnedObject loads a pinned map or program.
+//
+// Returns an error if want is not empty and the pinned object is of a
+// different kind. Returns nil if want is empty and the object is neither
+// a map nor a program.
+func loadPinnedObject(fileName string, opts *LoadPinOptions, want internal.BPFObjectType) (interface{}, error) {
+	fd, err := internal.BPFObjGet(fileName, opts.Marshal())
+	if err != nil {
+		return nil, err
+	}
+
+	typ, err := internal.BPFObjType(fd)
+	if err != nil {
+		fd.Close()
+		return nil, err
+	}
+
+	if want != "" && typ != want {
+		fd.Close()
+		return nil, fmt.Errorf("expected %s, got %s", want, typ)
+	}
+
+	switch typ {
+	case internal.BPFMapObject:
+		m, err := newMapFromFD(fd)
+		if err != nil {
+			return nil, err
+		}
+		m.pinnedPath = fileName
+		return m, nil
+
+	case internal.BPFProgObject:
+		return newPinnedProgramFromFD(fd, fileName)
+
+	default:
+		fd.Close()
+		return nil, nil
+	}
+}
+
 // Close frees all maps and programs associated with the collection.
 //
 // The collection mustn't be used afterwards.
@@ -501,6 +730,134 @@ func (coll *Collection) Close() {
 	}
 }
 
+// Pin persists all maps and programs of the collection on the BPF virtual
+// file system, using their names as file names in dir.
+//
+// Pinning is not atomic. If any object can't be pinned, the objects pinned
+// by this call are restored to their previous state and an error describing
+// every failure is returned.
+//
+// Returns an error without pinning anything if a map and a program share
+// the same name.
+//
+// This requires bpffs to be mounted above dir. See https://docs.cilium.io/en/k8s-doc/admin/#admin-mount-bpffs
+func (coll *Collection) Pin(dir string) error {
+	for name, m := range coll.Maps {
+		if m != nil && coll.Programs[name] != nil {
+			return fmt.Errorf("pin collection: map and program %s would be pinned to the same path", name)
+		}
+	}
+
+	var (
+		pinned []collectionObject
+		errs   pinErrors
+	)
+
+	for _, obj := range coll.objects() {
+		if err := obj.Pin(filepath.Join(dir, obj.name)); err != nil {
+			errs = append(errs, fmt.Errorf("%s %s: %w", obj.kind, obj.name, err))
+			continue
+		}
+		pinned = append(pinned, obj)
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	for _, obj := range pinned {
+		if obj.pinnedPath == "" {
+			_ = obj.Unpin()
+		} else {
+			_ = obj.Pin(obj.pinnedPath)
+		}
+	}
+
+	return fmt.Errorf("pin collection: %w", errs)
+}
+
+// Unpin removes the maps and programs of the collection that are pinned in
+// dir from the BPF virtual file system.
+//
+// Objects pinned elsewhere are left untouched. Returns an error describing
+// every object that couldn't be unpinned.
+func (coll *Collection) Unpin(dir string) error {
+	var errs pinErrors
+	for _, obj := range coll.objects() {
+		if obj.pinnedPath != filepath.Join(dir, obj.name) {
+			continue
+		}
+
+		if err := obj.Unpin(); err != nil {
+			errs = append(errs, fmt.Errorf("%s %s: %w", obj.kind, obj.name, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("unpin collection: %w", errs)
+	}
+	return nil
+}
+
+type pinnable interface {
+	Pin(fileName string) error
+	Unpin() error
+}
+
+// collectionObject is a map or program of a Collection.
+type collectionObject struct {
+	pinnable
+	kind, name string
+	// The path the object was pinned at when calling objects().
+	pinnedPath string
+}
+
+// objects returns all maps and programs in the collection, sorted by kind
+// and name.
+func (coll *Collection) objects() []collectionObject {
+	var objs []collectionObject
+	for name, m := range coll.Maps {
+		if m != nil {
+			objs = append(objs, collectionObject{m, "map", name, m.pinnedPath})
+		}
+	}
+	for name, p := range coll.Programs {
+		if p != nil {
+			objs = append(objs, collectionObject{p, "program", name, p.pinnedPath})
+		}
+	}
+
+	sort.Slice(objs, func(i, j int) bool {
+		if objs[i].kind != objs[j].kind {
+			return objs[i].kind < objs[j].kind
+		}
+		return objs[i].name < objs[j].name
+	})
+	return objs
+}
+
+// pinErrors contains all errors encountered while pinning or unpinning
+// the objects of a Collection.
+type pinErrors []error
+
+func (pe pinErrors) Error() string {
+	msgs := make([]string, 0, len(pe))
+	for _, err := range pe {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Is returns true if any of the contained errors matches target.
+func (pe pinErrors) Is(target error) bool {
+	for _, err := range pe {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
 // DetachMap removes the named map from the Collection.
 //
 // This means that a later call to Close() will not affect this map.
@@ -523,6 +880,116 @@ func (coll *Collection) DetachProgram(name string) *Program {
 	return p
 }
 
+// GlobalData returns the global variables in a data section like .data,
+// .bss or .rodata.
+//
+// Requires BTF for the data section to be present in the CollectionSpec the
+// Collection was created or loaded from.
+func (coll *Collection) GlobalData(section string) (GlobalVars, error) {
+	m := coll.Maps[section]
+	if m == nil {
+		return GlobalVars{}, fmt.Errorf("data section %s: missing map", section)
+	}
+
+	ds := coll.dataSections[section]
+	if ds == nil {
+		return GlobalVars{}, fmt.Errorf("data section %s: no BTF", section)
+	}
+
+	return newGlobalVars(m, ds)
+}
+
+// GlobalVars gives access to the global variables in a data section.
+//
+// Get and Set read the whole data section, and Set writes it back. This is
+// not atomic with regard to concurrent modifications by BPF programs.
+type GlobalVars struct {
+	m    *Map
+	vars map[string]btf.VarSecinfo
+}
+
+func newGlobalVars(m *Map, ds *btf.Datasec) (GlobalVars, error) {
+	if m.Type() != Array || m.MaxEntries() != 1 {
+		return GlobalVars{}, fmt.Errorf("data section %s: map is not an array with a single entry", ds.Name)
+	}
+
+	vars := make(map[string]btf.VarSecinfo)
+	for _, vsi := range ds.Vars {
+		v, ok := vsi.Type.(*btf.Var)
+		if !ok {
+			return GlobalVars{}, fmt.Errorf("data section %s: unexpected type %s", ds.Name, vsi.Type)
+		}
+
+		if vsi.Offset+vsi.Size > m.ValueSize() {
+			return GlobalVars{}, fmt.Errorf("data section %s: variable %s is out of bounds", ds.Name, v.Name)
+		}
+
+		vars[string(v.Name)] = vsi
+	}
+
+	return GlobalVars{m, vars}, nil
+}
+
+// Get unmarshals the value of a variable into out.
+//
+// out is unmarshalled according to the same rules as map values.
+func (gv GlobalVars) Get(name string, out interface{}) error {
+	vsi, ok := gv.vars[name]
+	if !ok {
+		return fmt.Errorf("variable %s: %w", name, ErrKeyNotExist)
+	}
+
+	buf, err := gv.contents()
+	if err != nil {
+		return fmt.Errorf("variable %s: %w", name, err)
+	}
+
+	value := buf[vsi.Offset : vsi.Offset+vsi.Size]
+	if err := unmarshalBytes(out, value); err != nil {
+		return fmt.Errorf("variable %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// Set replaces the value of a variable.
+//
+// value must marshal to the size of the variable, according to the same
+// rules as map values. Fails for read-only sections like .rodata, since
+// these are frozen when the Collection is created.
+func (gv GlobalVars) Set(name string, value interface{}) error {
+	vsi, ok := gv.vars[name]
+	if !ok {
+		return fmt.Errorf("variable %s: %w", name, ErrKeyNotExist)
+	}
+
+	data, err := marshalBytes(value, int(vsi.Size))
+	if err != nil {
+		return fmt.Errorf("variable %s: %w", name, err)
+	}
+
+	buf, err := gv.contents()
+	if err != nil {
+		return fmt.Errorf("variable %s: %w", name, err)
+	}
+
+	copy(buf[vsi.Offset:vsi.Offset+vsi.Size], data)
+
+	if err := gv.m.Update(uint32(0), buf, UpdateExist); err != nil {
+		return fmt.Errorf("variable %s: %w", name, err)
+	}
+
+	return nil
+}
+
+func (gv GlobalVars) contents() ([]byte, error) {
+	buf := make([]byte, gv.m.ValueSize())
+	if err := gv.m.Lookup(uint32(0), &buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
 // Assign the contents of a collection to a struct.
 //
 // Deprecated: use CollectionSpec.Assign instead. It provides the same
@@ -1,12 +1,17 @@
 package ebpf
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
 	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/btf"
 	"github.com/cilium/ebpf/internal/testutils"
+	"github.com/cilium/ebpf/internal/unix"
 )
 
 func TestCollectionSpecNotModified(t *testing.T) {
@@ -82,6 +87,39 @@ func TestCollectionSpecCopy(t *testing.T) {
 	}
 }
 
+func TestCollectionSpecLookup(t *testing.T) {
+	cs := &CollectionSpec{
+		Maps: map[string]*MapSpec{
+			"my-map": {Type: Array},
+		},
+		Programs: map[string]*ProgramSpec{
+			"my-prog": {Type: SocketFilter},
+		},
+	}
+
+	if m, err := cs.MapSpec("my-map"); err != nil || m != cs.Maps["my-map"] {
+		t.Error("MapSpec doesn't return the spec:", err)
+	}
+
+	if p, err := cs.ProgramSpec("my-prog"); err != nil || p != cs.Programs["my-prog"] {
+		t.Error("ProgramSpec doesn't return the spec:", err)
+	}
+
+	var nfe *NotFoundError
+	_, err := cs.MapSpec("my-prog")
+	if !errors.As(err, &nfe) || nfe.Kind != "map" || nfe.Name != "my-prog" {
+		t.Error("Expected NotFoundError for missing map, got", err)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Error("NotFoundError doesn't wrap os.ErrNotExist")
+	}
+
+	_, err = cs.ProgramSpec("my-map")
+	if !errors.As(err, &nfe) || nfe.Kind != "program" || nfe.Name != "my-map" {
+		t.Error("Expected NotFoundError for missing program, got", err)
+	}
+}
+
 func TestCollectionSpecRewriteMaps(t *testing.T) {
 	insns := asm.Instructions{
 		// R1 map
@@ -128,6 +166,24 @@ func TestCollectionSpecRewriteMaps(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	incompatible, err := NewMap(&MapSpec{
+		Type:       Array,
+		KeySize:    4,
+		ValueSize:  8,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer incompatible.Close()
+
+	err = cs.RewriteMaps(map[string]*Map{
+		"test-map": incompatible,
+	})
+	if !errors.Is(err, ErrMapIncompatible) {
+		t.Fatal("Expected ErrMapIncompatible for map with wrong value size, got", err)
+	}
+
 	err = cs.RewriteMaps(map[string]*Map{
 		"test-map": newMap,
 	})
@@ -208,6 +264,71 @@ func TestCollectionSpec_LoadAndAssign_LazyLoading(t *testing.T) {
 	}
 }
 
+func TestCollectionMapOverrides(t *testing.T) {
+	spec := &CollectionSpec{
+		Maps: map[string]*MapSpec{
+			"hash": {
+				Type:       Hash,
+				KeySize:    4,
+				ValueSize:  4,
+				MaxEntries: 1,
+			},
+		},
+	}
+
+	maxEntries, flags := uint32(42), uint32(unix.BPF_F_NO_PREALLOC)
+	coll, err := NewCollectionWithOptions(spec, CollectionOptions{
+		MapOverrides: map[string]MapOverride{
+			"hash": {MaxEntries: &maxEntries, Flags: &flags},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer coll.Close()
+
+	m := coll.Maps["hash"]
+	if m.MaxEntries() != 42 {
+		t.Error("Expected MaxEntries to be overridden, got", m.MaxEntries())
+	}
+	if m.Flags() != unix.BPF_F_NO_PREALLOC {
+		t.Error("Expected Flags to be overridden, got", m.Flags())
+	}
+
+	if spec.Maps["hash"].MaxEntries != 1 || spec.Maps["hash"].Flags != 0 {
+		t.Error("Overrides modify the CollectionSpec")
+	}
+
+	spec.Maps["hash"].Flags = unix.BPF_F_NO_PREALLOC
+	noFlags := uint32(0)
+	coll, err = NewCollectionWithOptions(spec, CollectionOptions{
+		MapOverrides: map[string]MapOverride{
+			"hash": {Flags: &noFlags},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer coll.Close()
+
+	m = coll.Maps["hash"]
+	if m.Flags() != 0 {
+		t.Error("Expected Flags to be cleared, got", m.Flags())
+	}
+	if m.MaxEntries() != 1 {
+		t.Error("Expected nil MaxEntries to leave the MapSpec unchanged, got", m.MaxEntries())
+	}
+
+	_, err = NewCollectionWithOptions(spec, CollectionOptions{
+		MapOverrides: map[string]MapOverride{
+			"missing": {MaxEntries: &maxEntries},
+		},
+	})
+	if err == nil {
+		t.Error("Override for a missing map doesn't return an error")
+	}
+}
+
 func TestCollectionAssign(t *testing.T) {
 	var specs struct {
 		Program *ProgramSpec `ebpf:"prog1"`
@@ -304,6 +425,178 @@ func TestCollectionAssign(t *testing.T) {
 	}
 }
 
+func TestCollectionPin(t *testing.T) {
+	coll, err := NewCollection(&CollectionSpec{
+		Maps: map[string]*MapSpec{
+			"map1": {
+				Type:       Array,
+				KeySize:    4,
+				ValueSize:  4,
+				MaxEntries: 1,
+			},
+		},
+		Programs: map[string]*ProgramSpec{
+			"prog1": {
+				Type: SocketFilter,
+				Instructions: asm.Instructions{
+					asm.LoadImm(asm.R0, 0, asm.DWord),
+					asm.Return(),
+				},
+				License: "MIT",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer coll.Close()
+
+	tmp := testutils.TempBPFFS(t)
+	if err := coll.Pin(tmp); err != nil {
+		t.Fatal("Can't pin collection:", err)
+	}
+
+	for _, name := range []string{"map1", "prog1"} {
+		if _, err := os.Stat(filepath.Join(tmp, name)); err != nil {
+			t.Errorf("Object %s isn't pinned: %s", name, err)
+		}
+	}
+
+	if !coll.Maps["map1"].IsPinned() || !coll.Programs["prog1"].IsPinned() {
+		t.Error("Expected all objects to be pinned")
+	}
+
+	if err := coll.Unpin(tmp); err != nil {
+		t.Fatal("Can't unpin collection:", err)
+	}
+
+	for _, name := range []string{"map1", "prog1"} {
+		if _, err := os.Stat(filepath.Join(tmp, name)); !os.IsNotExist(err) {
+			t.Errorf("Object %s is still pinned", name)
+		}
+	}
+
+	// Pinning fails for prog1, which must roll back the pin of map1.
+	if err := os.Mkdir(filepath.Join(tmp, "prog1"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	err = coll.Pin(tmp)
+	if !errors.Is(err, os.ErrExist) {
+		t.Fatal("Expected pinning to fail with os.ErrExist, got", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(tmp, "map1")); !os.IsNotExist(err) {
+		t.Error("Pin of map1 wasn't rolled back")
+	}
+
+	if coll.Maps["map1"].IsPinned() {
+		t.Error("Expected map1 to not be pinned after rollback")
+	}
+
+	if err := os.Remove(filepath.Join(tmp, "prog1")); err != nil {
+		t.Fatal(err)
+	}
+
+	coll.Programs["map1"] = coll.Programs["prog1"]
+	defer delete(coll.Programs, "map1")
+
+	if err := coll.Pin(tmp); err == nil {
+		t.Fatal("Pin accepts a map and a program with the same name")
+	}
+
+	if coll.Maps["map1"].IsPinned() || coll.Programs["prog1"].IsPinned() {
+		t.Error("Pin with duplicate names pins objects")
+	}
+}
+
+func TestLoadPinnedCollection(t *testing.T) {
+	spec := &CollectionSpec{
+		Maps: map[string]*MapSpec{
+			"map1": {
+				Type:       Array,
+				KeySize:    4,
+				ValueSize:  4,
+				MaxEntries: 1,
+			},
+		},
+		Programs: map[string]*ProgramSpec{
+			"prog1": {
+				Type: SocketFilter,
+				Instructions: asm.Instructions{
+					asm.LoadImm(asm.R0, 0, asm.DWord),
+					asm.Return(),
+				},
+				License: "MIT",
+			},
+		},
+	}
+
+	coll, err := NewCollection(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer coll.Close()
+
+	tmp := testutils.TempBPFFS(t)
+	if err := coll.Pin(tmp); err != nil {
+		t.Fatal("Can't pin collection:", err)
+	}
+
+	if err := coll.Maps["map1"].Put(uint32(0), uint32(42)); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, spec := range map[string]*CollectionSpec{
+		"without spec": nil,
+		"with spec":    spec,
+	} {
+		t.Run(name, func(t *testing.T) {
+			pinned, err := LoadPinnedCollection(tmp, spec, nil)
+			testutils.SkipIfNotSupported(t, err)
+			if err != nil {
+				t.Fatal("Can't load pinned collection:", err)
+			}
+			defer pinned.Close()
+
+			if len(pinned.Maps) != 1 || len(pinned.Programs) != 1 {
+				t.Fatalf("Expected one map and one program, got %v", pinned)
+			}
+
+			var v uint32
+			if err := pinned.Maps["map1"].Lookup(uint32(0), &v); err != nil {
+				t.Fatal("Can't lookup in pinned map:", err)
+			}
+			if v != 42 {
+				t.Error("Want value 42, got", v)
+			}
+
+			if prog := pinned.Programs["prog1"]; prog.Type() != SocketFilter {
+				t.Error("Expected program type SocketFilter, got", prog.Type())
+			}
+
+			if !pinned.Maps["map1"].IsPinned() || !pinned.Programs["prog1"].IsPinned() {
+				t.Error("Expected loaded objects to be pinned")
+			}
+		})
+	}
+
+	// A map pinned under a program's name is rejected.
+	swapped := &CollectionSpec{
+		Programs: map[string]*ProgramSpec{"map1": spec.Programs["prog1"]},
+	}
+	if _, err := LoadPinnedCollection(tmp, swapped, nil); err == nil {
+		t.Error("Loading a map as a program should fail")
+	}
+
+	incompatible := &CollectionSpec{
+		Maps: map[string]*MapSpec{"map1": {Type: Hash, KeySize: 4, ValueSize: 4, MaxEntries: 1}},
+	}
+	if _, err := LoadPinnedCollection(tmp, incompatible, nil); !errors.Is(err, ErrMapIncompatible) {
+		t.Error("Expected ErrMapIncompatible, got", err)
+	}
+}
+
 func TestAssignValues(t *testing.T) {
 	zero := func(t reflect.Type, name string) (reflect.Value, error) {
 		return reflect.Zero(t), nil
@@ -494,3 +787,134 @@ func ExampleCollection_Assign() {
 	// Output: SocketFilter
 	// Array
 }
+
+func TestLoadPinnedCollectionGlobalData(t *testing.T) {
+	m, err := NewMap(&MapSpec{
+		Type:       Array,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	// bpffs doesn't allow dots in file names, so the section can't be
+	// called .data.
+	tmp := testutils.TempBPFFS(t)
+	if err := m.Pin(filepath.Join(tmp, "data")); err != nil {
+		t.Fatal(err)
+	}
+
+	ds := &btf.Datasec{
+		Name: ".data",
+		Size: 4,
+		Vars: []btf.VarSecinfo{
+			{Type: &btf.Var{Name: "foo", Type: &btf.Int{Size: 4}}, Offset: 0, Size: 4},
+		},
+	}
+	btfMap := btf.NewMap(nil, &btf.Int{Size: 4}, ds)
+
+	spec := &CollectionSpec{
+		Maps: map[string]*MapSpec{
+			"data": {
+				Type:       Array,
+				KeySize:    4,
+				ValueSize:  4,
+				MaxEntries: 1,
+				BTF:        &btfMap,
+			},
+		},
+	}
+
+	coll, err := LoadPinnedCollection(tmp, spec, nil)
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal("Can't load pinned collection:", err)
+	}
+	defer coll.Close()
+
+	vars, err := coll.GlobalData("data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vars.Set("foo", uint32(42)); err != nil {
+		t.Fatal("Can't set foo:", err)
+	}
+
+	var foo uint32
+	if err := m.Lookup(uint32(0), &foo); err != nil {
+		t.Fatal(err)
+	}
+	if foo != 42 {
+		t.Error("Expected foo to be 42, got", foo)
+	}
+}
+
+func TestGlobalVars(t *testing.T) {
+	m, err := NewMap(&MapSpec{
+		Type:       Array,
+		KeySize:    4,
+		ValueSize:  12,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	u32 := &btf.Int{Size: 4}
+	u64 := &btf.Int{Size: 8}
+	ds := &btf.Datasec{
+		Name: ".data",
+		Size: 12,
+		Vars: []btf.VarSecinfo{
+			{Type: &btf.Var{Name: "foo", Type: u32}, Offset: 0, Size: 4},
+			{Type: &btf.Var{Name: "bar", Type: u64}, Offset: 4, Size: 8},
+		},
+	}
+
+	coll := &Collection{
+		Maps:         map[string]*Map{".data": m},
+		dataSections: map[string]*btf.Datasec{".data": ds},
+	}
+
+	if _, err := coll.GlobalData(".bss"); err == nil {
+		t.Error("GlobalData doesn't return an error for a missing section")
+	}
+
+	vars, err := coll.GlobalData(".data")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := vars.Set("foo", uint32(42)); err != nil {
+		t.Fatal("Can't set foo:", err)
+	}
+	if err := vars.Set("bar", uint64(23)); err != nil {
+		t.Fatal("Can't set bar:", err)
+	}
+	if err := vars.Set("bar", uint32(1)); err == nil {
+		t.Error("Set accepts a value of the wrong size")
+	}
+	if err := vars.Set("baz", uint32(1)); !errors.Is(err, ErrKeyNotExist) {
+		t.Error("Expected ErrKeyNotExist for missing variable, got", err)
+	}
+
+	var foo uint32
+	if err := vars.Get("foo", &foo); err != nil {
+		t.Fatal("Can't get foo:", err)
+	}
+	if foo != 42 {
+		t.Error("Expected foo to be 42, got", foo)
+	}
+
+	var bar uint64
+	if err := vars.Get("bar", &bar); err != nil {
+		t.Fatal("Can't get bar:", err)
+	}
+	if bar != 23 {
+		t.Error("Expected bar to be 23, got", bar)
+	}
+}
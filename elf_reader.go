@@ -14,8 +14,8 @@ import (
 	"strings"
 
 	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/btf"
 	"github.com/cilium/ebpf/internal"
-	"github.com/cilium/ebpf/internal/btf"
 	"github.com/cilium/ebpf/internal/unix"
 )
 
@@ -44,6 +44,12 @@ func LoadCollectionSpec(file string) (*CollectionSpec, error) {
 	return spec, nil
 }
 
+// LoadCollectionSpecFromBytes parses an ELF held in memory into a
+// CollectionSpec, for example one embedded via go:embed.
+func LoadCollectionSpecFromBytes(b []byte) (*CollectionSpec, error) {
+	return LoadCollectionSpecFromReader(bytes.NewReader(b))
+}
+
 // LoadCollectionSpecFromReader parses an ELF file into a CollectionSpec.
 func LoadCollectionSpecFromReader(rd io.ReaderAt) (*CollectionSpec, error) {
 	f, err := internal.NewSafeELFFile(rd)
@@ -78,6 +84,8 @@ func LoadCollectionSpecFromReader(rd io.ReaderAt) (*CollectionSpec, error) {
 			sections[idx] = newElfSection(sec, btfMapSection)
 		case sec.Name == ".bss" || sec.Name == ".data" || strings.HasPrefix(sec.Name, ".rodata"):
 			sections[idx] = newElfSection(sec, dataSection)
+		case sec.Name == ".struct_ops":
+			sections[idx] = newElfSection(sec, structOpsSection)
 		case sec.Type == elf.SHT_REL:
 			// Store relocations under the section index of the target
 			relSections[elf.SectionIndex(sec.Info)] = sec
@@ -120,7 +128,7 @@ func LoadCollectionSpecFromReader(rd io.ReaderAt) (*CollectionSpec, error) {
 		// all NOTYPE ones.
 		keep := symType == elf.STT_NOTYPE
 		switch section.kind {
-		case mapSection, btfMapSection, dataSection:
+		case mapSection, btfMapSection, dataSection, structOpsSection:
 			keep = keep || symType == elf.STT_OBJECT
 		case programSection:
 			keep = keep || symType == elf.STT_FUNC
@@ -189,7 +197,12 @@ func LoadCollectionSpecFromReader(rd io.ReaderAt) (*CollectionSpec, error) {
 		return nil, fmt.Errorf("load programs: %w", err)
 	}
 
-	return &CollectionSpec{maps, progs, ec.ByteOrder}, nil
+	structOps, err := ec.loadStructOps(progs)
+	if err != nil {
+		return nil, fmt.Errorf("load struct_ops: %w", err)
+	}
+
+	return &CollectionSpec{maps, progs, structOps, ec.ByteOrder}, nil
 }
 
 func loadLicense(sec *elf.Section) (string, error) {
@@ -224,6 +237,7 @@ const (
 	btfMapSection
 	programSection
 	dataSection
+	structOpsSection
 )
 
 type elfSection struct {
@@ -959,6 +973,97 @@ func (ec *elfCode) loadDataSections(maps map[string]*MapSpec) error {
 	return nil
 }
 
+// loadStructOps parses the variables in the .struct_ops section into
+// StructOpsSpecs. Function pointers are encoded as relocations to programs,
+// which are updated to attach to the corresponding member.
+// Dump the section with `readelf -x .struct_ops -r <elf_file>`.
+func (ec *elfCode) loadStructOps(progs map[string]*ProgramSpec) (map[string]*StructOpsSpec, error) {
+	var structOps map[string]*StructOpsSpec
+	for _, sec := range ec.sections {
+		if sec.kind != structOpsSection {
+			continue
+		}
+
+		if ec.btf == nil {
+			return nil, fmt.Errorf("missing BTF")
+		}
+
+		var ds btf.Datasec
+		if err := ec.btf.FindType(sec.Name, &ds); err != nil {
+			return nil, fmt.Errorf("cannot find section '%s' in BTF: %w", sec.Name, err)
+		}
+
+		data, err := sec.Data()
+		if err != nil {
+			return nil, fmt.Errorf("section %s: can't get contents: %w", sec.Name, err)
+		}
+
+		for _, vs := range ds.Vars {
+			v, ok := vs.Type.(*btf.Var)
+			if !ok {
+				return nil, fmt.Errorf("section %v: unexpected type %s", sec.Name, vs.Type)
+			}
+			name := string(v.Name)
+
+			typ, ok := v.Type.(*btf.Struct)
+			if !ok {
+				return nil, fmt.Errorf("struct_ops %s: expected struct, got %s", name, v.Type)
+			}
+
+			if uint64(vs.Offset)+uint64(vs.Size) > uint64(len(data)) {
+				return nil, fmt.Errorf("struct_ops %s: out of bounds", name)
+			}
+
+			spec := &StructOpsSpec{
+				Name:     name,
+				TypeName: string(typ.Name),
+				Type:     typ,
+				Data:     make([]byte, vs.Size),
+				Programs: make(map[string]string),
+			}
+			copy(spec.Data, data[vs.Offset:vs.Offset+vs.Size])
+
+			for _, member := range typ.Members {
+				off := uint64(vs.Offset) + uint64(member.Offset/8)
+				rel, ok := sec.relocations[off]
+				if !ok {
+					continue
+				}
+
+				if elf.ST_TYPE(rel.Info) != elf.STT_FUNC {
+					return nil, fmt.Errorf("struct_ops %s: member %s: unexpected relocation to %s", name, member.Name, rel.Name)
+				}
+
+				prog := progs[rel.Name]
+				if prog == nil || prog.Type != StructOps {
+					return nil, fmt.Errorf("struct_ops %s: member %s: %s isn't a struct_ops program", name, member.Name, rel.Name)
+				}
+
+				attachTo := fmt.Sprintf("%s:%s", spec.TypeName, member.Name)
+				if strings.Contains(prog.AttachTo, ":") && prog.AttachTo != attachTo {
+					return nil, fmt.Errorf("struct_ops %s: program %s is already used for %s", name, rel.Name, prog.AttachTo)
+				}
+				prog.AttachTo = attachTo
+
+				// The pointer is filled in when creating the map.
+				start := member.Offset / 8
+				if int(start)+8 > len(spec.Data) {
+					return nil, fmt.Errorf("struct_ops %s: member %s: pointer is out of bounds", name, member.Name)
+				}
+				copy(spec.Data[start:start+8], make([]byte, 8))
+				spec.Programs[string(member.Name)] = rel.Name
+			}
+
+			if structOps == nil {
+				structOps = make(map[string]*StructOpsSpec)
+			}
+			structOps[name] = spec
+		}
+	}
+
+	return structOps, nil
+}
+
 func getProgType(sectionName string) (ProgramType, AttachType, uint32, string) {
 	types := map[string]struct {
 		progType   ProgramType
@@ -971,12 +1076,18 @@ func getProgType(sectionName string) (ProgramType, AttachType, uint32, string) {
 		"kprobe/":               {Kprobe, AttachNone, 0},
 		"uprobe/":               {Kprobe, AttachNone, 0},
 		"kretprobe/":            {Kprobe, AttachNone, 0},
+		"kprobe.multi/":         {Kprobe, AttachTraceKprobeMulti, 0},
+		"kretprobe.multi/":      {Kprobe, AttachTraceKprobeMulti, 0},
+		"uprobe.multi/":         {Kprobe, AttachTraceUprobeMulti, 0},
+		"uretprobe.multi/":      {Kprobe, AttachTraceUprobeMulti, 0},
 		"uretprobe/":            {Kprobe, AttachNone, 0},
 		"tracepoint/":           {TracePoint, AttachNone, 0},
 		"raw_tracepoint/":       {RawTracepoint, AttachNone, 0},
 		"raw_tp/":               {RawTracepoint, AttachNone, 0},
 		"tp_btf/":               {Tracing, AttachTraceRawTp, 0},
 		"xdp":                   {XDP, AttachNone, 0},
+		"xdp/devmap":            {XDP, AttachXDPDevMap, 0},
+		"xdp/cpumap":            {XDP, AttachXDPCPUMap, 0},
 		"perf_event":            {PerfEvent, AttachNone, 0},
 		"lwt_in":                {LWTIn, AttachNone, 0},
 		"lwt_out":               {LWTOut, AttachNone, 0},
@@ -984,8 +1095,9 @@ func getProgType(sectionName string) (ProgramType, AttachType, uint32, string) {
 		"lwt_seg6local":         {LWTSeg6Local, AttachNone, 0},
 		"sockops":               {SockOps, AttachCGroupSockOps, 0},
 		"sk_skb/stream_parser":  {SkSKB, AttachSkSKBStreamParser, 0},
-		"sk_skb/stream_verdict": {SkSKB, AttachSkSKBStreamParser, 0},
-		"sk_msg":                {SkMsg, AttachSkSKBStreamVerdict, 0},
+		"sk_skb/stream_verdict": {SkSKB, AttachSkSKBStreamVerdict, 0},
+		"sk_skb/verdict":        {SkSKB, AttachSkSKBVerdict, 0},
+		"sk_msg":                {SkMsg, AttachSkMsgVerdict, 0},
 		"lirc_mode2":            {LircMode2, AttachLircMode2, 0},
 		"flow_dissector":        {FlowDissector, AttachFlowDissector, 0},
 		"iter/":                 {Tracing, AttachTraceIter, 0},
@@ -999,27 +1111,40 @@ func getProgType(sectionName string) (ProgramType, AttachType, uint32, string) {
 		"freplace/":             {Extension, AttachNone, 0},
 		"lsm/":                  {LSM, AttachLSMMac, 0},
 		"lsm.s/":                {LSM, AttachLSMMac, unix.BPF_F_SLEEPABLE},
-
-		"cgroup_skb/ingress": {CGroupSKB, AttachCGroupInetIngress, 0},
-		"cgroup_skb/egress":  {CGroupSKB, AttachCGroupInetEgress, 0},
-		"cgroup/dev":         {CGroupDevice, AttachCGroupDevice, 0},
-		"cgroup/skb":         {CGroupSKB, AttachNone, 0},
-		"cgroup/sock":        {CGroupSock, AttachCGroupInetSockCreate, 0},
-		"cgroup/post_bind4":  {CGroupSock, AttachCGroupInet4PostBind, 0},
-		"cgroup/post_bind6":  {CGroupSock, AttachCGroupInet6PostBind, 0},
-		"cgroup/bind4":       {CGroupSockAddr, AttachCGroupInet4Bind, 0},
-		"cgroup/bind6":       {CGroupSockAddr, AttachCGroupInet6Bind, 0},
-		"cgroup/connect4":    {CGroupSockAddr, AttachCGroupInet4Connect, 0},
-		"cgroup/connect6":    {CGroupSockAddr, AttachCGroupInet6Connect, 0},
-		"cgroup/sendmsg4":    {CGroupSockAddr, AttachCGroupUDP4Sendmsg, 0},
-		"cgroup/sendmsg6":    {CGroupSockAddr, AttachCGroupUDP6Sendmsg, 0},
-		"cgroup/recvmsg4":    {CGroupSockAddr, AttachCGroupUDP4Recvmsg, 0},
-		"cgroup/recvmsg6":    {CGroupSockAddr, AttachCGroupUDP6Recvmsg, 0},
-		"cgroup/sysctl":      {CGroupSysctl, AttachCGroupSysctl, 0},
-		"cgroup/getsockopt":  {CGroupSockopt, AttachCGroupGetsockopt, 0},
-		"cgroup/setsockopt":  {CGroupSockopt, AttachCGroupSetsockopt, 0},
-		"classifier":         {SchedCLS, AttachNone, 0},
-		"action":             {SchedACT, AttachNone, 0},
+		"lsm_cgroup/":           {LSM, AttachLSMCgroup, 0},
+		"sk_reuseport":          {SkReuseport, AttachSkReuseportSelect, 0},
+		"sk_reuseport/migrate":  {SkReuseport, AttachSkReuseportSelectOrMigrate, 0},
+		"struct_ops/":           {StructOps, AttachNone, 0},
+		"syscall":               {Syscall, AttachNone, unix.BPF_F_SLEEPABLE},
+		"netfilter":             {Netfilter, AttachNetfilter, 0},
+
+		"cgroup_skb/ingress":  {CGroupSKB, AttachCGroupInetIngress, 0},
+		"cgroup_skb/egress":   {CGroupSKB, AttachCGroupInetEgress, 0},
+		"cgroup/dev":          {CGroupDevice, AttachCGroupDevice, 0},
+		"cgroup/skb":          {CGroupSKB, AttachNone, 0},
+		"cgroup/sock":         {CGroupSock, AttachCGroupInetSockCreate, 0},
+		"cgroup/sock_create":  {CGroupSock, AttachCGroupInetSockCreate, 0},
+		"cgroup/sock_release": {CGroupSock, AttachCgroupInetSockRelease, 0},
+		"cgroup/post_bind4":   {CGroupSock, AttachCGroupInet4PostBind, 0},
+		"cgroup/post_bind6":   {CGroupSock, AttachCGroupInet6PostBind, 0},
+		"cgroup/bind4":        {CGroupSockAddr, AttachCGroupInet4Bind, 0},
+		"cgroup/bind6":        {CGroupSockAddr, AttachCGroupInet6Bind, 0},
+		"cgroup/connect4":     {CGroupSockAddr, AttachCGroupInet4Connect, 0},
+		"cgroup/connect6":     {CGroupSockAddr, AttachCGroupInet6Connect, 0},
+		"cgroup/sendmsg4":     {CGroupSockAddr, AttachCGroupUDP4Sendmsg, 0},
+		"cgroup/sendmsg6":     {CGroupSockAddr, AttachCGroupUDP6Sendmsg, 0},
+		"cgroup/recvmsg4":     {CGroupSockAddr, AttachCGroupUDP4Recvmsg, 0},
+		"cgroup/recvmsg6":     {CGroupSockAddr, AttachCGroupUDP6Recvmsg, 0},
+		"cgroup/sysctl":       {CGroupSysctl, AttachCGroupSysctl, 0},
+		"cgroup/getsockopt":   {CGroupSockopt, AttachCGroupGetsockopt, 0},
+		"cgroup/setsockopt":   {CGroupSockopt, AttachCGroupSetsockopt, 0},
+		"classifier":          {SchedCLS, AttachNone, 0},
+		"action":              {SchedACT, AttachNone, 0},
+		"tc":                  {SchedCLS, AttachNone, 0},
+		"tc/ingress":          {SchedCLS, AttachTCXIngress, 0},
+		"tc/egress":           {SchedCLS, AttachTCXEgress, 0},
+		"tcx/ingress":         {SchedCLS, AttachTCXIngress, 0},
+		"tcx/egress":          {SchedCLS, AttachTCXEgress, 0},
 
 		"cgroup/getsockname4": {CGroupSockAddr, AttachCgroupInet4GetSockname, 0},
 		"cgroup/getsockname6": {CGroupSockAddr, AttachCgroupInet6GetSockname, 0},
@@ -1027,19 +1152,34 @@ func getProgType(sectionName string) (ProgramType, AttachType, uint32, string) {
 		"cgroup/getpeername6": {CGroupSockAddr, AttachCgroupInet6GetPeername, 0},
 	}
 
-	for prefix, t := range types {
-		if !strings.HasPrefix(sectionName, prefix) {
+	// These are a prefix of unrelated section names, like tc and
+	// tcx/ingress, and must match exactly.
+	exact := map[string]bool{
+		"tc": true,
+	}
+
+	// Some prefixes are prefixes of each other, like xdp and xdp/devmap.
+	// Use the longest match.
+	var match string
+	for prefix := range types {
+		if exact[prefix] && sectionName != prefix {
 			continue
 		}
-
-		if !strings.HasSuffix(prefix, "/") {
-			return t.progType, t.attachType, t.progFlags, ""
+		if strings.HasPrefix(sectionName, prefix) && len(prefix) > len(match) {
+			match = prefix
 		}
+	}
+
+	t, ok := types[match]
+	if !ok {
+		return UnspecifiedProgram, AttachNone, 0, ""
+	}
 
-		return t.progType, t.attachType, t.progFlags, sectionName[len(prefix):]
+	if !strings.HasSuffix(match, "/") {
+		return t.progType, t.attachType, t.progFlags, ""
 	}
 
-	return UnspecifiedProgram, AttachNone, 0, ""
+	return t.progType, t.attachType, t.progFlags, sectionName[len(match):]
 }
 
 func (ec *elfCode) loadRelocations(sec *elf.Section, symbols []elf.Symbol) (map[uint64]elf.Symbol, error) {
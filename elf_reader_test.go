@@ -6,6 +6,7 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -13,8 +14,8 @@ import (
 	"syscall"
 	"testing"
 
+	"github.com/cilium/ebpf/btf"
 	"github.com/cilium/ebpf/internal"
-	"github.com/cilium/ebpf/internal/btf"
 	"github.com/cilium/ebpf/internal/testutils"
 	"github.com/cilium/ebpf/internal/unix"
 
@@ -206,6 +207,39 @@ func TestLoadCollectionSpec(t *testing.T) {
 	})
 }
 
+func TestLoadCollectionSpecFromBytes(t *testing.T) {
+	testutils.Files(t, testutils.Glob(t, "testdata/raw_tracepoint-*.elf"), func(t *testing.T, file string) {
+		want, err := LoadCollectionSpec(file)
+		if err != nil {
+			t.Fatal("Can't parse ELF:", err)
+		}
+
+		buf, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		have, err := LoadCollectionSpecFromBytes(buf)
+		if err != nil {
+			t.Fatal("Can't parse ELF from bytes:", err)
+		}
+
+		if len(have.Programs) != len(want.Programs) {
+			t.Fatalf("Expected %d programs, got %d", len(want.Programs), len(have.Programs))
+		}
+
+		for name, wantProg := range want.Programs {
+			haveProg := have.Programs[name]
+			if haveProg == nil {
+				t.Fatalf("Missing program %s", name)
+			}
+			if diff := cmp.Diff(wantProg.Instructions, haveProg.Instructions); diff != "" {
+				t.Errorf("Instructions of %s differ (-want +got):\n%s", name, diff)
+			}
+		}
+	})
+}
+
 func TestDataSections(t *testing.T) {
 	file := fmt.Sprintf("testdata/loader-%s.elf", internal.ClangEndian)
 	coll, err := LoadCollectionSpec(file)
@@ -521,6 +555,61 @@ func TestIPRoute2Compat(t *testing.T) {
 	})
 }
 
+func TestLoadStructOps(t *testing.T) {
+	testutils.Files(t, testutils.Glob(t, "testdata/struct_ops-*.elf"), func(t *testing.T, file string) {
+		spec, err := LoadCollectionSpec(file)
+		if err != nil {
+			t.Fatal("Can't parse ELF:", err)
+		}
+
+		ops, ok := spec.StructOps["my_ops"]
+		if !ok {
+			t.Fatal("Struct my_ops not found")
+		}
+
+		if ops.TypeName != "tcp_congestion_ops" {
+			t.Error("Unexpected type name", ops.TypeName)
+		}
+
+		wantProgs := map[string]string{
+			"ssthresh":   "my_ssthresh",
+			"cong_avoid": "my_cong_avoid",
+			"undo_cwnd":  "my_undo_cwnd",
+		}
+		if diff := cmp.Diff(wantProgs, ops.Programs); diff != "" {
+			t.Errorf("Programs don't match (-want +got):\n%s", diff)
+		}
+
+		if len(ops.Data) != 48 {
+			t.Fatal("Expected 48 bytes of data, got", len(ops.Data))
+		}
+		if flags := spec.ByteOrder.Uint32(ops.Data); flags != 1 {
+			t.Error("Expected flags to be 1, got", flags)
+		}
+		if !isZero(ops.Data[8:32]) {
+			t.Error("Function pointers should be zero, got", ops.Data[8:32])
+		}
+		if name := string(bytes.TrimRight(ops.Data[32:], "\x00")); name != "ebpf_test" {
+			t.Error("Unexpected name", name)
+		}
+
+		for member, name := range wantProgs {
+			prog, ok := spec.Programs[name]
+			if !ok {
+				t.Fatal("Program", name, "not found")
+			}
+
+			if prog.Type != StructOps {
+				t.Errorf("Program %s has type %s instead of StructOps", name, prog.Type)
+			}
+
+			if want := "tcp_congestion_ops:" + member; prog.AttachTo != want {
+				t.Errorf("Program %s has AttachTo %q instead of %q", name, prog.AttachTo, want)
+			}
+		}
+	})
+}
+
 var (
 	elfPath    = flag.String("elfs", os.Getenv("KERNEL_SELFTESTS"), "`Path` containing libbpf-compatible ELFs (defaults to $KERNEL_SELFTESTS)")
 	elfPattern = flag.String("elf-pattern", "*.o", "Glob `pattern` for object files that should be tested")
@@ -755,6 +844,60 @@ func TestGetProgType(t *testing.T) {
 			At: AttachLSMMac,
 			To: "file_ioctl",
 		},
+		"syscall": {
+			Pt: Syscall,
+			At: AttachNone,
+			Fl: unix.BPF_F_SLEEPABLE,
+		},
+		"netfilter": {
+			Pt: Netfilter,
+			At: AttachNetfilter,
+		},
+		"xdp/devmap": {
+			Pt: XDP,
+			At: AttachXDPDevMap,
+		},
+		"sk_skb/stream_parser": {
+			Pt: SkSKB,
+			At: AttachSkSKBStreamParser,
+		},
+		"sk_skb/stream_verdict": {
+			Pt: SkSKB,
+			At: AttachSkSKBStreamVerdict,
+		},
+		"sk_msg": {
+			Pt: SkMsg,
+			At: AttachSkMsgVerdict,
+		},
+		"cgroup/sock_release": {
+			Pt: CGroupSock,
+			At: AttachCgroupInetSockRelease,
+		},
+		"tcx/egress": {
+			Pt: SchedCLS,
+			At: AttachTCXEgress,
+		},
+		"tc": {
+			Pt: SchedCLS,
+			At: AttachNone,
+		},
+		"tc/ingress": {
+			Pt: SchedCLS,
+			At: AttachTCXIngress,
+		},
+		"tcx/foo": {
+			Pt: UnspecifiedProgram,
+			At: AttachNone,
+		},
+		"tc_foo": {
+			Pt: UnspecifiedProgram,
+			At: AttachNone,
+		},
+		"kprobe.multi/vfs_*": {
+			Pt: Kprobe,
+			At: AttachTraceKprobeMulti,
+			To: "vfs_*",
+		},
 	}
 
 	for section, want := range testcases {
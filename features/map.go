@@ -63,14 +63,14 @@ func createMapTypeAttr(mt ebpf.MapType) *internal.BPFMapCreateAttr {
 	case ebpf.Queue, ebpf.Stack:
 		// keySize needs to be 0, see alloc_check for queue and stack maps
 		keySize = 0
-	case ebpf.RingBuf:
+	case ebpf.RingBuf, ebpf.UserRingbuf:
 		// keySize and valueSize need to be 0
 		// maxEntries needs to be power of 2 and PAGE_ALIGNED
 		// checked at allocation time
 		keySize = 0
 		valueSize = 0
 		maxEntries = uint32(os.Getpagesize())
-	case ebpf.SkStorage, ebpf.InodeStorage, ebpf.TaskStorage:
+	case ebpf.SkStorage, ebpf.InodeStorage, ebpf.TaskStorage, ebpf.CgrpStorage:
 		// maxEntries needs to be 0
 		// BPF_F_NO_PREALLOC needs to be set
 		// btf* fields need to be set
@@ -80,6 +80,9 @@ func createMapTypeAttr(mt ebpf.MapType) *internal.BPFMapCreateAttr {
 		btfKeyTypeID = 1   // BTF_KIND_INT
 		btfValueTypeID = 3 // BTF_KIND_ARRAY
 		btfFd = ^uint32(0)
+	case ebpf.BloomFilter:
+		// keySize needs to be 0, see alloc_check for bloom filter maps
+		keySize = 0
 	}
 
 	return &internal.BPFMapCreateAttr{
@@ -179,7 +182,7 @@ func isMapOfMaps(mt ebpf.MapType) bool {
 
 func isStorageMap(mt ebpf.MapType) bool {
 	switch mt {
-	case ebpf.SkStorage, ebpf.InodeStorage, ebpf.TaskStorage:
+	case ebpf.SkStorage, ebpf.InodeStorage, ebpf.TaskStorage, ebpf.CgrpStorage:
 		return true
 	}
 
@@ -40,6 +40,9 @@ var mapTypeMinVersion = map[ebpf.MapType]string{
 	ebpf.RingBuf:             "5.8",
 	ebpf.InodeStorage:        "5.10",
 	ebpf.TaskStorage:         "5.11",
+	ebpf.BloomFilter:         "5.16",
+	ebpf.UserRingbuf:         "6.1",
+	ebpf.CgrpStorage:         "6.2",
 }
 
 func TestHaveMapType(t *testing.T) {
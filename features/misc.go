@@ -0,0 +1,49 @@
+package features
+
+import (
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/internal"
+)
+
+// HaveBatchOps probes the running kernel for batch map operations like
+// BPF_MAP_LOOKUP_BATCH.
+//
+// See the package documentation for the meaning of the error return value.
+//
+// Map.BatchLookup and friends already fall back to operating on single
+// elements if batch operations are unavailable.
+func HaveBatchOps() error {
+	return internal.HaveBatchAPI()
+}
+
+// HaveKernelVersion checks that the running kernel is at least version
+// major.minor.patch.
+//
+// Returns nil if it is, and an error wrapping ebpf.ErrNotSupported if the
+// kernel is older. The version is read once and cached.
+//
+// Distributions often backport BPF features to older kernels, so probing
+// for a feature directly is more reliable than checking the version.
+func HaveKernelVersion(major, minor, patch int) error {
+	for _, v := range []int{major, minor, patch} {
+		if v < 0 || v > math.MaxUint16 {
+			return os.ErrInvalid
+		}
+	}
+
+	running, err := internal.KernelVersion()
+	if err != nil {
+		return fmt.Errorf("detect kernel version: %w", err)
+	}
+
+	want := internal.Version{uint16(major), uint16(minor), uint16(patch)}
+	if running.Less(want) {
+		return fmt.Errorf("kernel %s is older than %s: %w", running, want, ebpf.ErrNotSupported)
+	}
+
+	return nil
+}
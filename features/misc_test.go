@@ -0,0 +1,38 @@
+package features
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/testutils"
+)
+
+func TestHaveBatchOps(t *testing.T) {
+	testutils.CheckFeatureTest(t, HaveBatchOps)
+}
+
+func TestHaveKernelVersion(t *testing.T) {
+	running, err := internal.KernelVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := HaveKernelVersion(int(running[0]), int(running[1]), int(running[2])); err != nil {
+		t.Error("Running kernel isn't at least its own version:", err)
+	}
+
+	if err := HaveKernelVersion(3, 0, 0); err != nil {
+		t.Error("Running kernel is older than 3.0:", err)
+	}
+
+	if err := HaveKernelVersion(int(running[0])+1, 0, 0); !errors.Is(err, ebpf.ErrNotSupported) {
+		t.Error("Expected ErrNotSupported for a newer kernel, got", err)
+	}
+
+	if err := HaveKernelVersion(-1, 0, 0); !errors.Is(err, os.ErrInvalid) {
+		t.Error("Expected os.ErrInvalid for a negative version, got", err)
+	}
+}
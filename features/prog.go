@@ -27,7 +27,10 @@ type progCache struct {
 }
 
 func createProgLoadAttr(pt ebpf.ProgramType) (*internal.BPFProgLoadAttr, error) {
-	var expectedAttachType ebpf.AttachType
+	var (
+		expectedAttachType ebpf.AttachType
+		progFlags          uint32
+	)
 
 	insns := asm.Instructions{
 		asm.LoadImm(asm.R0, 0, asm.DWord),
@@ -51,6 +54,11 @@ func createProgLoadAttr(pt ebpf.ProgramType) (*internal.BPFProgLoadAttr, error)
 		expectedAttachType = ebpf.AttachCGroupGetsockopt
 	case ebpf.SkLookup:
 		expectedAttachType = ebpf.AttachSkLookup
+	case ebpf.Syscall:
+		// Syscall programs must be sleepable.
+		progFlags = unix.BPF_F_SLEEPABLE
+	case ebpf.Netfilter:
+		expectedAttachType = ebpf.AttachNetfilter
 	default:
 		expectedAttachType = ebpf.AttachNone
 	}
@@ -69,6 +77,7 @@ func createProgLoadAttr(pt ebpf.ProgramType) (*internal.BPFProgLoadAttr, error)
 		Instructions:       instructions,
 		InsCount:           uint32(len(bytecode) / asm.InstructionSize),
 		ExpectedAttachType: uint32(expectedAttachType),
+		ProgFlags:          progFlags,
 		License:            internal.NewStringPointer("GPL"),
 		KernelVersion:      kv,
 	}, nil
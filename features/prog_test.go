@@ -41,6 +41,8 @@ var progTypeMinVersion = map[ebpf.ProgramType]string{
 	ebpf.Extension:             "5.6",
 	ebpf.LSM:                   "5.7",
 	ebpf.SkLookup:              "5.9",
+	ebpf.Syscall:               "5.14",
+	ebpf.Netfilter:             "6.4",
 }
 
 func TestHaveProgType(t *testing.T) {
@@ -2,17 +2,23 @@ package ebpf
 
 import (
 	"bufio"
+	"bytes"
+	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
+	"unsafe"
 
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/btf"
 	"github.com/cilium/ebpf/internal"
-	"github.com/cilium/ebpf/internal/btf"
+	"github.com/cilium/ebpf/internal/unix"
 )
 
 // MapInfo describes a map.
@@ -25,6 +31,15 @@ type MapInfo struct {
 	Flags      uint32
 	// Name as supplied by user space at load time.
 	Name string
+	// Index of the network interface the map is offloaded to.
+	ifindex uint32
+	// BTF for the map.
+	btf btf.ID
+	// Type IDs of key and value in the map's BTF.
+	btfKeyTypeID   uint32
+	btfValueTypeID uint32
+	// Bytes of memory locked by the map.
+	memlock uint64
 }
 
 func newMapInfoFromFd(fd *internal.FD) (*MapInfo, error) {
@@ -36,16 +51,28 @@ func newMapInfoFromFd(fd *internal.FD) (*MapInfo, error) {
 		return nil, err
 	}
 
-	return &MapInfo{
-		MapType(info.map_type),
-		MapID(info.id),
-		info.key_size,
-		info.value_size,
-		info.max_entries,
-		info.map_flags,
+	mi := &MapInfo{
+		Type:       MapType(info.map_type),
+		id:         MapID(info.id),
+		KeySize:    info.key_size,
+		ValueSize:  info.value_size,
+		MaxEntries: info.max_entries,
+		Flags:      info.map_flags,
 		// name is available from 4.15.
-		internal.CString(info.name[:]),
-	}, nil
+		Name: internal.CString(info.name[:]),
+		// ifindex is available from 4.16.
+		ifindex: info.ifindex,
+		// BTF information is available from 4.18.
+		btf:            btf.ID(info.btf_id),
+		btfKeyTypeID:   info.btf_key_type_id,
+		btfValueTypeID: info.btf_value_type_id,
+	}
+
+	// memlock is only available via fdinfo. It's optional, so ignore
+	// errors.
+	_ = scanFdInfo(fd, map[string]interface{}{"memlock": &mi.memlock})
+
+	return mi, nil
 }
 
 func newMapInfoFromProc(fd *internal.FD) (*MapInfo, error) {
@@ -60,6 +87,9 @@ func newMapInfoFromProc(fd *internal.FD) (*MapInfo, error) {
 	if err != nil {
 		return nil, err
 	}
+
+	_ = scanFdInfo(fd, map[string]interface{}{"memlock": &mi.memlock})
+
 	return &mi, nil
 }
 
@@ -72,6 +102,68 @@ func (mi *MapInfo) ID() (MapID, bool) {
 	return mi.id, mi.id > 0
 }
 
+// MemoryLocked returns the number of bytes of memory used by the map, as
+// reported by the kernel. Before 5.11 this counts against RLIMIT_MEMLOCK.
+//
+// Available from 4.10.
+//
+// The bool return value indicates whether this optional field is available.
+func (mi *MapInfo) MemoryLocked() (uint64, bool) {
+	return mi.memlock, mi.memlock > 0
+}
+
+// Capacity estimates the memory required to store MaxEntries keys and
+// values.
+//
+// It doesn't account for per-element overhead or additional copies of
+// per-CPU values, so the actual usage is usually higher. Use it if
+// MemoryLocked isn't available.
+func (mi *MapInfo) Capacity() uint64 {
+	return (uint64(mi.KeySize) + uint64(mi.ValueSize)) * uint64(mi.MaxEntries)
+}
+
+// Ifindex returns the index of the network interface the map is offloaded to.
+//
+// Available from 4.16.
+//
+// The bool return value indicates whether this optional field is available and
+// populated. (The field may be available but not populated if the kernel
+// supports the field but the map isn't bound to a network device.)
+func (mi *MapInfo) Ifindex() (uint32, bool) {
+	return mi.ifindex, mi.ifindex > 0
+}
+
+// BTFID returns the BTF ID associated with the map.
+//
+// Available from 4.18.
+//
+// The bool return value indicates whether this optional field is available and
+// populated. (The field may be available but not populated if the kernel
+// supports the field but the map was created without BTF information.)
+func (mi *MapInfo) BTFID() (btf.ID, bool) {
+	return mi.btf, mi.btf > 0
+}
+
+// BTFKeyTypeID returns the ID of the key type in the map's BTF.
+//
+// Available from 4.18.
+//
+// The bool return value indicates whether this optional field is available and
+// populated. See BTFID().
+func (mi *MapInfo) BTFKeyTypeID() (uint32, bool) {
+	return mi.btfKeyTypeID, mi.btf > 0
+}
+
+// BTFValueTypeID returns the ID of the value type in the map's BTF.
+//
+// Available from 4.18.
+//
+// The bool return value indicates whether this optional field is available and
+// populated. See BTFID().
+func (mi *MapInfo) BTFValueTypeID() (uint32, bool) {
+	return mi.btfValueTypeID, mi.btf > 0
+}
+
 // programStats holds statistics of a program.
 type programStats struct {
 	// Total accumulated runtime of the program ins ns.
@@ -92,14 +184,27 @@ type ProgramInfo struct {
 	btf btf.ID
 	// IDS map ids related to program.
 	ids []MapID
+	// Time since boot at which the program was loaded.
+	loadTime time.Duration
+	// Translated instructions of the program.
+	insns []byte
+	// Native code generated by the JIT.
+	jitedInsns []byte
+	jitedErr   error
+	// Kernel addresses and lengths of the JITed functions.
+	jitedKsyms    []uint64
+	jitedFuncLens []uint32
+	// Whether the license is compatible with the GPL.
+	gpl bool
+	// Bytes of memory locked by the program.
+	memlock uint64
 
 	stats *programStats
 }
 
 func newProgramInfoFromFd(fd *internal.FD) (*ProgramInfo, error) {
-	const defaultNumMaps = 10
-	mapIds := make([]MapID, defaultNumMaps)
-	info, err := bpfGetProgInfoByFD(fd, mapIds)
+	var info bpfProgInfo
+	err := bpfGetProgInfoByFD(fd, &info)
 	if errors.Is(err, syscall.EINVAL) {
 		return newProgramInfoFromProc(fd)
 	}
@@ -107,15 +212,7 @@ func newProgramInfoFromFd(fd *internal.FD) (*ProgramInfo, error) {
 		return nil, err
 	}
 
-	if info.nr_map_ids > defaultNumMaps {
-		mapIds = make([]MapID, info.nr_map_ids)
-		info, err = bpfGetProgInfoByFD(fd, mapIds)
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	return &ProgramInfo{
+	pi := ProgramInfo{
 		Type: ProgramType(info.prog_type),
 		id:   ProgramID(info.id),
 		// tag is available if the kernel supports BPF_PROG_GET_INFO_BY_FD.
@@ -123,12 +220,87 @@ func newProgramInfoFromFd(fd *internal.FD) (*ProgramInfo, error) {
 		// name is available from 4.15.
 		Name: internal.CString(info.name[:]),
 		btf:  btf.ID(info.btf_id),
-		ids:  mapIds[:info.nr_map_ids],
+		ids:  make([]MapID, info.nr_map_ids),
+		// load_time is available from 4.15.
+		loadTime: time.Duration(info.load_time),
+		// gpl_compatible is available from 4.18.
+		gpl: gplCompatible(info.gpl_compatible),
 		stats: &programStats{
 			runtime:  time.Duration(info.run_time_ns),
 			runCount: info.run_cnt,
 		},
-	}, nil
+	}
+
+	// memlock is only available via fdinfo. It's optional, so ignore
+	// errors.
+	_ = scanFdInfo(fd, map[string]interface{}{"memlock": &pi.memlock})
+
+	// The first call returned the lengths of variable length fields.
+	// Retrieve them using a clean struct, since the kernel returns EFAULT
+	// for lengths without a matching buffer.
+	var info2 bpfProgInfo
+	if info.nr_map_ids > 0 {
+		info2.nr_map_ids = info.nr_map_ids
+		info2.map_ids = internal.NewPointer(unsafe.Pointer(&pi.ids[0]))
+	}
+
+	// xlated_prog_len is zero if the caller lacks CAP_SYS_ADMIN.
+	if info.xlated_prog_len > 0 {
+		pi.insns = make([]byte, info.xlated_prog_len)
+		info2.xlated_prog_len = info.xlated_prog_len
+		info2.xlated_prog_insns = internal.NewSlicePointer(pi.insns)
+	}
+
+	// nr_jited_ksyms and nr_jited_func_lens are zero if the JIT is disabled
+	// or if the caller may not see kernel addresses.
+	if info.nr_jited_ksyms > 0 {
+		pi.jitedKsyms = make([]uint64, info.nr_jited_ksyms)
+		info2.nr_jited_ksyms = info.nr_jited_ksyms
+		info2.jited_ksyms = internal.NewPointer(unsafe.Pointer(&pi.jitedKsyms[0]))
+	}
+
+	if info.nr_jited_func_lens > 0 {
+		pi.jitedFuncLens = make([]uint32, info.nr_jited_func_lens)
+		info2.nr_jited_func_lens = info.nr_jited_func_lens
+		info2.jited_func_lens = internal.NewPointer(unsafe.Pointer(&pi.jitedFuncLens[0]))
+	}
+
+	if info.nr_map_ids > 0 || info.xlated_prog_len > 0 ||
+		info.nr_jited_ksyms > 0 || info.nr_jited_func_lens > 0 {
+		if err := bpfGetProgInfoByFD(fd, &info2); err != nil {
+			return nil, err
+		}
+	}
+
+	// jited_prog_len is zero if the JIT is disabled or if the caller lacks
+	// CAP_SYS_ADMIN.
+	if info.jited_prog_len > 0 {
+		pi.jitedInsns, pi.jitedErr = progJITedInsns(fd, info.jited_prog_len)
+	}
+
+	return &pi, nil
+}
+
+// progJITedInsns retrieves the native code of a program.
+//
+// The code is retrieved separately from the other variable length fields,
+// so that an error only affects ProgramInfo.JITedInstructions.
+func progJITedInsns(fd *internal.FD, length uint32) ([]byte, error) {
+	var (
+		info bpfProgInfo
+		buf  = make([]byte, length)
+	)
+	info.jited_prog_len = length
+	info.jited_prog_insns = internal.NewSlicePointer(buf)
+	if err := bpfGetProgInfoByFD(fd, &info); err != nil {
+		return nil, fmt.Errorf("get JITed instructions: %w", err)
+	}
+
+	if info.jited_prog_len != length {
+		return nil, fmt.Errorf("JITed instructions changed size from %d to %d bytes", length, info.jited_prog_len)
+	}
+
+	return buf, nil
 }
 
 func newProgramInfoFromProc(fd *internal.FD) (*ProgramInfo, error) {
@@ -147,9 +319,21 @@ func newProgramInfoFromProc(fd *internal.FD) (*ProgramInfo, error) {
 		return nil, err
 	}
 
+	_ = scanFdInfo(fd, map[string]interface{}{"memlock": &info.memlock})
+
 	return &info, nil
 }
 
+// gplCompatible decodes the gpl_compatible bitfield of bpf_prog_info. It
+// occupies the first bit of a u32, which is the most significant bit on
+// big endian architectures.
+func gplCompatible(field uint32) bool {
+	if internal.NativeEndian == binary.BigEndian {
+		return field&(1<<31) != 0
+	}
+	return field&1 != 0
+}
+
 // ID returns the program ID.
 //
 // Available from 4.13.
@@ -159,6 +343,24 @@ func (pi *ProgramInfo) ID() (ProgramID, bool) {
 	return pi.id, pi.id > 0
 }
 
+// GPL returns true if the program was loaded with a license compatible
+// with the GPL, which allows it to call GPL-only helpers.
+//
+// Available from 4.18. Always returns false on older kernels.
+func (pi *ProgramInfo) GPL() bool {
+	return pi.gpl
+}
+
+// MemoryLocked returns the number of bytes of memory locked by the
+// program. Before 5.11 this counts against RLIMIT_MEMLOCK.
+//
+// Available from 4.10.
+//
+// The bool return value indicates whether this optional field is available.
+func (pi *ProgramInfo) MemoryLocked() (uint64, bool) {
+	return pi.memlock, pi.memlock > 0
+}
+
 // BTFID returns the BTF ID associated with the program.
 //
 // Available from 5.0.
@@ -199,6 +401,113 @@ func (pi *ProgramInfo) MapIDs() ([]MapID, bool) {
 	return pi.ids, pi.ids != nil
 }
 
+// Instructions returns the instructions of the program as translated by the
+// verifier.
+//
+// The translated instructions can't be loaded into the kernel again. For
+// example, map references contain the ID of the map instead of a file
+// descriptor. They are useful to inspect a loaded program.
+//
+// Available from 4.13. Requires CAP_SYS_ADMIN.
+//
+// The bool return value indicates whether this optional field is available.
+func (pi *ProgramInfo) Instructions() (asm.Instructions, bool, error) {
+	if len(pi.insns) == 0 {
+		return nil, false, nil
+	}
+
+	var (
+		r      = bytes.NewReader(pi.insns)
+		insns  asm.Instructions
+		offset uint64
+	)
+	for {
+		var ins asm.Instruction
+		n, err := ins.Unmarshal(r, internal.NativeEndian)
+		if err == io.EOF {
+			return insns, true, nil
+		}
+		if err != nil {
+			return nil, true, fmt.Errorf("offset %d: %w", offset, err)
+		}
+
+		insns = append(insns, ins)
+		offset += n
+	}
+}
+
+// JITedInstructions returns the native code generated for the program by
+// the JIT compiler.
+//
+// Available from 4.13. Requires CAP_SYS_ADMIN and an enabled JIT.
+//
+// The bool return value indicates whether this optional field is available.
+// An error is returned if the code couldn't be retrieved.
+func (pi *ProgramInfo) JITedInstructions() ([]byte, bool, error) {
+	if pi.jitedErr != nil {
+		return nil, true, pi.jitedErr
+	}
+
+	if len(pi.jitedInsns) == 0 {
+		return nil, false, nil
+	}
+
+	return pi.jitedInsns, true, nil
+}
+
+// JITedKsyms returns the kernel addresses of the JITed functions of the
+// program, starting with the main function.
+//
+// Available from 4.18. Requires CAP_SYS_ADMIN and an enabled JIT.
+//
+// The bool return value indicates whether this optional field is available.
+func (pi *ProgramInfo) JITedKsyms() ([]uint64, bool) {
+	return pi.jitedKsyms, len(pi.jitedKsyms) > 0
+}
+
+// JITedFuncLengths returns the length in bytes of each JITed function of the
+// program, in the same order as JITedKsyms.
+//
+// Available from 4.18. Requires CAP_SYS_ADMIN and an enabled JIT.
+//
+// The bool return value indicates whether this optional field is available.
+func (pi *ProgramInfo) JITedFuncLengths() ([]uint32, bool) {
+	return pi.jitedFuncLens, len(pi.jitedFuncLens) > 0
+}
+
+// LoadedAt returns the time at which the program was loaded.
+//
+// The kernel records the load time relative to boot. It is converted to wall
+// clock time using the current offset between CLOCK_REALTIME and
+// CLOCK_BOOTTIME, so the result is subject to adjustments of the system clock.
+//
+// Available from 4.15.
+//
+// The bool return value indicates whether this optional field is available.
+func (pi *ProgramInfo) LoadedAt() (time.Time, bool) {
+	if pi.loadTime == 0 {
+		return time.Time{}, false
+	}
+
+	bootTime, err := bootTime()
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return bootTime.Add(pi.loadTime), true
+}
+
+// bootTime returns the wall clock time at which the system was booted.
+func bootTime() (time.Time, error) {
+	var ts unix.Timespec
+	if err := unix.ClockGettime(unix.CLOCK_BOOTTIME, &ts); err != nil {
+		return time.Time{}, fmt.Errorf("can't get boot time: %w", err)
+	}
+
+	sinceBoot := time.Duration(ts.Sec)*time.Second + time.Duration(ts.Nsec)
+	return time.Now().Add(-sinceBoot), nil
+}
+
 func scanFdInfo(fd *internal.FD, fields map[string]interface{}) error {
 	raw, err := fd.Value()
 	if err != nil {
@@ -260,15 +569,68 @@ func scanFdInfoReader(r io.Reader, fields map[string]interface{}) error {
 //
 // Collecting statistics can have an impact on the performance.
 //
+// Calling EnableStats multiple times is safe, statistics stay enabled
+// until all returned io.Closers have been closed.
+//
 // Requires at least 5.8.
 func EnableStats(which uint32) (io.Closer, error) {
-	attr := internal.BPFEnableStatsAttr{
-		StatsType: which,
+	enabledStats.Lock()
+	defer enabledStats.Unlock()
+
+	if enabledStats.fds == nil {
+		enabledStats.fds = make(map[uint32]*statsFD)
 	}
 
-	fd, err := internal.BPFEnableStats(&attr)
-	if err != nil {
-		return nil, err
+	sfd := enabledStats.fds[which]
+	if sfd == nil {
+		attr := internal.BPFEnableStatsAttr{
+			StatsType: which,
+		}
+
+		fd, err := internal.BPFEnableStats(&attr)
+		if err != nil {
+			return nil, err
+		}
+
+		sfd = &statsFD{fd: fd}
+		enabledStats.fds[which] = sfd
 	}
-	return fd, nil
+
+	sfd.refs++
+	return &statsCloser{which: which}, nil
+}
+
+// enabledStats tracks the statistics enabled via EnableStats.
+var enabledStats struct {
+	sync.Mutex
+	fds map[uint32]*statsFD
+}
+
+type statsFD struct {
+	fd   *internal.FD
+	refs int
+}
+
+type statsCloser struct {
+	which uint32
+	once  sync.Once
+}
+
+// Close disables the collection of statistics if no other
+// callers of EnableStats need it anymore.
+func (sc *statsCloser) Close() error {
+	var err error
+	sc.once.Do(func() {
+		enabledStats.Lock()
+		defer enabledStats.Unlock()
+
+		sfd := enabledStats.fds[sc.which]
+		if sfd.refs--; sfd.refs > 0 {
+			return
+		}
+
+		delete(enabledStats.fds, sc.which)
+		err = sfd.fd.Close()
+	})
+	return err
 }
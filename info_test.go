@@ -3,8 +3,10 @@ package ebpf
 import (
 	"errors"
 	"fmt"
+	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/cilium/ebpf/asm"
 	"github.com/cilium/ebpf/internal"
@@ -82,6 +84,51 @@ func TestMapInfoFromProc(t *testing.T) {
 	}
 }
 
+func TestMapInfo(t *testing.T) {
+	hash, err := NewMap(&MapSpec{
+		Type:       Hash,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hash.Close()
+
+	info, err := newMapInfoFromFd(hash.fd)
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal("Can't get map info:", err)
+	}
+
+	if ifindex, ok := info.Ifindex(); ok {
+		t.Error("Expected map not to be bound to an interface, got ifindex", ifindex)
+	}
+
+	if id, ok := info.BTFID(); ok {
+		t.Error("Expected map without BTF, got BTF ID", id)
+	}
+
+	if _, ok := info.BTFKeyTypeID(); ok {
+		t.Error("Expected BTF key type ID to not be available")
+	}
+
+	if _, ok := info.BTFValueTypeID(); ok {
+		t.Error("Expected BTF value type ID to not be available")
+	}
+
+	if _, ok := info.MemoryLocked(); !ok {
+		if v, err := internal.KernelVersion(); err == nil && !v.Less(internal.Version{4, 10, 0}) {
+			t.Error("Expected MemoryLocked to be available")
+		}
+	}
+
+	if capacity := info.Capacity(); capacity != 16 {
+		t.Error("Expected a capacity of 16 bytes, got", capacity)
+	}
+}
+
 func TestProgramInfo(t *testing.T) {
 	prog := createSocketFilter(t)
 	defer prog.Close()
@@ -114,6 +161,91 @@ func TestProgramInfo(t *testing.T) {
 			} else if name == "proc" && ok {
 				t.Error("Expected ID to not be available")
 			}
+
+			if name == "proc" {
+				if _, ok, _ := info.Instructions(); ok {
+					t.Error("Expected Instructions to not be available")
+				}
+				return
+			}
+
+			if insns, ok, err := info.Instructions(); err != nil {
+				t.Error("Can't decode instructions:", err)
+			} else if ok && (len(insns) == 0 || insns[len(insns)-1].OpCode != asm.Return().OpCode) {
+				t.Error("Expected instructions to end with exit, got", insns)
+			}
+
+			jited, ok, err := info.JITedInstructions()
+			if err != nil {
+				t.Error("Can't get JITed instructions:", err)
+			}
+			if ok && len(jited) == 0 {
+				t.Error("Expected JITed instructions to not be empty")
+			}
+
+			ksyms, ok := info.JITedKsyms()
+			if lens, lensOK := info.JITedFuncLengths(); ok && lensOK && len(ksyms) != len(lens) {
+				t.Errorf("Expected one function length per ksym, got %d and %d", len(lens), len(ksyms))
+			}
+
+			if loadedAt, ok := info.LoadedAt(); ok {
+				if since := time.Since(loadedAt); since < 0 || since > time.Minute {
+					t.Error("Expected program to be loaded recently, got", loadedAt)
+				}
+			} else if v, err := internal.KernelVersion(); err == nil && !v.Less(internal.Version{4, 15, 0}) {
+				t.Error("Expected LoadedAt to be available")
+			}
+		})
+	}
+}
+
+func TestProgramInfoGPL(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "4.18", "gpl_compatible in bpf_prog_info")
+
+	for license, want := range map[string]bool{
+		"MIT":          false,
+		"Dual MIT/GPL": true,
+	} {
+		spec := socketFilterSpec.Copy()
+		spec.License = license
+
+		prog, err := NewProgram(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer prog.Close()
+
+		info, err := prog.Info()
+		if err != nil {
+			t.Fatal("Can't get program info:", err)
+		}
+
+		if info.GPL() != want {
+			t.Errorf("Expected GPL() to be %v for license %q", want, license)
+		}
+	}
+}
+
+func TestProgramInfoMemoryLocked(t *testing.T) {
+	prog := createSocketFilter(t)
+	defer prog.Close()
+
+	for name, fn := range map[string]func(*internal.FD) (*ProgramInfo, error){
+		"generic": newProgramInfoFromFd,
+		"proc":    newProgramInfoFromProc,
+	} {
+		t.Run(name, func(t *testing.T) {
+			info, err := fn(prog.fd)
+			testutils.SkipIfNotSupported(t, err)
+			if err != nil {
+				t.Fatal("Can't get program info:", err)
+			}
+
+			if memlock, ok := info.MemoryLocked(); !ok {
+				t.Error("Expected MemoryLocked to be available")
+			} else if memlock%uint64(os.Getpagesize()) != 0 {
+				t.Error("Expected a multiple of the page size, got", memlock)
+			}
 		})
 	}
 }
@@ -189,6 +321,82 @@ func TestStats(t *testing.T) {
 	}
 }
 
+func TestEnableStatsRefcount(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.8", "BPF_ENABLE_STATS")
+
+	prog, err := NewProgram(&ProgramSpec{
+		Type: SocketFilter,
+		Instructions: asm.Instructions{
+			asm.LoadImm(asm.R0, 42, asm.DWord),
+			asm.Return(),
+		},
+		License: "MIT",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prog.Close()
+
+	runCount := func() uint64 {
+		t.Helper()
+
+		if _, _, err := prog.Test(make([]byte, 14)); err != nil {
+			t.Fatal(err)
+		}
+
+		pi, err := prog.Info()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rc, _ := pi.RunCount()
+		return rc
+	}
+
+	// Statistics are global, and may have been enabled via sysctl or by
+	// another process.
+	if runCount() != 0 {
+		t.Skip("Statistics are already enabled outside of the test")
+	}
+
+	first, err := EnableStats(uint32(unix.BPF_STATS_RUN_TIME))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+
+	second, err := EnableStats(uint32(unix.BPF_STATS_RUN_TIME))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+
+	if n := len(enabledStats.fds); n != 1 {
+		t.Fatalf("Expected one stats fd, got %d", n)
+	}
+
+	if err := first.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Closing twice mustn't drop the reference held by second.
+	if err := first.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rc := runCount()
+	if rc == 0 {
+		t.Fatal("Closing one of two closers disables stats")
+	}
+
+	if err := second.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if runCount() != rc {
+		t.Error("Stats are still enabled after closing all closers")
+	}
+}
+
 // BenchmarkStats is a benchmark of TestStats. See testStats for details.
 func BenchmarkStats(b *testing.B) {
 	testutils.SkipOnOldKernel(b, "5.8", "BPF_ENABLE_STATS")
@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"debug/dwarf"
 	"debug/elf"
 	"fmt"
 	"io"
@@ -66,3 +67,19 @@ func (se *SafeELFFile) DynamicSymbols() (syms []elf.Symbol, err error) {
 	syms, err = se.File.DynamicSymbols()
 	return
 }
+
+// DWARF is the safe version of elf.File.DWARF.
+func (se *SafeELFFile) DWARF() (data *dwarf.Data, err error) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+
+		data = nil
+		err = fmt.Errorf("reading ELF DWARF panicked: %s", r)
+	}()
+
+	data, err = se.File.DWARF()
+	return
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/cilium/ebpf/internal/unix"
@@ -16,29 +17,89 @@ import (
 // the log. It is used to check for truncation of the output.
 func ErrorWithLog(err error, log []byte, logErr error) error {
 	logStr := strings.Trim(CString(log), "\t\r\n ")
-	if errors.Is(logErr, unix.ENOSPC) {
-		logStr += " (truncated...)"
+	truncated := errors.Is(logErr, unix.ENOSPC)
+
+	ve := &VerifierError{
+		Cause:     err,
+		Log:       logStr,
+		Line:      -1,
+		Truncated: truncated,
+	}
+
+	for _, line := range strings.Split(logStr, "\n") {
+		if insn, ok := parseInstructionIndex(line); ok {
+			ve.Line = insn
+			// Drop register state printed after the instruction.
+			if i := strings.Index(line, ";"); i > 0 {
+				line = line[:i]
+			}
+			ve.insn = strings.TrimSpace(line)
+			continue
+		}
+
+		// The summary printed by the verifier is not interesting.
+		if line != "" && !strings.HasPrefix(line, "processed ") {
+			ve.Message = line
+		}
+	}
+
+	return ve
+}
+
+// parseInstructionIndex extracts the index from a line like
+// "2: (61) r0 = *(u32 *)(r3 +0)".
+func parseInstructionIndex(line string) (int, bool) {
+	i := strings.Index(line, ": (")
+	if i < 1 {
+		return 0, false
+	}
+
+	insn, err := strconv.Atoi(line[:i])
+	if err != nil {
+		return 0, false
 	}
 
-	return &VerifierError{err, logStr}
+	return insn, true
 }
 
 // VerifierError includes information from the eBPF verifier.
 type VerifierError struct {
-	cause error
-	log   string
+	// Cause is the error returned by the syscall.
+	Cause error
+	// Log is the full output of the verifier.
+	Log string
+	// Line is the index of the last instruction processed by the verifier
+	// before rejecting the program, or -1 if it isn't known.
+	Line int
+	// Message is the last line of the log, which usually explains why the
+	// verifier rejected the program.
+	Message string
+	// Truncated is true if the log was cut off, in which case Message and
+	// Line may not be accurate.
+	Truncated bool
+
+	// The instruction at Line as printed by the verifier.
+	insn string
 }
 
 func (le *VerifierError) Unwrap() error {
-	return le.cause
+	return le.Cause
 }
 
+// Error returns a one line summary of the error, use Log for the full
+// output of the verifier.
 func (le *VerifierError) Error() string {
-	if le.log == "" {
-		return le.cause.Error()
+	msg := le.Cause.Error()
+	if le.insn != "" {
+		msg = fmt.Sprintf("%s: %s", msg, le.insn)
 	}
-
-	return fmt.Sprintf("%s: %s", le.cause, le.log)
+	if le.Message != "" {
+		msg = fmt.Sprintf("%s: %s", msg, le.Message)
+	}
+	if le.Truncated {
+		msg += " (truncated...)"
+	}
+	return msg
 }
 
 // CString turns a NUL / zero terminated byte buffer into a string.
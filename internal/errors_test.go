@@ -0,0 +1,50 @@
+package internal
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cilium/ebpf/internal/unix"
+)
+
+func TestErrorWithLog(t *testing.T) {
+	log := []byte("0: R1=ctx() R10=fp0\n" +
+		"0: (b7) r0 = 0                        ; R0=0\n" +
+		"1: (61) r0 = *(u32 *)(r3 +0)\n" +
+		"R3 !read_ok\n" +
+		"processed 2 insns (limit 1000000) max_states_per_insn 0 total_states 0 peak_states 0 mark_read 0\x00")
+
+	err := ErrorWithLog(unix.EPERM, log, unix.EPERM)
+
+	var ve *VerifierError
+	if !errors.As(err, &ve) {
+		t.Fatal("Error is not a VerifierError")
+	}
+
+	if !errors.Is(err, unix.EPERM) {
+		t.Error("Error doesn't wrap cause")
+	}
+	if ve.Line != 1 {
+		t.Error("Expected line 1, got", ve.Line)
+	}
+	if ve.Message != "R3 !read_ok" {
+		t.Errorf("Unexpected message %q", ve.Message)
+	}
+	if ve.Truncated {
+		t.Error("Log shouldn't be truncated")
+	}
+	if want := "operation not permitted: 1: (61) r0 = *(u32 *)(r3 +0): R3 !read_ok"; err.Error() != want {
+		t.Errorf("Expected %q, got %q", want, err.Error())
+	}
+
+	err = ErrorWithLog(unix.EINVAL, []byte("\x00"), unix.ENOSPC)
+	if !errors.As(err, &ve) {
+		t.Fatal("Error is not a VerifierError")
+	}
+	if ve.Line != -1 {
+		t.Error("Expected line -1 for empty log, got", ve.Line)
+	}
+	if !ve.Truncated {
+		t.Error("Log should be truncated")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/cilium/ebpf/internal/unix"
 )
@@ -15,6 +16,14 @@ func Pin(currentPath, newPath string, fd *FD) error {
 	if currentPath == newPath {
 		return nil
 	}
+	// Check the filesystem before creating any directories, so that a
+	// failed Pin doesn't leave them behind.
+	if err := checkBPFFS(filepath.Dir(newPath)); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+		return fmt.Errorf("create parent directories of %s: %w", newPath, err)
+	}
 	if currentPath == "" {
 		return BPFObjPin(newPath, fd)
 	}
@@ -32,6 +41,30 @@ func Pin(currentPath, newPath string, fd *FD) error {
 	return BPFObjPin(newPath, fd)
 }
 
+// checkBPFFS returns an error if the closest existing ancestor of path
+// isn't on a bpf filesystem.
+func checkBPFFS(path string) error {
+	for {
+		var statfs unix.Statfs_t
+		err := unix.Statfs(path, &statfs)
+		if errors.Is(err, unix.ENOENT) {
+			parent := filepath.Dir(path)
+			if parent == path {
+				return err
+			}
+			path = parent
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if uint64(statfs.Type) != bpfFSType {
+			return fmt.Errorf("%s is not on a bpf filesystem", path)
+		}
+		return nil
+	}
+}
+
 func Unpin(pinnedPath string) error {
 	if pinnedPath == "" {
 		return nil
@@ -3,6 +3,7 @@ package internal
 import (
 	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
 	"runtime"
 	"syscall"
@@ -196,6 +197,42 @@ func BPFObjGet(fileName string, flags uint32) (*FD, error) {
 	return NewFD(uint32(ptr)), nil
 }
 
+// BPFObjectType is the kind of object a BPF file descriptor refers to.
+type BPFObjectType string
+
+const (
+	BPFMapObject  BPFObjectType = "map"
+	BPFProgObject BPFObjectType = "program"
+	BPFLinkObject BPFObjectType = "link"
+)
+
+// BPFObjType determines the kind of object fd refers to.
+//
+// The information is retrieved from procfs, since BPF_OBJ_GET_INFO_BY_FD
+// doesn't indicate which kind of info it returned.
+func BPFObjType(fd *FD) (BPFObjectType, error) {
+	value, err := fd.Value()
+	if err != nil {
+		return "", err
+	}
+
+	target, err := os.Readlink(fmt.Sprintf("/proc/self/fd/%d", value))
+	if err != nil {
+		return "", err
+	}
+
+	switch target {
+	case "anon_inode:bpf-map":
+		return BPFMapObject, nil
+	case "anon_inode:bpf-prog":
+		return BPFProgObject, nil
+	case "anon_inode:bpf_link":
+		return BPFLinkObject, nil
+	default:
+		return "", fmt.Errorf("fd %d: unknown object %s", value, target)
+	}
+}
+
 type bpfObjGetInfoByFDAttr struct {
 	fd      uint32
 	infoLen uint32
@@ -243,6 +280,15 @@ func BPFObjGetFDByID(cmd BPFCmd, id uint32) (*FD, error) {
 // 'A-Za-z0-9_' characters.
 type BPFObjName [unix.BPF_OBJ_NAME_LEN]byte
 
+// TruncateObjName truncates a name to the length the kernel keeps for
+// BPF objects.
+func TruncateObjName(name string) string {
+	if len(name) > unix.BPF_OBJ_NAME_LEN-1 {
+		return name[:unix.BPF_OBJ_NAME_LEN-1]
+	}
+	return name
+}
+
 // NewBPFObjName truncates the result if it is too long.
 func NewBPFObjName(name string) BPFObjName {
 	var result BPFObjName
@@ -263,6 +309,8 @@ type BPFMapCreateAttr struct {
 	BTFFd          uint32
 	BTFKeyTypeID   uint32
 	BTFValueTypeID uint32
+	// since 5.6 85d33df357b6
+	BTFVmlinuxValueTypeID uint32
 }
 
 func BPFMapCreate(attr *BPFMapCreateAttr) (*FD, error) {
@@ -274,6 +322,62 @@ func BPFMapCreate(attr *BPFMapCreateAttr) (*FD, error) {
 	return NewFD(uint32(fd)), nil
 }
 
+// HaveBatchAPI probes the running kernel for batch map operations like
+// BPF_MAP_LOOKUP_BATCH.
+//
+// It is shared by the ebpf and features packages, so that the kernel is
+// only probed once.
+var HaveBatchAPI = FeatureTest("map batch api", "5.6", func() error {
+	fd, err := BPFMapCreate(&BPFMapCreateAttr{
+		MapType:    2, // BPF_MAP_TYPE_ARRAY
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	mapFd, err := fd.Value()
+	if err != nil {
+		return err
+	}
+
+	var nextKey, key, value uint32
+
+	attr := struct {
+		inBatch   Pointer
+		outBatch  Pointer
+		keys      Pointer
+		values    Pointer
+		count     uint32
+		mapFd     uint32
+		elemFlags uint64
+		flags     uint64
+	}{
+		outBatch: NewPointer(unsafe.Pointer(&nextKey)),
+		keys:     NewPointer(unsafe.Pointer(&key)),
+		values:   NewPointer(unsafe.Pointer(&value)),
+		count:    1,
+		mapFd:    mapFd,
+	}
+
+	_, err = BPF(BPF_MAP_LOOKUP_BATCH, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	switch {
+	// ENOENT signals that the batch reached the end of the map.
+	case err == nil, errors.Is(err, unix.ENOENT):
+		return nil
+
+	// EINVAL occurs if the command is unknown, E2BIG if the kernel doesn't
+	// know about the batch fields of the attribute.
+	case errors.Is(err, unix.EINVAL), errors.Is(err, unix.E2BIG):
+		return ErrNotSupported
+	}
+
+	return err
+})
+
 // wrappedErrno wraps syscall.Errno to prevent direct comparisons with
 // syscall.E* or unix.E* constants.
 //
@@ -63,6 +63,11 @@ const (
 	PERF_RECORD_SAMPLE       = linux.PERF_RECORD_SAMPLE
 	AT_FDCWD                 = linux.AT_FDCWD
 	RENAME_NOREPLACE         = linux.RENAME_NOREPLACE
+	CLOCK_BOOTTIME           = linux.CLOCK_BOOTTIME
+	AF_NETLINK               = linux.AF_NETLINK
+	SOCK_RAW                 = linux.SOCK_RAW
+	SOCK_CLOEXEC             = linux.SOCK_CLOEXEC
+	NETLINK_ROUTE            = linux.NETLINK_ROUTE
 )
 
 // Statfs_t is a wrapper
@@ -124,6 +129,16 @@ func Write(fd int, p []byte) (n int, err error) {
 	return linux.Write(fd, p)
 }
 
+// Read is a wrapper
+func Read(fd int, p []byte) (n int, err error) {
+	return linux.Read(fd, p)
+}
+
+// Socket is a wrapper
+func Socket(domain, typ, proto int) (fd int, err error) {
+	return linux.Socket(domain, typ, proto)
+}
+
 // EpollCreate1 is a wrapper
 func EpollCreate1(flag int) (fd int, err error) {
 	return linux.EpollCreate1(flag)
@@ -193,6 +208,14 @@ func Renameat2(olddirfd int, oldpath string, newdirfd int, newpath string, flags
 	return linux.Renameat2(olddirfd, oldpath, newdirfd, newpath, flags)
 }
 
+// Timespec is a wrapper
+type Timespec = linux.Timespec
+
+// ClockGettime is a wrapper
+func ClockGettime(clockid int32, time *Timespec) (err error) {
+	return linux.ClockGettime(clockid, time)
+}
+
 func KernelRelease() (string, error) {
 	var uname Utsname
 	err := Uname(&uname)
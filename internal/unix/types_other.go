@@ -64,6 +64,11 @@ const (
 	PERF_RECORD_SAMPLE       = 9
 	AT_FDCWD                 = -0x2
 	RENAME_NOREPLACE         = 0x1
+	CLOCK_BOOTTIME           = 0x7
+	AF_NETLINK               = 0x10
+	SOCK_RAW                 = 0x3
+	SOCK_CLOEXEC             = 0x80000
+	NETLINK_ROUTE            = 0x0
 )
 
 // Statfs_t is a wrapper
@@ -145,6 +150,16 @@ func Write(fd int, p []byte) (n int, err error) {
 	return 0, errNonLinux
 }
 
+// Read is a wrapper
+func Read(fd int, p []byte) (n int, err error) {
+	return 0, errNonLinux
+}
+
+// Socket is a wrapper
+func Socket(domain, typ, proto int) (fd int, err error) {
+	return -1, errNonLinux
+}
+
 // EpollCreate1 is a wrapper
 func EpollCreate1(flag int) (fd int, err error) {
 	return 0, errNonLinux
@@ -260,6 +275,17 @@ func Renameat2(olddirfd int, oldpath string, newdirfd int, newpath string, flags
 	return errNonLinux
 }
 
+// Timespec is a wrapper
+type Timespec struct {
+	Sec  int64
+	Nsec int64
+}
+
+// ClockGettime is a wrapper
+func ClockGettime(clockid int32, time *Timespec) (err error) {
+	return errNonLinux
+}
+
 func KernelRelease() (string, error) {
 	return "", errNonLinux
 }
@@ -46,6 +46,12 @@ func NewVersion(ver string) (Version, error) {
 	return Version{major, minor, patch}, nil
 }
 
+// NewVersionFromCode creates a version from the output of the kernel's
+// KERNEL_VERSION macro. It is the inverse of Kernel.
+func NewVersionFromCode(code uint32) Version {
+	return Version{uint16(code >> 16 & 0xff), uint16(code >> 8 & 0xff), uint16(code & 0xff)}
+}
+
 func (v Version) String() string {
 	if v[2] == 0 {
 		return fmt.Sprintf("v%d.%d", v[0], v[1])
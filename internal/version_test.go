@@ -44,6 +44,10 @@ func TestKernelVersion(t *testing.T) {
 	if v, want := (Version{4, 9, 128}), uint32(264576); v.Kernel() != want {
 		t.Errorf("4.9.1 should result in a kernel version of %d, got: %d", want, v.Kernel())
 	}
+
+	if v := NewVersionFromCode(264576); v != (Version{4, 9, 128}) {
+		t.Errorf("Kernel version 264576 should result in 4.9.128, got: %s", v)
+	}
 }
 
 func TestVersionDetection(t *testing.T) {
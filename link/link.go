@@ -1,17 +1,22 @@
 package link
 
 import (
+	"errors"
 	"fmt"
+	"os"
 	"unsafe"
 
 	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
 	"github.com/cilium/ebpf/internal"
-	"github.com/cilium/ebpf/internal/btf"
 )
 
 var ErrNotSupported = internal.ErrNotSupported
 
 // Link represents a Program attached to a BPF hook.
+//
+// Depending on the hook and the kernel version a Link is either backed by a
+// bpf_link or by an older attachment mechanism like PERF_EVENT_IOC_SET_BPF.
 type Link interface {
 	// Replace the current program with a new program.
 	//
@@ -35,6 +40,12 @@ type Link interface {
 	// not called.
 	Close() error
 
+	// Info returns metadata about the link.
+	//
+	// Returns an error wrapping ErrNotSupported if the link isn't backed
+	// by a bpf_link.
+	Info() (*Info, error)
+
 	// Prevent external users from implementing this interface.
 	isLink()
 }
@@ -52,15 +63,22 @@ type RawLinkOptions struct {
 	Attach ebpf.AttachType
 	// BTF is the BTF of the attachment target.
 	BTF btf.TypeID
+	// Flags control the attach behaviour. This differs for each attach type.
+	Flags uint32
 }
 
-// RawLinkInfo contains metadata on a link.
-type RawLinkInfo struct {
+// Info contains metadata on a link.
+type Info struct {
 	Type    Type
 	ID      ID
 	Program ebpf.ProgramID
 }
 
+// RawLinkInfo contains metadata on a link.
+//
+// Deprecated: use Info instead.
+type RawLinkInfo = Info
+
 // RawLink is the low-level API to bpf_link.
 //
 // You should consider using the higher level interfaces in this
@@ -77,12 +95,12 @@ func AttachRawLink(opts RawLinkOptions) (*RawLink, error) {
 	}
 
 	if opts.Target < 0 {
-		return nil, fmt.Errorf("invalid target: %s", internal.ErrClosedFd)
+		return nil, fmt.Errorf("invalid target: %w", internal.ErrClosedFd)
 	}
 
 	progFd := opts.Program.FD()
 	if progFd < 0 {
-		return nil, fmt.Errorf("invalid program: %s", internal.ErrClosedFd)
+		return nil, fmt.Errorf("invalid program: %w", internal.ErrClosedFd)
 	}
 
 	attr := bpfLinkCreateAttr{
@@ -90,10 +108,11 @@ func AttachRawLink(opts RawLinkOptions) (*RawLink, error) {
 		progFd:      uint32(progFd),
 		attachType:  opts.Attach,
 		targetBTFID: uint32(opts.BTF),
+		flags:       opts.Flags,
 	}
 	fd, err := bpfLinkCreate(&attr)
 	if err != nil {
-		return nil, fmt.Errorf("can't create link: %s", err)
+		return nil, fmt.Errorf("can't create link: %w", err)
 	}
 
 	return &RawLink{fd, ""}, nil
@@ -128,6 +147,132 @@ func LoadPinnedRawLink(fileName string, linkType Type, opts *ebpf.LoadPinOptions
 	return link, nil
 }
 
+// LoadPinnedLink loads a persisted link of any type from a bpffs.
+//
+// The returned Link has the same concrete type as the one returned when
+// attaching, as far as it can be determined from the link type.
+func LoadPinnedLink(fileName string, opts *ebpf.LoadPinOptions) (Link, error) {
+	raw, err := LoadPinnedRawLink(fileName, UnspecifiedType, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return wrapRawLink(raw)
+}
+
+// NewFromID returns the link with the given ID.
+//
+// The returned Link has the same concrete type as the one returned when
+// attaching, as far as it can be determined from the link type.
+//
+// Returns an error wrapping os.ErrNotExist if there is no link with the given ID.
+func NewFromID(id ID) (Link, error) {
+	fd, err := internal.BPFObjGetFDByID(internal.BPF_LINK_GET_FD_BY_ID, uint32(id))
+	if err != nil {
+		return nil, fmt.Errorf("get link by id: %w", err)
+	}
+
+	return wrapRawLink(&RawLink{fd, ""})
+}
+
+// GetNextID returns the ID of the next BPF link.
+//
+// Returns os.ErrNotExist, if there is no next link.
+func GetNextID(startID ID) (ID, error) {
+	id, err := bpfLinkGetNextID(uint32(startID))
+	return ID(id), err
+}
+
+// LinkIterator enumerates all links in the system.
+//
+// Use NewLinkIterator to create one.
+type LinkIterator struct {
+	id   ID
+	link Link
+	err  error
+}
+
+// NewLinkIterator returns an iterator over all links in the system.
+//
+// Requires CAP_SYS_ADMIN.
+func NewLinkIterator() *LinkIterator {
+	return &LinkIterator{}
+}
+
+// Next opens the next link.
+//
+// Links which are removed between retrieving their ID and opening them
+// are skipped.
+//
+// Returns false if there are no more links or if an error occurred. You
+// must check Err in that case.
+func (li *LinkIterator) Next() bool {
+	li.link = nil
+	if li.err != nil {
+		return false
+	}
+
+	for {
+		id, err := GetNextID(li.id)
+		if errors.Is(err, os.ErrNotExist) {
+			return false
+		}
+		if err != nil {
+			li.err = fmt.Errorf("get next link id: %w", err)
+			return false
+		}
+		li.id = id
+
+		link, err := NewFromID(id)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			li.err = fmt.Errorf("open link %d: %w", id, err)
+			return false
+		}
+
+		li.link = link
+		return true
+	}
+}
+
+// Link returns the link opened by the last call to Next.
+//
+// The caller must close the link.
+func (li *LinkIterator) Link() Link {
+	return li.link
+}
+
+// Err returns the error which made Next return false, if any.
+func (li *LinkIterator) Err() error {
+	return li.err
+}
+
+// wrapRawLink wraps raw in the type matching its link type.
+//
+// Closes raw on error.
+func wrapRawLink(raw *RawLink) (Link, error) {
+	info, err := raw.Info()
+	if err != nil {
+		raw.Close()
+		return nil, err
+	}
+
+	switch info.Type {
+	case TracingType:
+		return &tracing{*raw}, nil
+	case CgroupType:
+		return &linkCgroup{*raw}, nil
+	case IterType:
+		return &Iter{*raw}, nil
+	case NetNsType:
+		return &NetNsLink{raw}, nil
+	default:
+		return raw, nil
+	}
+}
+
 func (l *RawLink) isLink() {}
 
 // FD returns the raw file descriptor.
@@ -176,7 +321,9 @@ func (l *RawLink) Update(new *ebpf.Program) error {
 
 // RawLinkUpdateOptions control the behaviour of RawLink.UpdateArgs.
 type RawLinkUpdateOptions struct {
-	New   *ebpf.Program
+	New *ebpf.Program
+	// Old is optional. If set, the update only succeeds if Old is the
+	// program currently attached to the link.
 	Old   *ebpf.Program
 	Flags uint32
 }
@@ -189,11 +336,13 @@ func (l *RawLink) UpdateArgs(opts RawLinkUpdateOptions) error {
 	}
 
 	var oldFd int
+	flags := opts.Flags
 	if opts.Old != nil {
 		oldFd = opts.Old.FD()
 		if oldFd < 0 {
 			return fmt.Errorf("invalid replacement program: %s", internal.ErrClosedFd)
 		}
+		flags |= uint32(flagReplace)
 	}
 
 	linkFd, err := l.fd.Value()
@@ -205,7 +354,7 @@ func (l *RawLink) UpdateArgs(opts RawLinkUpdateOptions) error {
 		linkFd:    linkFd,
 		newProgFd: uint32(newFd),
 		oldProgFd: uint32(oldFd),
-		flags:     opts.Flags,
+		flags:     flags,
 	}
 	return bpfLinkUpdate(&attr)
 }
@@ -218,14 +367,18 @@ type bpfLinkInfo struct {
 }
 
 // Info returns metadata about the link.
-func (l *RawLink) Info() (*RawLinkInfo, error) {
+func (l *RawLink) Info() (*Info, error) {
+	return linkInfo(l.fd)
+}
+
+func linkInfo(fd *internal.FD) (*Info, error) {
 	var info bpfLinkInfo
-	err := internal.BPFObjGetInfoByFD(l.fd, unsafe.Pointer(&info), unsafe.Sizeof(info))
+	err := internal.BPFObjGetInfoByFD(fd, unsafe.Pointer(&info), unsafe.Sizeof(info))
 	if err != nil {
-		return nil, fmt.Errorf("link info: %s", err)
+		return nil, fmt.Errorf("link info: %w", err)
 	}
 
-	return &RawLinkInfo{
+	return &Info{
 		Type(info.typ),
 		ID(info.id),
 		ebpf.ProgramID(info.prog_id),
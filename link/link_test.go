@@ -163,6 +163,27 @@ func testLink(t *testing.T, link Link, opts testLinkOptions) {
 		}
 	}
 
+	t.Run("info", func(t *testing.T) {
+		info, err := link.Info()
+		if err == ErrNotSupported {
+			t.Fatal("Info returns unwrapped ErrNotSupported", link)
+		}
+		if errors.Is(err, ErrNotSupported) {
+			return
+		}
+		if err != nil {
+			t.Fatal("Info returns an error:", err)
+		}
+
+		pi, err := opts.prog.Info()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id, ok := pi.ID(); ok && info.Program != id {
+			t.Errorf("Info returns program %d, expected %d", info.Program, id)
+		}
+	})
+
 	t.Run("update", func(t *testing.T) {
 		err := link.Update(opts.prog)
 		if err == ErrNotSupported {
@@ -191,3 +212,148 @@ func testLink(t *testing.T, link Link, opts testLinkOptions) {
 		t.Fatalf("%T.Close returns an error: %s", link, err)
 	}
 }
+
+func TestNewFromID(t *testing.T) {
+	cgroup, prog := mustCgroupFixtures(t)
+
+	link, err := AttachCgroup(CgroupOptions{
+		Path:    cgroup.Name(),
+		Attach:  ebpf.AttachCGroupInetEgress,
+		Program: prog,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer link.Close()
+
+	lc, ok := link.(*linkCgroup)
+	if !ok {
+		t.Skip("Kernel doesn't support bpf_link for cgroups")
+	}
+
+	info, err := lc.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	link2, err := NewFromID(info.ID)
+	if err != nil {
+		t.Fatal("Can't get link by ID:", err)
+	}
+	defer link2.Close()
+
+	lc2, ok := link2.(*linkCgroup)
+	if !ok {
+		t.Fatalf("Expected *linkCgroup, got %T", link2)
+	}
+
+	info2, err := lc2.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info2.ID != info.ID {
+		t.Errorf("Expected link ID %d, got %d", info.ID, info2.ID)
+	}
+
+	if _, err := GetNextID(0); err != nil {
+		t.Error("Can't get next link ID:", err)
+	}
+
+	if _, err := NewFromID(math.MaxUint32); !errors.Is(err, os.ErrNotExist) {
+		t.Error("Expected os.ErrNotExist for missing link, got", err)
+	}
+}
+
+func TestLinkIterator(t *testing.T) {
+	cgroup, prog := mustCgroupFixtures(t)
+
+	link, err := AttachRawLink(RawLinkOptions{
+		Target:  int(cgroup.Fd()),
+		Program: prog,
+		Attach:  ebpf.AttachCGroupInetEgress,
+	})
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer link.Close()
+
+	info, err := link.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var found bool
+	it := NewLinkIterator()
+	for it.Next() {
+		l := it.Link()
+		if li, err := l.Info(); err == nil && li.ID == info.ID {
+			found = true
+		}
+		l.Close()
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !found {
+		t.Error("Iterator didn't return link", info.ID)
+	}
+}
+
+func TestRawLinkUpdateArgsOld(t *testing.T) {
+	cgroup, prog := mustCgroupFixtures(t)
+
+	link, err := AttachRawLink(RawLinkOptions{
+		Target:  int(cgroup.Fd()),
+		Program: prog,
+		Attach:  ebpf.AttachCGroupInetEgress,
+	})
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal("Can't create raw link:", err)
+	}
+	defer link.Close()
+
+	prog2 := mustCgroupEgressProgram(t)
+
+	err = link.UpdateArgs(RawLinkUpdateOptions{New: prog, Old: prog2})
+	if err == nil {
+		t.Fatal("Update succeeds although Old isn't attached")
+	}
+
+	err = link.UpdateArgs(RawLinkUpdateOptions{New: prog2, Old: prog})
+	if err != nil {
+		t.Fatal("Can't update link:", err)
+	}
+}
+
+func TestLoadPinnedLink(t *testing.T) {
+	cgroup, prog := mustCgroupFixtures(t)
+
+	link, err := AttachRawLink(RawLinkOptions{
+		Target:  int(cgroup.Fd()),
+		Program: prog,
+		Attach:  ebpf.AttachCGroupInetEgress,
+	})
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal("Can't create raw link:", err)
+	}
+	defer link.Close()
+
+	path := filepath.Join(testutils.TempBPFFS(t), "link")
+	if err := link.Pin(path); err != nil {
+		t.Fatal(err)
+	}
+
+	link2, err := LoadPinnedLink(path, nil)
+	if err != nil {
+		t.Fatal("Can't load pinned link:", err)
+	}
+	defer link2.Close()
+
+	if _, ok := link2.(*linkCgroup); !ok {
+		t.Fatalf("Expected *linkCgroup, got %T", link2)
+	}
+}
@@ -0,0 +1,105 @@
+package link
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/cilium/ebpf"
+)
+
+// lsmHookPrefix is prepended by the kernel to the name of every LSM hook
+// that BPF programs can attach to.
+const lsmHookPrefix = "bpf_lsm_"
+
+// lsmHelpers are functions in kernel/bpf/bpf_lsm.c which share the prefix
+// of the hooks but can't be attached to.
+var lsmHelpers = map[string]bool{
+	"find_cgroup_shim":   true,
+	"func_proto":         true,
+	"get_retval_range":   true,
+	"has_d_inode_locked": true,
+	"hook_returns_errno": true,
+	"init":               true,
+	"is_sleepable_hook":  true,
+	"is_trusted":         true,
+	"verify_prog":        true,
+}
+
+// AttachLSM links a BPF program to the LSM hook it was loaded for.
+//
+// The hook is chosen via ProgramSpec.AttachTo when loading the program,
+// see ListLSMHooks for the names accepted by the running kernel.
+//
+// Requires at least Linux 5.7.
+func AttachLSM(prog *ebpf.Program) (Link, error) {
+	if prog == nil {
+		return nil, fmt.Errorf("prog cannot be nil: %w", errInvalidInput)
+	}
+	if prog.Type() != ebpf.LSM {
+		return nil, fmt.Errorf("eBPF program type %s is not LSM: %w", prog.Type(), errInvalidInput)
+	}
+
+	link, err := attachBTFID(prog)
+	if err != nil {
+		return nil, fmt.Errorf("attach lsm: %w", err)
+	}
+
+	return link, nil
+}
+
+// LoadPinnedLSM loads a pinned LSM link from a bpffs.
+//
+// LSM links are tracing links, this is equivalent to LoadPinnedTracing.
+func LoadPinnedLSM(fileName string, opts *ebpf.LoadPinOptions) (Link, error) {
+	return LoadPinnedTracing(fileName, opts)
+}
+
+// ListLSMHooks returns the sorted names of the LSM hooks exposed by the
+// running kernel, suitable for use in ProgramSpec.AttachTo.
+//
+// The list is best-effort. The kernel doesn't export the set of hooks BPF
+// programs may attach to, so the names are derived from the bpf_lsm_ symbols
+// in /proc/kallsyms minus a list of known helper functions sharing the
+// prefix. Helpers added by future kernels may show up as hooks. Attaching
+// also requires "bpf" to be among the active LSMs in /sys/kernel/security/lsm,
+// which isn't checked.
+//
+// Returns an error wrapping ErrNotSupported if the kernel doesn't have BPF LSM
+// support.
+func ListLSMHooks() ([]string, error) {
+	f, err := os.Open("/proc/kallsyms")
+	if err != nil {
+		return nil, fmt.Errorf("list lsm hooks: %w", err)
+	}
+	defer f.Close()
+
+	var hooks []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// Lines are formatted as "<address> <type> <symbol> [<module>]".
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || (fields[1] != "T" && fields[1] != "t") {
+			continue
+		}
+
+		name := strings.TrimPrefix(fields[2], lsmHookPrefix)
+		if name == fields[2] || lsmHelpers[name] {
+			continue
+		}
+
+		hooks = append(hooks, name)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("list lsm hooks: %w", err)
+	}
+
+	if len(hooks) == 0 {
+		return nil, fmt.Errorf("list lsm hooks: %w", ErrNotSupported)
+	}
+
+	sort.Strings(hooks)
+	return hooks, nil
+}
@@ -0,0 +1,73 @@
+package link
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal/testutils"
+)
+
+func TestAttachLSM(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.7", "BPF LSM")
+
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		Type:       ebpf.LSM,
+		AttachType: ebpf.AttachLSMMac,
+		AttachTo:   "task_getpgid",
+		Instructions: asm.Instructions{
+			asm.LoadImm(asm.R0, 0, asm.DWord),
+			asm.Return(),
+		},
+		License: "GPL",
+	})
+	if err != nil {
+		testutils.SkipIfNotSupported(t, err)
+		t.Fatal(err)
+	}
+	defer prog.Close()
+
+	link, err := AttachLSM(prog)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testLink(t, link, testLinkOptions{
+		prog: prog,
+		loadPinned: func(s string, opts *ebpf.LoadPinOptions) (Link, error) {
+			return LoadPinnedLSM(s, opts)
+		},
+	})
+}
+
+func TestAttachLSMInvalidProgram(t *testing.T) {
+	prog := mustCgroupEgressProgram(t)
+
+	if _, err := AttachLSM(prog); !errors.Is(err, errInvalidInput) {
+		t.Fatal("Expected errInvalidInput for non-LSM program, got", err)
+	}
+}
+
+func TestListLSMHooks(t *testing.T) {
+	hooks, err := ListLSMHooks()
+	if errors.Is(err, ErrNotSupported) {
+		t.Skip("Kernel doesn't support BPF LSM")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := false
+	for _, hook := range hooks {
+		if hook == "verify_prog" {
+			t.Error("ListLSMHooks returns helper verify_prog")
+		}
+		if hook == "file_open" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("ListLSMHooks doesn't return file_open")
+	}
+}
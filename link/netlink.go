@@ -0,0 +1,155 @@
+package link
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/unix"
+)
+
+// Constants from <linux/netlink.h> and <linux/rtnetlink.h>.
+const (
+	nlmsgHdrLen   = 16
+	nlaHdrLen     = 4
+	nlaAlignTo    = 4
+	nlmsgAlignTo  = 4
+	nlmsgError    = 0x2
+	nlmFRequest   = 0x1
+	nlmFAck       = 0x4
+	nlmFEcho      = 0x8
+	nlmFExcl      = 0x200
+	nlmFCreate    = 0x400
+	nlaFNested    = 0x8000
+	rtmSetLink    = 0x13
+	rtmNewQdisc   = 0x24
+	rtmNewTFilter = 0x2c
+	rtmDelTFilter = 0x2d
+)
+
+var errNetlinkTruncated = errors.New("truncated netlink message")
+
+// netlinkMessage is a request to the rtnetlink subsystem.
+//
+// The body starts with the family specific header, for example struct
+// ifinfomsg, and is followed by attributes.
+type netlinkMessage struct {
+	typ   uint16
+	flags uint16
+	body  []byte
+}
+
+func netlinkAlign(n, to int) int {
+	return (n + to - 1) &^ (to - 1)
+}
+
+// attr appends an attribute with an arbitrary payload.
+func (msg *netlinkMessage) attr(typ uint16, data []byte) {
+	hdr := make([]byte, nlaHdrLen)
+	internal.NativeEndian.PutUint16(hdr[0:], uint16(nlaHdrLen+len(data)))
+	internal.NativeEndian.PutUint16(hdr[2:], typ)
+
+	msg.body = append(msg.body, hdr...)
+	msg.body = append(msg.body, data...)
+	msg.pad()
+}
+
+func (msg *netlinkMessage) uint32Attr(typ uint16, value uint32) {
+	data := make([]byte, 4)
+	internal.NativeEndian.PutUint32(data, value)
+	msg.attr(typ, data)
+}
+
+// stringAttr appends a NUL terminated string attribute.
+func (msg *netlinkMessage) stringAttr(typ uint16, value string) {
+	msg.attr(typ, append([]byte(value), 0))
+}
+
+// nested appends an attribute containing the attributes added by fn.
+func (msg *netlinkMessage) nested(typ uint16, fn func()) {
+	start := len(msg.body)
+	msg.body = append(msg.body, make([]byte, nlaHdrLen)...)
+	fn()
+
+	internal.NativeEndian.PutUint16(msg.body[start:], uint16(len(msg.body)-start))
+	internal.NativeEndian.PutUint16(msg.body[start+2:], typ|nlaFNested)
+}
+
+func (msg *netlinkMessage) pad() {
+	padded := netlinkAlign(len(msg.body), nlaAlignTo)
+	msg.body = append(msg.body, make([]byte, padded-len(msg.body))...)
+}
+
+// netlinkRequest sends msg to the kernel and waits for it to be acknowledged.
+//
+// Returns the bodies of any messages the kernel sent before the acknowledgement,
+// which is only the case if msg has nlmFEcho set.
+func netlinkRequest(msg *netlinkMessage) ([][]byte, error) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_ROUTE)
+	if err != nil {
+		return nil, fmt.Errorf("netlink socket: %w", err)
+	}
+	sock := internal.NewFD(uint32(fd))
+	defer sock.Close()
+
+	const seq = 1
+
+	buf := make([]byte, nlmsgHdrLen, nlmsgHdrLen+len(msg.body))
+	internal.NativeEndian.PutUint32(buf[0:], uint32(nlmsgHdrLen+len(msg.body)))
+	internal.NativeEndian.PutUint16(buf[4:], msg.typ)
+	internal.NativeEndian.PutUint16(buf[6:], msg.flags|nlmFRequest|nlmFAck)
+	internal.NativeEndian.PutUint32(buf[8:], seq)
+	buf = append(buf, msg.body...)
+
+	if _, err := unix.Write(fd, buf); err != nil {
+		return nil, fmt.Errorf("netlink write: %w", err)
+	}
+
+	var replies [][]byte
+	recv := make([]byte, 32*1024)
+	for {
+		n, err := unix.Read(fd, recv)
+		if err != nil {
+			return nil, fmt.Errorf("netlink read: %w", err)
+		}
+
+		for data := recv[:n]; len(data) > 0; {
+			if len(data) < nlmsgHdrLen {
+				return nil, errNetlinkTruncated
+			}
+
+			length := int(internal.NativeEndian.Uint32(data[0:]))
+			typ := internal.NativeEndian.Uint16(data[4:])
+			if length < nlmsgHdrLen || length > len(data) {
+				return nil, errNetlinkTruncated
+			}
+
+			if internal.NativeEndian.Uint32(data[8:]) == seq {
+				body := data[nlmsgHdrLen:length]
+				if typ != nlmsgError {
+					replies = append(replies, append([]byte(nil), body...))
+				} else {
+					// struct nlmsgerr starts with a negative errno, or zero
+					// for an acknowledgement.
+					if len(body) < 4 {
+						return nil, errNetlinkTruncated
+					}
+					if errno := int32(internal.NativeEndian.Uint32(body)); errno != 0 {
+						return nil, syscall.Errno(-errno)
+					}
+					return replies, nil
+				}
+			}
+
+			data = data[min(netlinkAlign(length, nlmsgAlignTo), len(data)):]
+		}
+	}
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
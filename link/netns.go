@@ -7,8 +7,10 @@ import (
 )
 
 // NetNsInfo contains metadata about a network namespace link.
+//
+// Deprecated: NetNsLink.Info returns an Info.
 type NetNsInfo struct {
-	RawLinkInfo
+	Info
 }
 
 // NetNsLink is a program attached to a network namespace.
@@ -49,12 +51,3 @@ func LoadPinnedNetNs(fileName string, opts *ebpf.LoadPinOptions) (*NetNsLink, er
 
 	return &NetNsLink{link}, nil
 }
-
-// Info returns information about the link.
-func (nns *NetNsLink) Info() (*NetNsInfo, error) {
-	info, err := nns.RawLink.Info()
-	if err != nil {
-		return nil, err
-	}
-	return &NetNsInfo{*info}, nil
-}
@@ -84,6 +84,9 @@ type perfEvent struct {
 	typ perfEventType
 
 	fd *internal.FD
+	// bpf_link holding the attached program, nil if the program was attached
+	// using PERF_EVENT_IOC_SET_BPF.
+	link *internal.FD
 }
 
 func (pe *perfEvent) isLink() {}
@@ -96,6 +99,13 @@ func (pe *perfEvent) Unpin() error {
 	return fmt.Errorf("unpin perf event: %w", ErrNotSupported)
 }
 
+func (pe *perfEvent) Info() (*Info, error) {
+	if pe.link == nil {
+		return nil, fmt.Errorf("can't get info of perf event attached with ioctl: %w", ErrNotSupported)
+	}
+	return linkInfo(pe.link)
+}
+
 // Since 4.15 (e87c6bc3852b "bpf: permit multiple bpf attachments for a single perf event"),
 // calling PERF_EVENT_IOC_SET_BPF appends the given program to a prog_array
 // owned by the perf event, which means multiple programs can be attached
@@ -125,6 +135,12 @@ func (pe *perfEvent) Close() error {
 		return fmt.Errorf("disabling perf event: %w", err)
 	}
 
+	if pe.link != nil {
+		if err := pe.link.Close(); err != nil {
+			return fmt.Errorf("closing perf event link: %w", err)
+		}
+	}
+
 	err = pe.fd.Close()
 	if err != nil {
 		return fmt.Errorf("closing perf event fd: %w", err)
@@ -178,10 +194,23 @@ func (pe *perfEvent) attach(prog *ebpf.Program) error {
 	// The ioctl below will fail when the fd is invalid.
 	kfd, _ := pe.fd.Value()
 
-	// Assign the eBPF program to the perf event.
-	err := unix.IoctlSetInt(int(kfd), unix.PERF_EVENT_IOC_SET_BPF, prog.FD())
-	if err != nil {
-		return fmt.Errorf("setting perf event bpf program: %w", err)
+	if err := havePerfLink(); err == nil {
+		// Attach using a bpf_link, which detaches the program once the
+		// link is closed, even if the perf event fd is still open.
+		pe.link, err = bpfLinkCreate(&bpfLinkCreateAttr{
+			targetFd:   kfd,
+			progFd:     uint32(prog.FD()),
+			attachType: ebpf.AttachPerfEvent,
+		})
+		if err != nil {
+			return fmt.Errorf("creating perf event link: %w", err)
+		}
+	} else {
+		// Assign the eBPF program to the perf event.
+		err := unix.IoctlSetInt(int(kfd), unix.PERF_EVENT_IOC_SET_BPF, prog.FD())
+		if err != nil {
+			return fmt.Errorf("setting perf event bpf program: %w", err)
+		}
 	}
 
 	// PERF_EVENT_IOC_ENABLE and _DISABLE ignore their given values.
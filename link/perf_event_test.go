@@ -72,3 +72,7 @@ func TestTraceEventRegex(t *testing.T) {
 		})
 	}
 }
+
+func TestHavePerfLink(t *testing.T) {
+	testutils.CheckFeatureTest(t, havePerfLink)
+}
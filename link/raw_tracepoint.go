@@ -16,6 +16,8 @@ type RawTracepointOptions struct {
 
 // AttachRawTracepoint links a BPF program to a raw_tracepoint.
 //
+// Returns an error wrapping os.ErrNotExist if the tracepoint doesn't exist.
+//
 // Requires at least Linux 4.17.
 func AttachRawTracepoint(opts RawTracepointOptions) (Link, error) {
 	if t := opts.Program.Type(); t != ebpf.RawTracepoint && t != ebpf.RawTracepointWritable {
@@ -30,7 +32,7 @@ func AttachRawTracepoint(opts RawTracepointOptions) (Link, error) {
 		fd:   uint32(opts.Program.FD()),
 	})
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("raw tracepoint %s: %w", opts.Name, err)
 	}
 
 	return &progAttachRawTracepoint{fd: fd}, nil
@@ -59,3 +61,7 @@ func (rt *progAttachRawTracepoint) Pin(_ string) error {
 func (rt *progAttachRawTracepoint) Unpin() error {
 	return fmt.Errorf("unpin raw_tracepoint: %w", ErrNotSupported)
 }
+
+func (rt *progAttachRawTracepoint) Info() (*Info, error) {
+	return nil, fmt.Errorf("can't get info of raw_tracepoint: %w", ErrNotSupported)
+}
@@ -1,6 +1,8 @@
 package link
 
 import (
+	"errors"
+	"os"
 	"testing"
 
 	"github.com/cilium/ebpf"
@@ -25,6 +27,14 @@ func TestRawTracepoint(t *testing.T) {
 	}
 	defer prog.Close()
 
+	_, err = AttachRawTracepoint(RawTracepointOptions{
+		Name:    "bogus_tracepoint",
+		Program: prog,
+	})
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Error("Expected os.ErrNotExist for missing tracepoint, got", err)
+	}
+
 	link, err := AttachRawTracepoint(RawTracepointOptions{
 		Name:    "cgroup_mkdir",
 		Program: prog,
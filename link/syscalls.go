@@ -24,6 +24,11 @@ const (
 	IterType
 	NetNsType
 	XDPType
+	PerfEventType
+	KprobeMultiType
+	StructOpsType
+	NetfilterType
+	TCXType
 )
 
 var haveProgAttach = internal.FeatureTest("BPF_PROG_ATTACH", "4.10", func() error {
@@ -163,6 +168,84 @@ var haveBPFLink = internal.FeatureTest("bpf_link", "5.7", func() error {
 	return err
 })
 
+var havePerfLink = internal.FeatureTest("bpf_link for perf events", "5.15", func() error {
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		Type:    ebpf.TracePoint,
+		License: "MIT",
+		Instructions: asm.Instructions{
+			asm.Mov.Imm(asm.R0, 0),
+			asm.Return(),
+		},
+	})
+	if err != nil {
+		return internal.ErrNotSupported
+	}
+	defer prog.Close()
+
+	attr := bpfLinkCreateAttr{
+		// This is a hopefully invalid file descriptor, which triggers EBADF.
+		targetFd:   ^uint32(0),
+		progFd:     uint32(prog.FD()),
+		attachType: ebpf.AttachPerfEvent,
+	}
+	_, err = bpfLinkCreate(&attr)
+	if errors.Is(err, unix.EINVAL) {
+		return internal.ErrNotSupported
+	}
+	if errors.Is(err, unix.EBADF) {
+		return nil
+	}
+	return err
+})
+
+var haveXDPLink = internal.FeatureTest("bpf_link for XDP", "5.9", func() error {
+	attr := bpfLinkCreateAttr{
+		// This is a hopefully invalid file descriptor, which triggers EBADF.
+		// Kernels without XDP links reject the attach type with EINVAL
+		// before looking at the program.
+		progFd:     ^uint32(0),
+		attachType: ebpf.AttachXDP,
+	}
+	_, err := bpfLinkCreate(&attr)
+	if errors.Is(err, unix.EINVAL) {
+		return internal.ErrNotSupported
+	}
+	if errors.Is(err, unix.EBADF) {
+		return nil
+	}
+	return err
+})
+
+var haveTCX = internal.FeatureTest("tcx", "6.6", func() error {
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		Type:    ebpf.SchedCLS,
+		License: "MIT",
+		Instructions: asm.Instructions{
+			asm.Mov.Imm(asm.R0, 0),
+			asm.Return(),
+		},
+	})
+	if err != nil {
+		return internal.ErrNotSupported
+	}
+	defer prog.Close()
+
+	attr := bpfLinkCreateAttr{
+		// This is a hopefully invalid interface index, which triggers ENODEV.
+		targetFd:   ^uint32(0),
+		progFd:     uint32(prog.FD()),
+		attachType: ebpf.AttachTCXIngress,
+	}
+	_, err = bpfLinkCreate(&attr)
+	if errors.Is(err, unix.ENODEV) {
+		return nil
+	}
+	if err != nil {
+		return internal.ErrNotSupported
+	}
+	return errors.New("attaching to an invalid interface succeeded")
+})
+
 type bpfIterCreateAttr struct {
 	linkFd uint32
 	flags  uint32
@@ -189,3 +272,17 @@ func bpfRawTracepointOpen(attr *bpfRawTracepointOpenAttr) (*internal.FD, error)
 	}
 	return nil, err
 }
+
+type bpfObjGetNextIDAttr struct {
+	startID   uint32
+	nextID    uint32
+	openFlags uint32
+}
+
+func bpfLinkGetNextID(start uint32) (uint32, error) {
+	attr := bpfObjGetNextIDAttr{
+		startID: start,
+	}
+	_, err := internal.BPF(internal.BPF_LINK_GET_NEXT_ID, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	return attr.nextID, err
+}
@@ -0,0 +1,207 @@
+package link
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/unix"
+)
+
+// TCOptions control the attachment of a traffic control program.
+type TCOptions struct {
+	// Attach must be either AttachTCXIngress or AttachTCXEgress.
+	Attach ebpf.AttachType
+}
+
+// AttachTC attaches a SchedCLS program to the ingress or egress hook of the
+// network interface with the given index.
+//
+// The program is appended to the programs already attached to the hook. Closing
+// the Link only detaches this program, others on the same hook are unaffected.
+//
+// Kernels older than Linux 6.6 don't support tcx links. On those the program
+// is attached as a direct-action cls_bpf filter of a clsact qdisc via netlink
+// instead, which requires at least Linux 4.5. The qdisc is created if
+// necessary and isn't removed when the Link is closed. Such a Link can't be
+// pinned.
+func AttachTC(ifindex int, prog *ebpf.Program, opts TCOptions) (Link, error) {
+	if t := prog.Type(); t != ebpf.SchedCLS {
+		return nil, fmt.Errorf("invalid program type %s, expected SchedCLS", t)
+	}
+
+	if opts.Attach != ebpf.AttachTCXIngress && opts.Attach != ebpf.AttachTCXEgress {
+		return nil, fmt.Errorf("invalid attach type %s, expected AttachTCXIngress or AttachTCXEgress", opts.Attach)
+	}
+
+	if ifindex < 1 {
+		return nil, fmt.Errorf("invalid interface index %d", ifindex)
+	}
+
+	if err := haveTCX(); errors.Is(err, ErrNotSupported) {
+		return attachTCNetlink(ifindex, prog, opts.Attach)
+	} else if err != nil {
+		return nil, err
+	}
+
+	link, err := AttachRawLink(RawLinkOptions{
+		Target:  ifindex,
+		Program: prog,
+		Attach:  opts.Attach,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("attach TC: %w", err)
+	}
+
+	return link, nil
+}
+
+// Constants from <linux/pkt_sched.h>, <linux/rtnetlink.h> and
+// <linux/pkt_cls.h>.
+const (
+	tcHClsact           = 0xfffffff1
+	tcHMinIngress       = 0xfff2
+	tcHMinEgress        = 0xfff3
+	tcaKind             = 0x1
+	tcaOptions          = 0x2
+	tcaBPFFD            = 0x6
+	tcaBPFName          = 0x7
+	tcaBPFFlags         = 0x8
+	tcaBPFFlagActDirect = 0x1
+	tcmsgLen            = 20
+)
+
+// tcNetlink is a SchedCLS program attached to a clsact qdisc via netlink.
+type tcNetlink struct {
+	ifindex int
+	parent  uint32
+	handle  uint32
+	// Priority of the filter, in the upper 16 bits, ORed with the protocol
+	// in network byte order. Equivalent to tcmsg.tcm_info.
+	info    uint32
+	current *ebpf.Program
+}
+
+var _ Link = (*tcNetlink)(nil)
+
+func attachTCNetlink(ifindex int, prog *ebpf.Program, attach ebpf.AttachType) (*tcNetlink, error) {
+	parent := uint32(tcHClsact&0xffff0000 | tcHMinIngress)
+	if attach == ebpf.AttachTCXEgress {
+		parent = tcHClsact&0xffff0000 | tcHMinEgress
+	}
+
+	qdisc := netlinkMessage{
+		typ:   rtmNewQdisc,
+		flags: nlmFCreate | nlmFExcl,
+		body:  tcmsg(ifindex, tcHClsact&0xffff0000, tcHClsact, 0),
+	}
+	qdisc.stringAttr(tcaKind, "clsact")
+	if _, err := netlinkRequest(&qdisc); err != nil && !errors.Is(err, unix.EEXIST) {
+		return nil, fmt.Errorf("create clsact qdisc: %w", err)
+	}
+
+	current, err := prog.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	// Let the kernel choose priority and handle, so that existing filters
+	// are left alone. They are echoed back to us.
+	filter := tcFilterMessage(ifindex, parent, 0, uint32(ethPAll()), current)
+	filter.flags = nlmFCreate | nlmFExcl | nlmFEcho
+	replies, err := netlinkRequest(filter)
+	if err != nil {
+		current.Close()
+		return nil, fmt.Errorf("attach TC via netlink: %w", err)
+	}
+
+	if len(replies) == 0 || len(replies[0]) < tcmsgLen {
+		current.Close()
+		return nil, fmt.Errorf("attach TC via netlink: kernel didn't echo filter")
+	}
+
+	tc := &tcNetlink{
+		ifindex: ifindex,
+		parent:  parent,
+		handle:  internal.NativeEndian.Uint32(replies[0][8:]),
+		info:    internal.NativeEndian.Uint32(replies[0][16:]),
+		current: current,
+	}
+	return tc, nil
+}
+
+// tcmsg returns an encoded struct tcmsg.
+func tcmsg(ifindex int, handle, parent, info uint32) []byte {
+	msg := make([]byte, tcmsgLen)
+	internal.NativeEndian.PutUint32(msg[4:], uint32(ifindex))
+	internal.NativeEndian.PutUint32(msg[8:], handle)
+	internal.NativeEndian.PutUint32(msg[12:], parent)
+	internal.NativeEndian.PutUint32(msg[16:], info)
+	return msg
+}
+
+func tcFilterMessage(ifindex int, parent, handle, info uint32, prog *ebpf.Program) *netlinkMessage {
+	msg := &netlinkMessage{
+		typ:  rtmNewTFilter,
+		body: tcmsg(ifindex, handle, parent, info),
+	}
+	msg.stringAttr(tcaKind, "bpf")
+	msg.nested(tcaOptions, func() {
+		msg.uint32Attr(tcaBPFFD, uint32(prog.FD()))
+		msg.stringAttr(tcaBPFName, prog.Name())
+		msg.uint32Attr(tcaBPFFlags, tcaBPFFlagActDirect)
+	})
+	return msg
+}
+
+// ethPAll returns ETH_P_ALL in network byte order.
+func ethPAll() uint16 {
+	return internal.NativeEndian.Uint16([]byte{0x00, 0x03})
+}
+
+func (tc *tcNetlink) isLink() {}
+
+func (tc *tcNetlink) Close() error {
+	defer tc.current.Close()
+
+	msg := netlinkMessage{
+		typ:  rtmDelTFilter,
+		body: tcmsg(tc.ifindex, tc.handle, tc.parent, tc.info),
+	}
+	msg.stringAttr(tcaKind, "bpf")
+	if _, err := netlinkRequest(&msg); err != nil {
+		return fmt.Errorf("close TC: %w", err)
+	}
+	return nil
+}
+
+func (tc *tcNetlink) Update(prog *ebpf.Program) error {
+	new, err := prog.Clone()
+	if err != nil {
+		return err
+	}
+
+	// Without nlmFCreate the kernel replaces the existing filter.
+	msg := tcFilterMessage(tc.ifindex, tc.parent, tc.handle, tc.info, new)
+	if _, err := netlinkRequest(msg); err != nil {
+		new.Close()
+		return fmt.Errorf("update TC: %w", err)
+	}
+
+	tc.current.Close()
+	tc.current = new
+	return nil
+}
+
+func (tc *tcNetlink) Pin(string) error {
+	return fmt.Errorf("can't pin TC attached via netlink: %w", ErrNotSupported)
+}
+
+func (tc *tcNetlink) Unpin() error {
+	return fmt.Errorf("can't unpin TC attached via netlink: %w", ErrNotSupported)
+}
+
+func (tc *tcNetlink) Info() (*Info, error) {
+	return nil, fmt.Errorf("can't get info of TC attached via netlink: %w", ErrNotSupported)
+}
@@ -0,0 +1,103 @@
+package link
+
+import (
+	"net"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal/testutils"
+)
+
+func TestAttachTC(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "6.6", "tcx link")
+
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		Type:    ebpf.SchedCLS,
+		License: "MIT",
+		Instructions: asm.Instructions{
+			asm.Mov.Imm(asm.R0, 0),
+			asm.Return(),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prog.Close()
+
+	iface, err := net.InterfaceByName("lo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := AttachTC(iface.Index, prog, TCOptions{}); err == nil {
+		t.Fatal("AttachTC accepts an invalid attach type")
+	}
+
+	for _, attach := range []ebpf.AttachType{ebpf.AttachTCXIngress, ebpf.AttachTCXEgress} {
+		t.Run(attach.String(), func(t *testing.T) {
+			link, err := AttachTC(iface.Index, prog, TCOptions{Attach: attach})
+			if err != nil {
+				t.Fatal("Can't attach program:", err)
+			}
+
+			testLink(t, link, testLinkOptions{
+				prog: prog,
+				loadPinned: func(fileName string, opts *ebpf.LoadPinOptions) (Link, error) {
+					return LoadPinnedRawLink(fileName, TCXType, opts)
+				},
+			})
+		})
+	}
+}
+
+func TestAttachTCNetlink(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "4.5", "clsact qdisc")
+
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		Type:    ebpf.SchedCLS,
+		License: "MIT",
+		Instructions: asm.Instructions{
+			asm.Mov.Imm(asm.R0, 0),
+			asm.Return(),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prog.Close()
+
+	iface, err := net.InterfaceByName("lo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, attach := range []ebpf.AttachType{ebpf.AttachTCXIngress, ebpf.AttachTCXEgress} {
+		t.Run(attach.String(), func(t *testing.T) {
+			other, err := attachTCNetlink(iface.Index, prog, attach)
+			if err != nil {
+				t.Fatal("Can't attach program:", err)
+			}
+			defer other.Close()
+
+			link, err := attachTCNetlink(iface.Index, prog, attach)
+			if err != nil {
+				t.Fatal("Can't attach second program:", err)
+			}
+			if link.handle == other.handle && link.info == other.info {
+				t.Fatal("Both filters have the same handle and priority")
+			}
+
+			testLink(t, link, testLinkOptions{prog: prog})
+
+			// Closing a link must not remove other filters.
+			if err := other.Update(prog); err != nil {
+				t.Fatal("Closing a link removes other filters:", err)
+			}
+		})
+	}
+}
+
+func TestHaveTCX(t *testing.T) {
+	testutils.CheckFeatureTest(t, haveTCX)
+}
@@ -19,6 +19,9 @@ import (
 //
 // Note that attaching eBPF programs to syscalls (sys_enter_*/sys_exit_*) is
 // only possible as of kernel 4.14 (commit cf5f5ce).
+//
+// As of kernel 5.15 the program is attached using a bpf_link, which ensures
+// that the program is detached when the Link is closed.
 func Tracepoint(group, name string, prog *ebpf.Program) (Link, error) {
 	if group == "" || name == "" {
 		return nil, fmt.Errorf("group and name cannot be empty: %w", errInvalidInput)
@@ -43,6 +43,10 @@ func TestTracepoint(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	if havePerfLink() == nil && tp.(*perfEvent).link == nil {
+		t.Error("Tracepoint isn't attached using a bpf_link")
+	}
+
 	if err := tp.Close(); err != nil {
 		t.Error("closing tracepoint:", err)
 	}
@@ -0,0 +1,79 @@
+package link
+
+import (
+	"fmt"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/internal"
+)
+
+// tracing is a bpf_link of TracingType, used by Tracing and LSM programs.
+type tracing struct {
+	RawLink
+}
+
+// Update implements the Link interface.
+func (l *tracing) Update(new *ebpf.Program) error {
+	return fmt.Errorf("tracing update: %w", ErrNotSupported)
+}
+
+// TracingOptions control the attachment of a Tracing program.
+type TracingOptions struct {
+	// Program must be of type Tracing, and have been loaded with an
+	// AttachType of AttachTraceFEntry, AttachTraceFExit or
+	// AttachModifyReturn.
+	Program *ebpf.Program
+}
+
+// AttachTracing links a fentry, fexit or fmod_ret program to the kernel
+// function it was loaded for.
+//
+// The kernel fixes the target function when the program is loaded, it is
+// chosen via ProgramSpec.AttachTo.
+//
+// Requires at least Linux 5.5.
+func AttachTracing(opts TracingOptions) (Link, error) {
+	if opts.Program == nil {
+		return nil, fmt.Errorf("prog cannot be nil: %w", errInvalidInput)
+	}
+	if t := opts.Program.Type(); t != ebpf.Tracing {
+		return nil, fmt.Errorf("eBPF program type %s is not Tracing: %w", t, errInvalidInput)
+	}
+
+	link, err := attachBTFID(opts.Program)
+	if err != nil {
+		return nil, fmt.Errorf("attach tracing: %w", err)
+	}
+
+	return link, nil
+}
+
+// LoadPinnedTracing loads a pinned Tracing or LSM link from a bpffs.
+func LoadPinnedTracing(fileName string, opts *ebpf.LoadPinOptions) (Link, error) {
+	link, err := LoadPinnedRawLink(fileName, TracingType, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tracing{*link}, nil
+}
+
+// attachBTFID attaches a program to the BTF ID it was loaded for.
+//
+// This uses BPF_RAW_TRACEPOINT_OPEN, which returns a bpf_link on every
+// kernel supporting such programs. BPF_LINK_CREATE only accepts them on
+// much newer kernels.
+func attachBTFID(prog *ebpf.Program) (*tracing, error) {
+	if prog.FD() < 0 {
+		return nil, fmt.Errorf("invalid program: %w", internal.ErrClosedFd)
+	}
+
+	fd, err := bpfRawTracepointOpen(&bpfRawTracepointOpenAttr{
+		fd: uint32(prog.FD()),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &tracing{RawLink{fd, ""}}, nil
+}
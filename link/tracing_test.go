@@ -0,0 +1,52 @@
+package link
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal/testutils"
+)
+
+func TestAttachTracing(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.5", "fentry and fexit programs")
+
+	for _, attach := range []ebpf.AttachType{ebpf.AttachTraceFEntry, ebpf.AttachTraceFExit} {
+		t.Run(attach.String(), func(t *testing.T) {
+			prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+				Type:       ebpf.Tracing,
+				AttachType: attach,
+				AttachTo:   "bpf_fentry_test1",
+				Instructions: asm.Instructions{
+					asm.LoadImm(asm.R0, 0, asm.DWord),
+					asm.Return(),
+				},
+				License: "GPL",
+			})
+			if err != nil {
+				testutils.SkipIfNotSupported(t, err)
+				t.Fatal(err)
+			}
+			defer prog.Close()
+
+			link, err := AttachTracing(TracingOptions{Program: prog})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			testLink(t, link, testLinkOptions{
+				prog:       prog,
+				loadPinned: LoadPinnedTracing,
+			})
+		})
+	}
+}
+
+func TestAttachTracingInvalidProgram(t *testing.T) {
+	prog := mustCgroupEgressProgram(t)
+
+	if _, err := AttachTracing(TracingOptions{Program: prog}); !errors.Is(err, errInvalidInput) {
+		t.Fatal("Expected errInvalidInput for non-Tracing program, got", err)
+	}
+}
@@ -1,6 +1,7 @@
 package link
 
 import (
+	"debug/dwarf"
 	"debug/elf"
 	"errors"
 	"fmt"
@@ -33,6 +34,9 @@ type Executable struct {
 	path string
 	// Parsed ELF symbols and dynamic symbols.
 	symbols map[string]elf.Symbol
+	// Executable segments, used to translate symbol addresses into
+	// file offsets.
+	segments []elf.ProgHeader
 }
 
 // UprobeOptions defines additional parameters that will be used
@@ -40,6 +44,7 @@ type Executable struct {
 type UprobeOptions struct {
 	// Symbol offset. Must be provided in case of external symbols (shared libs).
 	// If set, overrides the offset eventually parsed from the executable.
+	// See Executable.Offset and ResolveUprobeOffset.
 	Offset uint64
 	// Only set the uprobe on the given process ID. Useful when tracing
 	// shared library calls or programs that have many running instances.
@@ -79,6 +84,12 @@ func OpenExecutable(path string) (*Executable, error) {
 		return nil, err
 	}
 
+	for _, prog := range se.Progs {
+		if prog.Type == elf.PT_LOAD && prog.Flags&elf.PF_X != 0 {
+			ex.segments = append(ex.segments, prog.ProgHeader)
+		}
+	}
+
 	return &ex, nil
 }
 
@@ -105,6 +116,177 @@ func (ex *Executable) symbol(symbol string) (*elf.Symbol, error) {
 	return nil, fmt.Errorf("symbol %s not found", symbol)
 }
 
+// Offset returns the file offset of symbol, which is what the kernel expects
+// as the location of a uprobe.
+//
+// The value of an ELF symbol is a virtual address, which only matches the file
+// offset if the containing segment is mapped at its offset. The address is
+// translated using the executable segment containing it, which works for both
+// position independent and fixed address executables.
+//
+// Returns an error wrapping ErrNotSupported for symbols provided by shared
+// libraries, since their address is only known at runtime.
+func (ex *Executable) Offset(symbol string) (uint64, error) {
+	sym, err := ex.symbol(symbol)
+	if err != nil {
+		return 0, fmt.Errorf("symbol '%s' not found: %w", symbol, err)
+	}
+
+	// Symbols with location 0 from section undef are shared library calls and
+	// are relocated before the binary is executed. Dynamic linking is not
+	// implemented by the library, so mark this as unsupported for now.
+	if sym.Section == elf.SHN_UNDEF && sym.Value == 0 {
+		return 0, fmt.Errorf("cannot resolve %s library call '%s', "+
+			"consider providing the offset via options: %w", ex.path, symbol, ErrNotSupported)
+	}
+
+	return ex.addressOffset(sym.Value), nil
+}
+
+// addressOffset translates a virtual address into a file offset.
+func (ex *Executable) addressOffset(addr uint64) uint64 {
+	for _, seg := range ex.segments {
+		if seg.Vaddr <= addr && addr < seg.Vaddr+seg.Memsz {
+			return addr - seg.Vaddr + seg.Off
+		}
+	}
+
+	// Binaries without program headers aren't loaded by the kernel, their
+	// addresses are taken as is.
+	return addr
+}
+
+// InlinedOffsets returns the file offsets of all call sites function has been
+// inlined into, according to the DWARF debug information of the executable.
+//
+// Inlined call sites are not covered by the ELF symbol of a function, if it
+// has one at all. Returns an error if the executable has no DWARF information.
+func (ex *Executable) InlinedOffsets(function string) ([]uint64, error) {
+	f, err := os.Open(ex.path)
+	if err != nil {
+		return nil, fmt.Errorf("open file '%s': %w", ex.path, err)
+	}
+	defer f.Close()
+
+	se, err := internal.NewSafeELFFile(f)
+	if err != nil {
+		return nil, fmt.Errorf("parse ELF file: %w", err)
+	}
+
+	data, err := se.DWARF()
+	if err != nil {
+		return nil, fmt.Errorf("read DWARF of %s: %w", ex.path, err)
+	}
+
+	addrs, err := inlinedAddresses(data, function)
+	if err != nil {
+		return nil, fmt.Errorf("find inlined call sites of %s: %w", function, err)
+	}
+
+	offsets := make([]uint64, 0, len(addrs))
+	for _, addr := range addrs {
+		offsets = append(offsets, ex.addressOffset(addr))
+	}
+	return offsets, nil
+}
+
+// inlinedAddresses returns the start addresses of all inlined instances of
+// function.
+func inlinedAddresses(data *dwarf.Data, function string) ([]uint64, error) {
+	// Inlined instances refer to the abstract instance of a function via
+	// DW_AT_abstract_origin. The abstract instance itself may only refer to
+	// the declaration carrying the name via DW_AT_specification.
+	origins := make(map[dwarf.Offset]bool)
+	specifications := make(map[dwarf.Offset]dwarf.Offset)
+	var inlined []*dwarf.Entry
+
+	r := data.Reader()
+	for {
+		entry, err := r.Next()
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			break
+		}
+
+		switch entry.Tag {
+		case dwarf.TagSubprogram:
+			if name, _ := entry.Val(dwarf.AttrName).(string); name == function {
+				origins[entry.Offset] = true
+			}
+			if spec, ok := entry.Val(dwarf.AttrSpecification).(dwarf.Offset); ok {
+				specifications[entry.Offset] = spec
+			}
+
+		case dwarf.TagInlinedSubroutine:
+			inlined = append(inlined, entry)
+		}
+	}
+
+	for offset, spec := range specifications {
+		if origins[spec] {
+			origins[offset] = true
+		}
+	}
+
+	var addrs []uint64
+	for _, entry := range inlined {
+		origin, ok := entry.Val(dwarf.AttrAbstractOrigin).(dwarf.Offset)
+		if !ok || !origins[origin] {
+			continue
+		}
+
+		if pc, ok := entry.Val(dwarf.AttrEntrypc).(uint64); ok && entry.AttrField(dwarf.AttrEntrypc).Class == dwarf.ClassAddress {
+			addrs = append(addrs, pc)
+			continue
+		}
+
+		ranges, err := data.Ranges(entry)
+		if err != nil {
+			return nil, err
+		}
+		if len(ranges) > 0 {
+			addrs = append(addrs, ranges[0][0])
+		}
+	}
+
+	return addrs, nil
+}
+
+// ResolveUprobeOffset returns the file offset of symbol in the executable at
+// binaryPath, suitable for UprobeOptions.Offset.
+//
+// symbol is looked up in the ELF symbol tables first, see Executable.Offset.
+// Functions without a symbol, which happens if they have been inlined at
+// every call site, are looked up in the DWARF debug information instead. This
+// fails if the function has been inlined more than once, use
+// Executable.InlinedOffsets to retrieve all call sites.
+func ResolveUprobeOffset(binaryPath, symbol string) (uint64, error) {
+	ex, err := OpenExecutable(binaryPath)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := ex.symbol(symbol); err == nil {
+		return ex.Offset(symbol)
+	}
+
+	offsets, err := ex.InlinedOffsets(symbol)
+	if err != nil {
+		return 0, fmt.Errorf("symbol '%s' not found: %w", symbol, err)
+	}
+
+	switch len(offsets) {
+	case 0:
+		return 0, fmt.Errorf("symbol '%s' not found", symbol)
+	case 1:
+		return offsets[0], nil
+	default:
+		return 0, fmt.Errorf("symbol '%s' is inlined at %d call sites", symbol, len(offsets))
+	}
+}
+
 // Uprobe attaches the given eBPF program to a perf event that fires when the
 // given symbol starts executing in the given Executable.
 // For example, /bin/bash::main():
@@ -184,20 +366,11 @@ func (ex *Executable) uprobe(symbol string, prog *ebpf.Program, opts *UprobeOpti
 	if opts != nil && opts.Offset != 0 {
 		offset = opts.Offset
 	} else {
-		sym, err := ex.symbol(symbol)
+		var err error
+		offset, err = ex.Offset(symbol)
 		if err != nil {
-			return nil, fmt.Errorf("symbol '%s' not found: %w", symbol, err)
-		}
-
-		// Symbols with location 0 from section undef are shared library calls and
-		// are relocated before the binary is executed. Dynamic linking is not
-		// implemented by the library, so mark this as unsupported for now.
-		if sym.Section == elf.SHN_UNDEF && sym.Value == 0 {
-			return nil, fmt.Errorf("cannot resolve %s library call '%s', "+
-				"consider providing the offset via options: %w", ex.path, symbol, ErrNotSupported)
+			return nil, err
 		}
-
-		offset = sym.Value
 	}
 
 	pid := perfAllThreads
@@ -1,6 +1,7 @@
 package link
 
 import (
+	"debug/elf"
 	"errors"
 	"fmt"
 	"os"
@@ -41,6 +42,93 @@ func TestExecutable(t *testing.T) {
 	}
 }
 
+func TestExecutableOffset(t *testing.T) {
+	f, err := elf.Open("/bin/bash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	offset, err := bashEx.Offset(bashSym)
+	if err != nil {
+		t.Fatal("Can't get offset:", err)
+	}
+
+	// Compute the offset via the section headers instead of the segments.
+	sym, err := bashEx.symbol(bashSym)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sec := f.Sections[sym.Section]
+	if want := sym.Value - sec.Addr + sec.Offset; offset != want {
+		t.Errorf("Expected offset %#x, got %#x", want, offset)
+	}
+
+	if _, err := bashEx.Offset("open"); !errors.Is(err, ErrNotSupported) {
+		t.Error("Expected ErrNotSupported for a library call, got", err)
+	}
+}
+
+func TestResolveUprobeOffset(t *testing.T) {
+	want, err := bashEx.Offset(bashSym)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	offset, err := ResolveUprobeOffset("/bin/bash", bashSym)
+	if err != nil {
+		t.Fatal("Can't resolve offset:", err)
+	}
+	if offset != want {
+		t.Errorf("Expected offset %#x, got %#x", want, offset)
+	}
+
+	if _, err := ResolveUprobeOffset("/bin/bash", "bogus"); err == nil {
+		t.Error("Resolving a missing symbol doesn't return an error")
+	}
+}
+
+func TestExecutableInlinedOffsets(t *testing.T) {
+	ex, err := OpenExecutable("../testdata/uprobe_inline.elf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caller, err := ex.Offset("caller")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sym, err := ex.symbol("caller")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	offsets, err := ex.InlinedOffsets("inlined_twice")
+	if err != nil {
+		t.Fatal("Can't get inlined offsets:", err)
+	}
+	if len(offsets) != 2 {
+		t.Fatalf("Expected two call sites, got %#x", offsets)
+	}
+	for _, offset := range offsets {
+		if offset < caller || offset >= caller+sym.Size {
+			t.Errorf("Call site %#x is outside of caller at %#x", offset, caller)
+		}
+	}
+
+	if _, err := ResolveUprobeOffset("../testdata/uprobe_inline.elf", "inlined_twice"); err == nil {
+		t.Error("Resolving a function inlined twice doesn't return an error")
+	}
+
+	offset, err := ResolveUprobeOffset("../testdata/uprobe_inline.elf", "inlined_once")
+	if err != nil {
+		t.Fatal("Can't resolve inlined function:", err)
+	}
+	if offset < caller || offset >= caller+sym.Size {
+		t.Errorf("Call site %#x is outside of caller at %#x", offset, caller)
+	}
+}
+
 func TestUprobe(t *testing.T) {
 	c := qt.New(t)
 
@@ -0,0 +1,155 @@
+package link
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/internal"
+)
+
+// XDPAttachFlags control how an XDP program is attached to an interface.
+type XDPAttachFlags uint32
+
+// Equivalent to XDP_FLAGS_*_MODE.
+const (
+	// XDPGenericMode attaches the program to the generic receive path,
+	// which works for drivers without native XDP support.
+	XDPGenericMode XDPAttachFlags = 1 << (iota + 1)
+	// XDPDriverMode attaches the program to the receive path of the driver.
+	XDPDriverMode
+	// XDPOffloadMode offloads the program to the network card.
+	XDPOffloadMode
+)
+
+// AttachXDP attaches an XDP program to the network interface with the given
+// index.
+//
+// Passing zero flags lets the kernel pick a mode, preferring XDPDriverMode.
+// The program is detached when the Link is closed, unless it has been pinned.
+//
+// Kernels older than Linux 5.9 don't support XDP links. On those the program
+// is attached via netlink instead, which requires at least Linux 4.12. Such a
+// Link can't be pinned, and closing it detaches whichever program is
+// attached to the interface in the given mode at that time.
+func AttachXDP(ifindex int, prog *ebpf.Program, flags XDPAttachFlags) (Link, error) {
+	if t := prog.Type(); t != ebpf.XDP {
+		return nil, fmt.Errorf("invalid program type %s, expected XDP", t)
+	}
+
+	if ifindex < 1 {
+		return nil, fmt.Errorf("invalid interface index %d", ifindex)
+	}
+
+	if err := haveXDPLink(); errors.Is(err, ErrNotSupported) {
+		return attachXDPNetlink(ifindex, prog, flags)
+	} else if err != nil {
+		return nil, err
+	}
+
+	link, err := AttachRawLink(RawLinkOptions{
+		Target:  ifindex,
+		Program: prog,
+		Attach:  ebpf.AttachXDP,
+		Flags:   uint32(flags),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("attach XDP: %w", err)
+	}
+
+	return link, nil
+}
+
+// LoadPinnedXDP loads an XDP link from bpffs.
+func LoadPinnedXDP(fileName string, opts *ebpf.LoadPinOptions) (Link, error) {
+	return LoadPinnedRawLink(fileName, XDPType, opts)
+}
+
+// Constants from <linux/if_link.h>.
+const (
+	iflaXDP                 = 0x2b
+	iflaXDPFD               = 0x1
+	iflaXDPFlags            = 0x3
+	xdpFlagsUpdateIfNoExist = 0x1
+)
+
+// xdpNetlink is an XDP program attached via netlink.
+type xdpNetlink struct {
+	ifindex int
+	current *ebpf.Program
+	flags   XDPAttachFlags
+}
+
+var _ Link = (*xdpNetlink)(nil)
+
+func attachXDPNetlink(ifindex int, prog *ebpf.Program, flags XDPAttachFlags) (*xdpNetlink, error) {
+	current, err := prog.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	// Don't replace a program attached by somebody else, which is
+	// consistent with XDP links.
+	if err := setXDPNetlink(ifindex, current.FD(), uint32(flags)|xdpFlagsUpdateIfNoExist); err != nil {
+		current.Close()
+		return nil, fmt.Errorf("attach XDP via netlink: %w", err)
+	}
+
+	return &xdpNetlink{ifindex, current, flags}, nil
+}
+
+// setXDPNetlink attaches the program with the given fd to an interface,
+// or detaches the current program if fd is -1.
+func setXDPNetlink(ifindex, fd int, flags uint32) error {
+	// struct ifinfomsg
+	body := make([]byte, 16)
+	internal.NativeEndian.PutUint32(body[4:], uint32(ifindex))
+
+	msg := netlinkMessage{typ: rtmSetLink, body: body}
+	msg.nested(iflaXDP, func() {
+		msg.uint32Attr(iflaXDPFD, uint32(fd))
+		msg.uint32Attr(iflaXDPFlags, flags)
+	})
+
+	_, err := netlinkRequest(&msg)
+	return err
+}
+
+func (xdp *xdpNetlink) isLink() {}
+
+func (xdp *xdpNetlink) Close() error {
+	defer xdp.current.Close()
+
+	if err := setXDPNetlink(xdp.ifindex, -1, uint32(xdp.flags)); err != nil {
+		return fmt.Errorf("close XDP: %w", err)
+	}
+	return nil
+}
+
+func (xdp *xdpNetlink) Update(prog *ebpf.Program) error {
+	new, err := prog.Clone()
+	if err != nil {
+		return err
+	}
+
+	if err := setXDPNetlink(xdp.ifindex, new.FD(), uint32(xdp.flags)); err != nil {
+		new.Close()
+		return fmt.Errorf("update XDP: %w", err)
+	}
+
+	xdp.current.Close()
+	xdp.current = new
+	return nil
+}
+
+func (xdp *xdpNetlink) Pin(string) error {
+	return fmt.Errorf("can't pin XDP attached via netlink: %w", ErrNotSupported)
+}
+
+func (xdp *xdpNetlink) Unpin() error {
+	return fmt.Errorf("can't unpin XDP attached via netlink: %w", ErrNotSupported)
+}
+
+func (xdp *xdpNetlink) Info() (*Info, error) {
+	return nil, fmt.Errorf("can't get info of XDP attached via netlink: %w", ErrNotSupported)
+}
@@ -0,0 +1,92 @@
+package link
+
+import (
+	"net"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal/testutils"
+)
+
+func TestAttachXDPNetlink(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "4.12", "XDP flags")
+
+	prog := mustCreateXDPProgram(t)
+
+	iface, err := net.InterfaceByName("lo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	link, err := attachXDPNetlink(iface.Index, prog, XDPGenericMode)
+	if err != nil {
+		t.Fatal("Can't attach XDP program:", err)
+	}
+
+	if _, err := attachXDPNetlink(iface.Index, prog, XDPGenericMode); err == nil {
+		t.Error("Attaching via netlink replaces an existing program")
+	}
+
+	testLink(t, link, testLinkOptions{prog: prog})
+
+	link, err = attachXDPNetlink(iface.Index, prog, XDPGenericMode)
+	if err != nil {
+		t.Fatal("Close doesn't detach the program:", err)
+	}
+	if err := link.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAttachXDP(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.9", "BPF_LINK_TYPE_XDP")
+
+	prog := mustCreateXDPProgram(t)
+
+	iface, err := net.InterfaceByName("lo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	link, err := AttachXDP(iface.Index, prog, XDPGenericMode)
+	if err != nil {
+		t.Fatal("Can't attach XDP program:", err)
+	}
+
+	testLink(t, link, testLinkOptions{
+		prog: prog,
+		loadPinned: func(fileName string, opts *ebpf.LoadPinOptions) (Link, error) {
+			return LoadPinnedXDP(fileName, opts)
+		},
+	})
+}
+
+func TestAttachXDPInvalidProgram(t *testing.T) {
+	prog := mustCreateSkLookupProgram(t)
+
+	if _, err := AttachXDP(1, prog, 0); err == nil {
+		t.Fatal("AttachXDP accepts a program that isn't XDP")
+	}
+}
+
+func mustCreateXDPProgram(tb testing.TB) *ebpf.Program {
+	tb.Helper()
+
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		Type:       ebpf.XDP,
+		AttachType: ebpf.AttachXDP,
+		License:    "MIT",
+		Instructions: asm.Instructions{
+			// XDP_PASS
+			asm.Mov.Imm(asm.R0, 2),
+			asm.Return(),
+		},
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { prog.Close() })
+
+	return prog
+}
@@ -2,19 +2,22 @@ package ebpf
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"os"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"strings"
 
+	"github.com/cilium/ebpf/btf"
 	"github.com/cilium/ebpf/internal"
-	"github.com/cilium/ebpf/internal/btf"
 	"github.com/cilium/ebpf/internal/unix"
 )
 
-// Errors returned by Map and MapIterator methods.
+// Errors returned by Map and MapIter methods.
 var (
 	ErrKeyNotExist      = errors.New("key does not exist")
 	ErrKeyExist         = errors.New("key already exists")
@@ -120,19 +123,46 @@ type MapKV struct {
 	Value interface{}
 }
 
-func (ms *MapSpec) checkCompatibility(m *Map) error {
+// Compatible returns nil if an existing map can be used in place of
+// creating a new one from the spec.
+//
+// Fields which are filled in by the library when creating a map, like the
+// key size of a PerfEventArray, may be zero in the spec.
+//
+// Returns an error wrapping ErrMapIncompatible otherwise.
+func (ms *MapSpec) Compatible(m *Map) error {
+	keySize, valueSize, maxEntries := ms.KeySize, ms.ValueSize, ms.MaxEntries
+	switch ms.Type {
+	case PerfEventArray:
+		if keySize == 0 {
+			keySize = 4
+		}
+		if valueSize == 0 {
+			valueSize = 4
+		}
+		if maxEntries == 0 {
+			// Defaults to the number of possible CPUs.
+			maxEntries = m.maxEntries
+		}
+
+	case ArrayOfMaps, HashOfMaps:
+		if valueSize == 0 {
+			valueSize = 4
+		}
+	}
+
 	switch {
 	case m.typ != ms.Type:
 		return fmt.Errorf("expected type %v, got %v: %w", ms.Type, m.typ, ErrMapIncompatible)
 
-	case m.keySize != ms.KeySize:
-		return fmt.Errorf("expected key size %v, got %v: %w", ms.KeySize, m.keySize, ErrMapIncompatible)
+	case m.keySize != keySize:
+		return fmt.Errorf("expected key size %v, got %v: %w", keySize, m.keySize, ErrMapIncompatible)
 
-	case m.valueSize != ms.ValueSize:
-		return fmt.Errorf("expected value size %v, got %v: %w", ms.ValueSize, m.valueSize, ErrMapIncompatible)
+	case m.valueSize != valueSize:
+		return fmt.Errorf("expected value size %v, got %v: %w", valueSize, m.valueSize, ErrMapIncompatible)
 
-	case m.maxEntries != ms.MaxEntries:
-		return fmt.Errorf("expected max entries %v, got %v: %w", ms.MaxEntries, m.maxEntries, ErrMapIncompatible)
+	case m.maxEntries != maxEntries:
+		return fmt.Errorf("expected max entries %v, got %v: %w", maxEntries, m.maxEntries, ErrMapIncompatible)
 
 	case m.flags != ms.Flags:
 		return fmt.Errorf("expected flags %v, got %v: %w", ms.Flags, m.flags, ErrMapIncompatible)
@@ -177,7 +207,7 @@ func newMapFromFD(fd *internal.FD) (*Map, error) {
 	info, err := newMapInfoFromFd(fd)
 	if err != nil {
 		fd.Close()
-		return nil, fmt.Errorf("get map info: %s", err)
+		return nil, fmt.Errorf("get map info: %w", err)
 	}
 
 	return newMap(fd, info.Name, info.Type, info.KeySize, info.ValueSize, info.MaxEntries, info.Flags)
@@ -190,6 +220,26 @@ func NewMap(spec *MapSpec) (*Map, error) {
 	return NewMapWithOptions(spec, MapOptions{})
 }
 
+// NewMapWithBTF creates a new Map which is annotated with BTF for the
+// given key and value types.
+//
+// The BTF is generated from the types, which allows creating maps that
+// tools like bpftool can introspect without compiling C. Use btf.Encoder
+// to obtain types from Go values. spec.BTF is ignored.
+//
+// As with NewMap, the map is created without BTF if the kernel doesn't
+// support it.
+func NewMapWithBTF(spec *MapSpec, keyType, valueType btf.Type) (*Map, error) {
+	m, err := btf.NewMapFromTypes(keyType, valueType)
+	if err != nil {
+		return nil, fmt.Errorf("creating map: %w", err)
+	}
+
+	spec = spec.Copy()
+	spec.BTF = m
+	return NewMap(spec)
+}
+
 // NewMapWithOptions creates a new Map.
 //
 // Creating a map for the first time will perform feature detection
@@ -240,7 +290,7 @@ func newMapWithOptions(spec *MapSpec, opts MapOptions, handles *handleCache) (_
 		}
 		defer closeOnError(m)
 
-		if err := spec.checkCompatibility(m); err != nil {
+		if err := spec.Compatible(m); err != nil {
 			return nil, fmt.Errorf("use pinned map %s: %w", spec.Name, err)
 		}
 
@@ -437,7 +487,7 @@ func newMap(fd *internal.FD, name string, typ MapType, keySize, valueSize, maxEn
 		return nil, err
 	}
 
-	m.fullValueSize = align(int(valueSize), 8) * possibleCPUs
+	m.fullValueSize = internal.Align(int(valueSize), 8) * possibleCPUs
 	return m, nil
 }
 
@@ -448,6 +498,15 @@ func (m *Map) String() string {
 	return fmt.Sprintf("%s#%v", m.typ, m.fd)
 }
 
+// Name returns the name of the map.
+//
+// This is the name supplied via MapSpec.Name, truncated to the 15
+// characters the kernel keeps, or the name reported by the kernel for maps
+// obtained by ID, from a raw fd or from a pin.
+func (m *Map) Name() string {
+	return internal.TruncateObjName(m.name)
+}
+
 // Type returns the underlying type of the map.
 func (m *Map) Type() MapType {
 	return m.typ
@@ -484,6 +543,8 @@ func (m *Map) Info() (*MapInfo, error) {
 // and *valueOut is not nil.
 //
 // Returns an error if the key doesn't exist, see ErrKeyNotExist.
+//
+// Use BloomFilterMap to test whether a bloom filter contains a value.
 func (m *Map) Lookup(key, valueOut interface{}) error {
 	valuePtr, valueBytes := makeBuffer(valueOut, m.fullValueSize)
 	if err := m.lookup(key, valuePtr); err != nil {
@@ -495,6 +556,10 @@ func (m *Map) Lookup(key, valueOut interface{}) error {
 
 // LookupAndDelete retrieves and deletes a value from a Map.
 //
+// Queue and Stack maps support this from 4.20, in which case key must be nil.
+// Other map types like Hash require at least 5.14, ErrNotSupported is returned
+// on older kernels.
+//
 // Returns ErrKeyNotExist if the key doesn't exist.
 func (m *Map) LookupAndDelete(key, valueOut interface{}) error {
 	valuePtr, valueBytes := makeBuffer(valueOut, m.fullValueSize)
@@ -559,6 +624,60 @@ func (m *Map) Put(key, value interface{}) error {
 	return m.Update(key, value, UpdateAny)
 }
 
+// Insert creates a value in map.
+//
+// It is equivalent to calling Update with UpdateNoExist.
+// Returns ErrKeyExist if the key already exists.
+func (m *Map) Insert(key, value interface{}) error {
+	return m.Update(key, value, UpdateNoExist)
+}
+
+// getOrInsertAttempts is the number of times GetOrInsert retries if the
+// key is concurrently deleted or inserted.
+const getOrInsertAttempts = 3
+
+// GetOrInsert retrieves the value of a key, or creates it if it doesn't
+// exist yet.
+//
+// valueInOut must be a pointer. It contains the value to insert, and is
+// overwritten with the current value if the key exists. existed reports
+// which of the two happened.
+//
+// This isn't atomic: the value is looked up first and inserted if it's
+// missing, which is retried if another process modifies the key in
+// between. Races are unlikely if only a single writer creates keys.
+// Per-CPU maps avoid contention between BPF programs running on
+// different CPUs, but not between user space and BPF.
+func (m *Map) GetOrInsert(key, valueInOut interface{}) (existed bool, err error) {
+	for i := 0; i < getOrInsertAttempts; i++ {
+		err = m.Lookup(key, valueInOut)
+		if err == nil {
+			return true, nil
+		}
+		if !errors.Is(err, ErrKeyNotExist) {
+			return false, err
+		}
+
+		err = m.Insert(key, valueInOut)
+		if err == nil {
+			return false, nil
+		}
+		if !errors.Is(err, ErrKeyExist) {
+			return false, err
+		}
+	}
+
+	return false, fmt.Errorf("key is modified concurrently, giving up after %d attempts: %w", getOrInsertAttempts, err)
+}
+
+// Replace changes an existing value in map.
+//
+// It is equivalent to calling Update with UpdateExist.
+// Returns ErrKeyNotExist if the key does not exist.
+func (m *Map) Replace(key, value interface{}) error {
+	return m.Update(key, value, UpdateExist)
+}
+
 // Update changes the value of a key.
 func (m *Map) Update(key, value interface{}, flags MapUpdateFlags) error {
 	keyPtr, err := m.marshalKey(key)
@@ -631,6 +750,11 @@ func (m *Map) NextKeyBytes(key interface{}) ([]byte, error) {
 }
 
 func (m *Map) nextKey(key interface{}, nextKeyOut internal.Pointer) error {
+	if m.keySize == 0 {
+		// Bloom filters, queues and stacks.
+		return fmt.Errorf("next key: %s has no keys", m.typ)
+	}
+
 	var (
 		keyPtr internal.Pointer
 		err    error
@@ -649,16 +773,65 @@ func (m *Map) nextKey(key interface{}, nextKeyOut internal.Pointer) error {
 	return nil
 }
 
+// MapBatchCursor holds the position of a batch lookup. The zero value
+// starts at the first element of a map.
+//
+// A cursor must only be used with a single map.
+type MapBatchCursor struct {
+	opaque []byte
+}
+
+// LookupBatch looks up many elements in a map at once, starting at cursor.
+//
+// "keysOut" and "valuesOut" must be of type slice, a pointer
+// to a slice or buffer will not work. The cursor is advanced past the
+// returned elements.
+//
+// Returns the number of elements written to keysOut and valuesOut, and
+// whether the cursor can be passed to LookupBatch again to retrieve
+// more elements.
+//
+// See BatchLookup for further caveats.
+func (m *Map) LookupBatch(cursor *MapBatchCursor, keysOut, valuesOut interface{}, opts *BatchOptions) (int, bool, error) {
+	var prevKey interface{}
+	if cursor.opaque != nil {
+		prevKey = cursor.opaque
+	}
+
+	var next []byte
+	n, err := m.BatchLookup(prevKey, &next, keysOut, valuesOut, opts)
+	if errors.Is(err, ErrKeyNotExist) {
+		return n, false, nil
+	}
+	if err != nil {
+		return n, false, err
+	}
+
+	cursor.opaque = next
+	return n, true, nil
+}
+
 // BatchLookup looks up many elements in a map at once.
 //
 // "keysOut" and "valuesOut" must be of type slice, a pointer
 // to a slice or buffer will not work.
-// "prevKey" is the key to start the batch lookup from, it will
-// *not* be included in the results. Use nil to start at the first key.
+// "prevKey" is the position to start the batch lookup from, it will
+// *not* be included in the results. Use nil to start at the first key,
+// or the value of "nextKeyOut" from a previous call to continue a lookup.
+//
+// "nextKeyOut" is an opaque cursor. For hash maps the kernel returns a
+// 4 byte bucket index instead of a key, which means that prevKey and
+// nextKeyOut must hold at least 4 bytes even if the key is smaller.
+// LookupBatch takes care of this.
 //
 // ErrKeyNotExist is returned when the batch lookup has reached
 // the end of all possible results, even when partial results
 // are returned. It should be used to evaluate when lookup is "done".
+//
+// On kernels without support for batch operations (before 5.6) the lookup
+// is emulated by looking up individual elements. In that case the result
+// isn't a consistent snapshot of the map, and "nextKeyOut" contains the
+// last key in "keysOut", padded to the same size as a cursor.
 func (m *Map) BatchLookup(prevKey, nextKeyOut, keysOut, valuesOut interface{}, opts *BatchOptions) (int, error) {
 	return m.batchLookup(internal.BPF_MAP_LOOKUP_BATCH, prevKey, nextKeyOut, keysOut, valuesOut, opts)
 }
@@ -679,8 +852,10 @@ func (m *Map) BatchLookupAndDelete(prevKey, nextKeyOut, keysOut, valuesOut inter
 }
 
 func (m *Map) batchLookup(cmd internal.BPFCmd, startKey, nextKeyOut, keysOut, valuesOut interface{}, opts *BatchOptions) (int, error) {
-	if err := haveBatchAPI(); err != nil {
-		return 0, err
+	batchErr := haveBatchAPI()
+	if batchErr != nil && cmd != internal.BPF_MAP_LOOKUP_BATCH {
+		// Deleting elements one by one isn't atomic, so there is no fallback.
+		return 0, batchErr
 	}
 	if m.typ.hasPerCPUValue() {
 		return 0, ErrNotSupported
@@ -708,25 +883,50 @@ func (m *Map) batchLookup(cmd internal.BPFCmd, startKey, nextKeyOut, keysOut, va
 		retErr   error
 	)
 	if startKey != nil {
-		startPtr, err = marshalPtr(startKey, int(m.keySize))
+		startPtr, err = marshalPtr(startKey, m.batchCursorSize())
 		if err != nil {
 			return 0, err
 		}
 	}
-	nextPtr, nextBuf := makeBuffer(nextKeyOut, int(m.keySize))
 
-	ct, err := bpfMapBatch(cmd, m.fd, startPtr, nextPtr, keyPtr, valuePtr, uint32(count), opts)
-	if err != nil {
-		if !errors.Is(err, ErrKeyNotExist) {
+	var ct int
+	if batchErr != nil {
+		var lastKey []byte
+		ct, lastKey, err = m.batchLookupFallback(startPtr, keyBuf, valueBuf, count)
+		if err != nil {
+			if !errors.Is(err, ErrKeyNotExist) {
+				return 0, err
+			}
+			retErr = ErrKeyNotExist
+		}
+
+		if lastKey != nil {
+			// Use the same size as the cursor returned by the kernel, so
+			// that it can be passed back as startKey.
+			cursor := make([]byte, m.batchCursorSize())
+			copy(cursor, lastKey)
+			if err := unmarshalBytes(nextKeyOut, cursor); err != nil {
+				return 0, err
+			}
+		}
+	} else {
+		nextPtr, nextBuf := makeBuffer(nextKeyOut, m.batchCursorSize())
+
+		n, err := bpfMapBatch(cmd, m.fd, startPtr, nextPtr, keyPtr, valuePtr, uint32(count), opts)
+		if err != nil {
+			if !errors.Is(err, ErrKeyNotExist) {
+				return 0, err
+			}
+			retErr = ErrKeyNotExist
+		}
+		ct = int(n)
+
+		err = m.unmarshalKey(nextKeyOut, nextBuf)
+		if err != nil {
 			return 0, err
 		}
-		retErr = ErrKeyNotExist
 	}
 
-	err = m.unmarshalKey(nextKeyOut, nextBuf)
-	if err != nil {
-		return 0, err
-	}
 	err = unmarshalBytes(keysOut, keyBuf)
 	if err != nil {
 		return 0, err
@@ -735,17 +935,74 @@ func (m *Map) batchLookup(cmd internal.BPFCmd, startKey, nextKeyOut, keysOut, va
 	if err != nil {
 		retErr = err
 	}
-	return int(ct), retErr
+	return ct, retErr
+}
+
+// batchLookupFallback emulates BPF_MAP_LOOKUP_BATCH using BPF_MAP_GET_NEXT_KEY
+// and BPF_MAP_LOOKUP_ELEM.
+//
+// Returns the number of elements written to keyBuf and valueBuf, as well as
+// the last key that was looked up. The latter can be used to resume the lookup.
+func (m *Map) batchLookupFallback(startPtr internal.Pointer, keyBuf, valueBuf []byte, count int) (int, []byte, error) {
+	var (
+		keySize   = int(m.keySize)
+		valueSize = int(m.fullValueSize)
+		prevPtr   = startPtr
+		lastKey   []byte
+		skipped   []byte
+		n         int
+	)
+
+	for n < count {
+		key := keyBuf[n*keySize : (n+1)*keySize]
+		keyPtr := internal.NewSlicePointer(key)
+		if err := bpfMapGetNextKey(m.fd, prevPtr, keyPtr); err != nil {
+			return n, lastKey, err
+		}
+
+		valuePtr := internal.NewSlicePointer(valueBuf[n*valueSize : (n+1)*valueSize])
+		err := bpfMapLookupElem(m.fd, keyPtr, valuePtr)
+		if errors.Is(err, ErrKeyNotExist) && m.typ.hasFixedKeys() {
+			// Empty slots of fd arrays can't be looked up, but the key
+			// still exists. Continue after it, otherwise the same key
+			// is returned forever. key is overwritten by the next
+			// iteration, so it needs to be copied.
+			if skipped == nil {
+				skipped = make([]byte, keySize)
+			}
+			copy(skipped, key)
+			prevPtr = internal.NewSlicePointer(skipped)
+			continue
+		}
+		if errors.Is(err, ErrKeyNotExist) {
+			// The element was deleted after we retrieved its key.
+			// Continue from the last key that still existed, since
+			// hash maps start over when iterating from a missing key.
+			continue
+		}
+		if err != nil {
+			return n, lastKey, err
+		}
+
+		prevPtr = keyPtr
+		lastKey = key
+		n++
+	}
+
+	return n, lastKey, nil
 }
 
 // BatchUpdate updates the map with multiple keys and values
 // simultaneously.
 // "keys" and "values" must be of type slice, a pointer
 // to a slice or buffer will not work.
+//
+// Returns the number of elements that were updated, even if an error
+// occurs part way through.
+//
+// On kernels without support for batch operations (before 5.6) elements
+// are updated one by one.
 func (m *Map) BatchUpdate(keys, values interface{}, opts *BatchOptions) (int, error) {
-	if err := haveBatchAPI(); err != nil {
-		return 0, err
-	}
 	if m.typ.hasPerCPUValue() {
 		return 0, ErrNotSupported
 	}
@@ -765,6 +1022,9 @@ func (m *Map) BatchUpdate(keys, values interface{}, opts *BatchOptions) (int, er
 	if count != valuesValue.Len() {
 		return 0, fmt.Errorf("keys and values must be the same length")
 	}
+	if err := haveBatchAPI(); err != nil {
+		return m.batchUpdateFallback(keys, values, count, opts)
+	}
 	keyPtr, err := marshalPtr(keys, count*int(m.keySize))
 	if err != nil {
 		return 0, err
@@ -778,12 +1038,44 @@ func (m *Map) BatchUpdate(keys, values interface{}, opts *BatchOptions) (int, er
 	return int(ct), err
 }
 
+// batchUpdateFallback emulates BPF_MAP_UPDATE_BATCH using BPF_MAP_UPDATE_ELEM.
+func (m *Map) batchUpdateFallback(keys, values interface{}, count int, opts *BatchOptions) (int, error) {
+	keySize, valueSize := int(m.keySize), int(m.valueSize)
+	keyBuf, err := marshalBytes(keys, count*keySize)
+	if err != nil {
+		return 0, err
+	}
+	valueBuf, err := marshalBytes(values, count*valueSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var flags uint64
+	if opts != nil {
+		flags = opts.ElemFlags
+	}
+
+	for i := 0; i < count; i++ {
+		keyPtr := internal.NewSlicePointer(keyBuf[i*keySize : (i+1)*keySize])
+		valuePtr := internal.NewSlicePointer(valueBuf[i*valueSize : (i+1)*valueSize])
+		if err := bpfMapUpdateElem(m.fd, keyPtr, valuePtr, flags); err != nil {
+			return i, err
+		}
+	}
+
+	return count, nil
+}
+
 // BatchDelete batch deletes entries in the map by keys.
 // "keys" must be of type slice, a pointer to a slice or buffer will not work.
+//
+// Returns the number of elements that were deleted, even if an error
+// occurs part way through. Deletion stops at the first key that can't be
+// deleted, for example because it doesn't exist.
+//
+// On kernels without support for batch operations (before 5.6) elements
+// are deleted one by one.
 func (m *Map) BatchDelete(keys interface{}, opts *BatchOptions) (int, error) {
-	if err := haveBatchAPI(); err != nil {
-		return 0, err
-	}
 	if m.typ.hasPerCPUValue() {
 		return 0, ErrNotSupported
 	}
@@ -792,6 +1084,9 @@ func (m *Map) BatchDelete(keys interface{}, opts *BatchOptions) (int, error) {
 		return 0, fmt.Errorf("keys must be a slice")
 	}
 	count := keysValue.Len()
+	if err := haveBatchAPI(); err != nil {
+		return m.batchDeleteFallback(keys, count)
+	}
 	keyPtr, err := marshalPtr(keys, count*int(m.keySize))
 	if err != nil {
 		return 0, fmt.Errorf("cannot marshal keys: %v", err)
@@ -801,14 +1096,195 @@ func (m *Map) BatchDelete(keys interface{}, opts *BatchOptions) (int, error) {
 	return int(ct), err
 }
 
+// batchDeleteFallback emulates BPF_MAP_DELETE_BATCH using BPF_MAP_DELETE_ELEM.
+func (m *Map) batchDeleteFallback(keys interface{}, count int) (int, error) {
+	keySize := int(m.keySize)
+	keyBuf, err := marshalBytes(keys, count*keySize)
+	if err != nil {
+		return 0, fmt.Errorf("cannot marshal keys: %v", err)
+	}
+
+	for i := 0; i < count; i++ {
+		keyPtr := internal.NewSlicePointer(keyBuf[i*keySize : (i+1)*keySize])
+		if err := bpfMapDeleteElem(m.fd, keyPtr); err != nil {
+			return i, err
+		}
+	}
+
+	return count, nil
+}
+
+// MapDiffKind describes how an entry differs between two maps.
+type MapDiffKind uint8
+
+const (
+	// MapDiffAdded is an entry which only exists in the other map.
+	MapDiffAdded MapDiffKind = iota + 1
+	// MapDiffRemoved is an entry which only exists in the map.
+	MapDiffRemoved
+	// MapDiffChanged is an entry which exists in both maps with
+	// different values.
+	MapDiffChanged
+)
+
+// MapDiff is an entry which differs between two maps.
+type MapDiff struct {
+	Kind MapDiffKind
+	Key  []byte
+	// Value in the map, nil for MapDiffAdded.
+	Value []byte
+	// OtherValue in the other map, nil for MapDiffRemoved.
+	OtherValue []byte
+}
+
+// Diff compares the contents of two maps, returning the changes which
+// turn m into other, sorted by key.
+//
+// Both maps must have the same type, key and value size. Keys and values
+// are compared as raw bytes. The contents are read using batch lookups if
+// the kernel supports them, which makes the comparison consistent for
+// each batch. Concurrent modifications may still lead to spurious differences.
+func (m *Map) Diff(other *Map) ([]MapDiff, error) {
+	if m.typ != other.typ || m.keySize != other.keySize || m.valueSize != other.valueSize {
+		return nil, fmt.Errorf("diff: %s and %s are not compatible", m, other)
+	}
+
+	entries, err := m.dumpBytes()
+	if err != nil {
+		return nil, fmt.Errorf("diff: %w", err)
+	}
+
+	otherEntries, err := other.dumpBytes()
+	if err != nil {
+		return nil, fmt.Errorf("diff: %w", err)
+	}
+
+	var diffs []MapDiff
+	for key, value := range entries {
+		otherValue, ok := otherEntries[key]
+		switch {
+		case !ok:
+			diffs = append(diffs, MapDiff{MapDiffRemoved, []byte(key), value, nil})
+		case !bytes.Equal(value, otherValue):
+			diffs = append(diffs, MapDiff{MapDiffChanged, []byte(key), value, otherValue})
+		}
+	}
+
+	for key, otherValue := range otherEntries {
+		if _, ok := entries[key]; !ok {
+			diffs = append(diffs, MapDiff{MapDiffAdded, []byte(key), nil, otherValue})
+		}
+	}
+
+	sort.Slice(diffs, func(i, j int) bool {
+		return bytes.Compare(diffs[i].Key, diffs[j].Key) < 0
+	})
+
+	return diffs, nil
+}
+
+// dumpBytes reads all entries of the map, keyed by the raw key.
+//
+// Uses BPF_MAP_LOOKUP_BATCH if possible, otherwise falls back to
+// looking up elements one by one.
+func (m *Map) dumpBytes() (map[string][]byte, error) {
+	count := int(m.maxEntries)
+	if count > 1024 {
+		count = 1024
+	} else if count == 0 {
+		count = 1
+	}
+
+	var (
+		keySize   = int(m.keySize)
+		valueSize = m.fullValueSize
+		keyBuf    = make([]byte, count*keySize)
+		valueBuf  = make([]byte, count*valueSize)
+		prevKey   = make([]byte, m.batchCursorSize())
+		nextKey   = make([]byte, m.batchCursorSize())
+		startPtr  internal.Pointer
+		first     = true
+		useBatch  = haveBatchAPI() == nil
+		entries   = make(map[string][]byte)
+	)
+
+	for {
+		var (
+			n   int
+			err error
+		)
+
+		if useBatch {
+			var ct uint32
+			ct, err = bpfMapBatch(internal.BPF_MAP_LOOKUP_BATCH, m.fd, startPtr,
+				internal.NewSlicePointer(nextKey), internal.NewSlicePointer(keyBuf),
+				internal.NewSlicePointer(valueBuf), uint32(count), nil)
+			if errors.Is(err, ErrNotSupported) && first {
+				// The map type doesn't support batch operations.
+				useBatch = false
+				continue
+			}
+			n = int(ct)
+		} else {
+			var lastKey []byte
+			n, lastKey, err = m.batchLookupFallback(startPtr, keyBuf, valueBuf, count)
+			copy(nextKey, lastKey)
+		}
+		if err != nil && !errors.Is(err, ErrKeyNotExist) {
+			return nil, err
+		}
+
+		for i := 0; i < n; i++ {
+			key := keyBuf[i*keySize : (i+1)*keySize]
+			value := valueBuf[i*valueSize : (i+1)*valueSize]
+			entries[string(key)] = append([]byte(nil), value...)
+		}
+
+		if err != nil || n == 0 {
+			// ErrKeyNotExist signals the end of the map.
+			return entries, nil
+		}
+
+		// Don't pass the same buffer as start and next key.
+		prevKey, nextKey = nextKey, prevKey
+		startPtr = internal.NewSlicePointer(prevKey)
+		first = false
+	}
+}
+
+// batchCursorSize returns the size of the buffers used to resume
+// BPF_MAP_LOOKUP_BATCH. Hash maps use a 4 byte bucket index, other maps
+// use a key.
+func (m *Map) batchCursorSize() int {
+	if m.keySize < 4 {
+		return 4
+	}
+	return int(m.keySize)
+}
+
 // Iterate traverses a map.
 //
-// It's safe to create multiple iterators at the same time.
+// It's safe to create multiple iterators at the same time. A single
+// iterator must not be used from multiple goroutines concurrently.
 //
 // It's not possible to guarantee that all keys in a map will be
 // returned if there are concurrent modifications to the map.
-func (m *Map) Iterate() *MapIterator {
-	return newMapIterator(m)
+//
+// Hash and array maps are read in batches on kernels which support
+// BPF_MAP_LOOKUP_BATCH (5.6 and later), which requires far fewer
+// syscalls than looking up keys one by one.
+func (m *Map) Iterate() *MapIter {
+	return newMapIterator(context.Background(), m)
+}
+
+// IterateContext traverses a map until ctx is cancelled.
+//
+// The iterator checks ctx before retrieving each key. If ctx is
+// cancelled Next returns false and Err returns ctx.Err().
+//
+// See Iterate for further caveats.
+func (m *Map) IterateContext(ctx context.Context) *MapIter {
+	return newMapIterator(ctx, m)
 }
 
 // Close removes a Map
@@ -873,6 +1349,8 @@ func (m *Map) Clone() (*Map, error) {
 // the new path already exists. Re-pinning across filesystems is not supported.
 // You can Clone a map to pin it to a different path.
 //
+// Missing parent directories of fileName are created.
+//
 // This requires bpffs to be mounted above fileName. See https://docs.cilium.io/en/k8s-doc/admin/#admin-mount-bpffs
 func (m *Map) Pin(fileName string) error {
 	if err := internal.Pin(m.pinnedPath, fileName, m.fd); err != nil {
@@ -884,6 +1362,9 @@ func (m *Map) Pin(fileName string) error {
 
 // Unpin removes the persisted state for the map from the BPF virtual filesystem.
 //
+// The Map itself remains usable, since Unpin doesn't close the underlying
+// file descriptor.
+//
 // Failed calls to Unpin will not alter the state returned by IsPinned.
 //
 // Unpinning an unpinned Map returns nil.
@@ -902,6 +1383,9 @@ func (m *Map) IsPinned() bool {
 
 // Freeze prevents a map to be modified from user space.
 //
+// Subsequent calls to Update, Put and Delete fail with an error wrapping
+// syscall.EPERM. Freezing a map can't be undone.
+//
 // It makes no changes to kernel-side restrictions.
 func (m *Map) Freeze() error {
 	if err := haveMapMutabilityModifiers(); err != nil {
@@ -1141,24 +1625,64 @@ func patchValue(value []byte, typ btf.Type, replacements map[string]interface{})
 	return fmt.Errorf("unknown fields: %s", strings.Join(missing, ","))
 }
 
-// MapIterator iterates a Map.
+// MapIter iterates a Map.
+//
+// A MapIter holds the state of the iteration and isn't safe for
+// concurrent use. Create one iterator per goroutine instead.
 //
 // See Map.Iterate.
-type MapIterator struct {
+type MapIter struct {
 	target            *Map
+	ctx               context.Context
 	prevKey           interface{}
 	prevBytes         []byte
 	count, maxEntries uint32
 	done              bool
 	err               error
+
+	// State of BPF_MAP_LOOKUP_BATCH. keys and values contain entries
+	// which haven't been returned by Next yet.
+	batch struct {
+		enabled bool
+		started bool
+		done    bool
+		size    int
+		cursor  []byte
+		keys    []byte
+		values  []byte
+	}
 }
 
-func newMapIterator(target *Map) *MapIterator {
-	return &MapIterator{
+// iterateBatchSize is the number of entries initially requested by a
+// single BPF_MAP_LOOKUP_BATCH.
+const iterateBatchSize = 256
+
+// MapIterator is the previous name of MapIter.
+//
+// Deprecated: use MapIter instead.
+type MapIterator = MapIter
+
+func newMapIterator(ctx context.Context, target *Map) *MapIter {
+	mi := &MapIter{
 		target:     target,
+		ctx:        ctx,
 		maxEntries: target.maxEntries,
 		prevBytes:  make([]byte, target.keySize),
 	}
+
+	if target.typ.canBatchLookup() && haveBatchAPI() == nil {
+		mi.batch.enabled = true
+		mi.batch.size = iterateBatchSize
+		if mi.maxEntries < iterateBatchSize {
+			mi.batch.size = int(mi.maxEntries)
+		}
+		if mi.batch.size == 0 {
+			mi.batch.size = 1
+		}
+		mi.batch.cursor = make([]byte, target.batchCursorSize())
+	}
+
+	return mi
 }
 
 // Next decodes the next key and value.
@@ -1171,14 +1695,27 @@ func newMapIterator(target *Map) *MapIterator {
 // the result of Err afterwards.
 //
 // See Map.Get for further caveats around valueOut.
-func (mi *MapIterator) Next(keyOut, valueOut interface{}) bool {
+func (mi *MapIter) Next(keyOut, valueOut interface{}) bool {
 	if mi.err != nil || mi.done {
 		return false
 	}
 
+	if mi.batch.enabled {
+		ok := mi.nextBatched(keyOut, valueOut)
+		if mi.batch.enabled {
+			return ok
+		}
+		// The map doesn't support batch lookups after all, fall
+		// through to iterating key by key.
+	}
+
 	// For array-like maps NextKeyBytes returns nil only on after maxEntries
 	// iterations.
 	for mi.count <= mi.maxEntries {
+		if mi.err = mi.ctx.Err(); mi.err != nil {
+			return false
+		}
+
 		var nextBytes []byte
 		nextBytes, mi.err = mi.target.NextKeyBytes(mi.prevKey)
 		if mi.err != nil {
@@ -1222,12 +1759,107 @@ func (mi *MapIterator) Next(keyOut, valueOut interface{}) bool {
 	return false
 }
 
+// nextBatched decodes the next entry retrieved via BPF_MAP_LOOKUP_BATCH.
+//
+// Disables batching if the map turns out not to support it before
+// any entries were returned.
+func (mi *MapIter) nextBatched(keyOut, valueOut interface{}) bool {
+	var (
+		keySize   = int(mi.target.keySize)
+		valueSize = mi.target.fullValueSize
+	)
+
+	for len(mi.batch.keys) == 0 {
+		if mi.batch.done {
+			mi.done = true
+			return false
+		}
+
+		if mi.err = mi.ctx.Err(); mi.err != nil {
+			return false
+		}
+
+		mi.err = mi.fetchBatch()
+		if errors.Is(mi.err, ErrNotSupported) && !mi.batch.started {
+			mi.batch.enabled = false
+			mi.err = nil
+			return false
+		}
+		if mi.err != nil {
+			return false
+		}
+	}
+
+	if mi.err = mi.ctx.Err(); mi.err != nil {
+		return false
+	}
+
+	// Each batch is allocated anew, so it's fine for the caller to retain
+	// references into it after unmarshaling into a []byte.
+	key, value := mi.batch.keys[:keySize], mi.batch.values[:valueSize]
+	mi.batch.keys, mi.batch.values = mi.batch.keys[keySize:], mi.batch.values[valueSize:]
+
+	if mi.err = mi.target.unmarshalValue(valueOut, value); mi.err != nil {
+		return false
+	}
+
+	mi.err = mi.target.unmarshalKey(keyOut, key)
+	return mi.err == nil
+}
+
+// fetchBatch retrieves the next batch of entries from the kernel.
+func (mi *MapIter) fetchBatch() error {
+	var (
+		keySize   = int(mi.target.keySize)
+		valueSize = mi.target.fullValueSize
+		startPtr  internal.Pointer
+	)
+
+	if mi.batch.started {
+		startPtr = internal.NewSlicePointer(mi.batch.cursor)
+	}
+
+	for {
+		var (
+			keys   = make([]byte, mi.batch.size*keySize)
+			values = make([]byte, mi.batch.size*valueSize)
+			next   = make([]byte, len(mi.batch.cursor))
+		)
+
+		n, err := bpfMapBatch(internal.BPF_MAP_LOOKUP_BATCH, mi.target.fd, startPtr,
+			internal.NewSlicePointer(next), internal.NewSlicePointer(keys),
+			internal.NewSlicePointer(values), uint32(mi.batch.size), nil)
+		if errors.Is(err, unix.ENOSPC) && mi.batch.size < int(mi.maxEntries) {
+			// A hash bucket contains more entries than fit into the batch.
+			mi.batch.size *= 2
+			if mi.batch.size > int(mi.maxEntries) {
+				mi.batch.size = int(mi.maxEntries)
+			}
+			continue
+		}
+		if errors.Is(err, ErrKeyNotExist) {
+			// The batch contains the last entries of the map.
+			mi.batch.done = true
+		} else if err != nil {
+			return fmt.Errorf("lookup batch: %w", err)
+		}
+
+		mi.batch.started = true
+		mi.batch.cursor = next
+		mi.batch.keys = keys[:int(n)*keySize]
+		mi.batch.values = values[:int(n)*valueSize]
+		return nil
+	}
+}
+
 // Err returns any encountered error.
 //
 // The method must be called after Next returns nil.
 //
-// Returns ErrIterationAborted if it wasn't possible to do a full iteration.
-func (mi *MapIterator) Err() error {
+// Returns ErrIterationAborted if it wasn't possible to do a full iteration,
+// or the error of the context passed to Map.IterateContext if it was
+// cancelled.
+func (mi *MapIter) Err() error {
 	return mi.err
 }
 
@@ -1251,6 +1883,72 @@ func NewMapFromID(id MapID) (*Map, error) {
 	return newMapFromFD(fd)
 }
 
+// MapIDIterator enumerates all maps loaded into the kernel.
+//
+// Use NewMapIDIterator to create one.
+type MapIDIterator struct {
+	id  MapID
+	m   *Map
+	err error
+}
+
+// NewMapIDIterator returns an iterator over all maps in the system.
+//
+// Requires CAP_SYS_ADMIN.
+func NewMapIDIterator() *MapIDIterator {
+	return &MapIDIterator{}
+}
+
+// Next opens the next map.
+//
+// Maps which are removed between retrieving their ID and opening them
+// are skipped.
+//
+// Returns false if there are no more maps or if an error occurred. You
+// must check Err in that case.
+func (mi *MapIDIterator) Next() bool {
+	mi.m = nil
+	if mi.err != nil {
+		return false
+	}
+
+	for {
+		id, err := MapGetNextID(mi.id)
+		if errors.Is(err, os.ErrNotExist) {
+			return false
+		}
+		if err != nil {
+			mi.err = fmt.Errorf("get next map id: %w", err)
+			return false
+		}
+		mi.id = id
+
+		m, err := NewMapFromID(id)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			mi.err = fmt.Errorf("open map %d: %w", id, err)
+			return false
+		}
+
+		mi.m = m
+		return true
+	}
+}
+
+// Map returns the map opened by the last call to Next.
+//
+// The caller must close the map.
+func (mi *MapIDIterator) Map() *Map {
+	return mi.m
+}
+
+// Err returns the error which made Next return false, if any.
+func (mi *MapIDIterator) Err() error {
+	return mi.err
+}
+
 // ID returns the systemwide unique ID of the map.
 //
 // Deprecated: use MapInfo.ID() instead.
@@ -1,6 +1,7 @@
 package ebpf
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -13,8 +14,8 @@ import (
 	"unsafe"
 
 	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/btf"
 	"github.com/cilium/ebpf/internal"
-	"github.com/cilium/ebpf/internal/btf"
 	"github.com/cilium/ebpf/internal/testutils"
 	"github.com/cilium/ebpf/internal/unix"
 
@@ -262,6 +263,227 @@ func TestBatchAPIMapDelete(t *testing.T) {
 	}
 }
 
+func TestBatchLookupFallback(t *testing.T) {
+	m, err := NewMap(&MapSpec{
+		Type:       Hash,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	for i := uint32(0); i < 3; i++ {
+		if err := m.Put(i, i*10); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var (
+		keyBuf   = make([]byte, 2*4)
+		valueBuf = make([]byte, 2*4)
+		seen     = make(map[uint32]uint32)
+	)
+
+	record := func(n int) {
+		for i := 0; i < n; i++ {
+			key := internal.NativeEndian.Uint32(keyBuf[i*4:])
+			seen[key] = internal.NativeEndian.Uint32(valueBuf[i*4:])
+		}
+	}
+
+	n, lastKey, err := m.batchLookupFallback(internal.Pointer{}, keyBuf, valueBuf, 2)
+	if err != nil {
+		t.Fatal("First batch:", err)
+	}
+	if n != 2 {
+		t.Fatalf("First batch returned %d elements, expected 2", n)
+	}
+	record(n)
+
+	n, _, err = m.batchLookupFallback(internal.NewSlicePointer(lastKey), keyBuf, valueBuf, 2)
+	if !errors.Is(err, ErrKeyNotExist) {
+		t.Fatalf("Second batch: expected %v, got %v", ErrKeyNotExist, err)
+	}
+	if n != 1 {
+		t.Fatalf("Second batch returned %d elements, expected 1", n)
+	}
+	record(n)
+
+	want := map[uint32]uint32{0: 0, 1: 10, 2: 20}
+	if !reflect.DeepEqual(seen, want) {
+		t.Errorf("Expected %v, got %v", want, seen)
+	}
+}
+
+func TestBatchLookupFallbackSparseProgramArray(t *testing.T) {
+	arr, err := NewMap(&MapSpec{
+		Type:       ProgramArray,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 4,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer arr.Close()
+
+	prog := createSocketFilter(t)
+	defer prog.Close()
+
+	if err := arr.Put(uint32(2), prog); err != nil {
+		t.Fatal(err)
+	}
+
+	keyBuf := make([]byte, 4*4)
+	valueBuf := make([]byte, 4*4)
+	n, _, err := arr.batchLookupFallback(internal.Pointer{}, keyBuf, valueBuf, 4)
+	if !errors.Is(err, ErrKeyNotExist) {
+		t.Fatalf("Expected %v, got %v", ErrKeyNotExist, err)
+	}
+	if n != 1 {
+		t.Fatalf("Expected one element, got %d", n)
+	}
+	if key := internal.NativeEndian.Uint32(keyBuf); key != 2 {
+		t.Error("Expected key 2, got", key)
+	}
+}
+
+func TestLookupBatch(t *testing.T) {
+	m, err := NewMap(&MapSpec{
+		Type:       Hash,
+		KeySize:    2,
+		ValueSize:  4,
+		MaxEntries: 100,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	for i := uint16(0); i < 100; i++ {
+		if err := m.Put(i, uint32(i)*10); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var (
+		cursor MapBatchCursor
+		keys   = make([]uint16, 8)
+		values = make([]uint32, 8)
+		seen   = make(map[uint16]uint32)
+	)
+
+	for more := true; more; {
+		var n int
+		n, more, err = m.LookupBatch(&cursor, keys, values, nil)
+		if errors.Is(err, unix.ENOSPC) {
+			// A bucket holds more elements than fit into the batch.
+			keys = make([]uint16, len(keys)*2)
+			values = make([]uint32, len(values)*2)
+			more = true
+			continue
+		}
+		if err != nil {
+			t.Fatal("Can't lookup batch:", err)
+		}
+
+		for i := 0; i < n; i++ {
+			if _, ok := seen[keys[i]]; ok {
+				t.Fatal("Duplicate key", keys[i])
+			}
+			seen[keys[i]] = values[i]
+		}
+	}
+
+	if len(seen) != 100 {
+		t.Fatalf("Expected 100 elements, got %d", len(seen))
+	}
+	for key, value := range seen {
+		if value != uint32(key)*10 {
+			t.Errorf("Key %d has value %d", key, value)
+		}
+	}
+}
+
+func TestBatchUpdateFallback(t *testing.T) {
+	m, err := NewMap(&MapSpec{
+		Type:       Hash,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	if err := m.Put(uint32(1), uint32(0)); err != nil {
+		t.Fatal(err)
+	}
+
+	keys := []uint32{0, 1, 2}
+	values := []uint32{42, 4242, 424242}
+	count, err := m.batchUpdateFallback(keys, values, len(keys), &BatchOptions{ElemFlags: uint64(UpdateNoExist)})
+	if !errors.Is(err, ErrKeyExist) {
+		t.Fatalf("Expected %v, got %v", ErrKeyExist, err)
+	}
+	if count != 1 {
+		t.Fatalf("Expected 1 committed element, got %d", count)
+	}
+
+	var v uint32
+	if err := m.Lookup(uint32(0), &v); err != nil {
+		t.Fatal("Can't lookup 0:", err)
+	}
+	if v != 42 {
+		t.Error("Want value 42, got", v)
+	}
+
+	if err := m.Lookup(uint32(2), &v); !errors.Is(err, ErrKeyNotExist) {
+		t.Error("Expected key 2 to not exist, got", err)
+	}
+}
+
+func TestBatchDeleteFallback(t *testing.T) {
+	m, err := NewMap(&MapSpec{
+		Type:       Hash,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	for _, k := range []uint32{0, 2} {
+		if err := m.Put(k, uint32(42)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	keys := []uint32{0, 1, 2}
+	count, err := m.batchDeleteFallback(keys, len(keys))
+	if !errors.Is(err, ErrKeyNotExist) {
+		t.Fatalf("Expected %v, got %v", ErrKeyNotExist, err)
+	}
+	if count != 1 {
+		t.Fatalf("Expected 1 deleted element, got %d", count)
+	}
+
+	var v uint32
+	if err := m.Lookup(uint32(0), &v); !errors.Is(err, ErrKeyNotExist) {
+		t.Error("Expected key 0 to be deleted, got", err)
+	}
+
+	if err := m.Lookup(uint32(2), &v); err != nil {
+		t.Error("Expected key 2 to exist, got", err)
+	}
+}
+
 func TestMapClose(t *testing.T) {
 	m := createArray(t)
 
@@ -289,6 +511,40 @@ func TestMapCloneNil(t *testing.T) {
 	}
 }
 
+func TestMapIDIterator(t *testing.T) {
+	hash := createHash()
+	defer hash.Close()
+
+	info, err := hash.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, ok := info.ID()
+	if !ok {
+		t.Skip("Map IDs are not supported")
+	}
+
+	var found bool
+	it := NewMapIDIterator()
+	for it.Next() {
+		m := it.Map()
+		if mi, err := m.Info(); err == nil {
+			if id, _ := mi.ID(); id == want {
+				found = true
+			}
+		}
+		m.Close()
+	}
+	testutils.SkipIfNotSupported(t, it.Err())
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !found {
+		t.Error("Iterator didn't return map", want)
+	}
+}
+
 func TestMapPin(t *testing.T) {
 	m := createArray(t)
 	c := qt.New(t)
@@ -325,6 +581,51 @@ func TestMapPin(t *testing.T) {
 	}
 }
 
+func TestMapPinCreatesDirectories(t *testing.T) {
+	m := createArray(t)
+	defer m.Close()
+
+	tmp := testutils.TempBPFFS(t)
+	path := filepath.Join(tmp, "foo", "bar", "map")
+
+	if err := m.Pin(path); err != nil {
+		t.Fatal("Can't pin map to nested path:", err)
+	}
+
+	pinned, err := LoadPinnedMap(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinned.Close()
+
+	if err := m.Unpin(); err != nil {
+		t.Fatal("Can't unpin map:", err)
+	}
+
+	if err := m.Put(uint32(0), uint32(1)); err != nil {
+		t.Error("Map isn't usable after Unpin:", err)
+	}
+}
+
+func TestMapPinNotOnBPFFS(t *testing.T) {
+	m := createArray(t)
+	defer m.Close()
+
+	tmp, err := ioutil.TempDir("", "ebpf-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	if err := m.Pin(filepath.Join(tmp, "foo", "map")); err == nil {
+		t.Fatal("Pin accepts a path outside of bpffs")
+	}
+
+	if _, err := os.Stat(filepath.Join(tmp, "foo")); !os.IsNotExist(err) {
+		t.Error("Failed Pin creates parent directories")
+	}
+}
+
 func TestNestedMapPin(t *testing.T) {
 	m, err := NewMap(&MapSpec{
 		Type:       ArrayOfMaps,
@@ -624,6 +925,14 @@ func TestMapQueue(t *testing.T) {
 	}
 
 	var v uint32
+	if err := m.Lookup(nil, &v); err != nil {
+		t.Fatal("Can't peek element:", err)
+	}
+	if v != 42 {
+		t.Error("Want peeked value 42, got", v)
+	}
+
+	v = 0
 	if err := m.LookupAndDelete(nil, &v); err != nil {
 		t.Fatal("Can't lookup and delete element:", err)
 	}
@@ -644,6 +953,67 @@ func TestMapQueue(t *testing.T) {
 	}
 }
 
+func TestMapStack(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "4.20", "map type stack")
+
+	m, err := NewMap(&MapSpec{
+		Type:       Stack,
+		ValueSize:  4,
+		MaxEntries: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	for _, v := range []uint32{42, 4242} {
+		if err := m.Put(nil, v); err != nil {
+			t.Fatalf("Can't put %d: %s", v, err)
+		}
+	}
+
+	for _, want := range []uint32{4242, 42} {
+		var v uint32
+		if err := m.LookupAndDelete(nil, &v); err != nil {
+			t.Fatal("Can't lookup and delete element:", err)
+		}
+		if v != want {
+			t.Errorf("Want value %d, got %d", want, v)
+		}
+	}
+}
+
+func TestMapLookupAndDeleteHash(t *testing.T) {
+	m, err := NewMap(&MapSpec{
+		Type:       Hash,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	if err := m.Put(uint32(1), uint32(42)); err != nil {
+		t.Fatal(err)
+	}
+
+	var v uint32
+	err = m.LookupAndDelete(uint32(1), &v)
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal("Can't lookup and delete element:", err)
+	}
+	if v != 42 {
+		t.Error("Want value 42, got", v)
+	}
+
+	if err := m.LookupAndDelete(uint32(1), &v); !errors.Is(err, ErrKeyNotExist) {
+		t.Fatal("Lookup and delete of deleted key:", err)
+	}
+}
+
 func TestMapInMap(t *testing.T) {
 	for _, typ := range []MapType{ArrayOfMaps, HashOfMaps} {
 		t.Run(typ.String(), func(t *testing.T) {
@@ -731,136 +1101,384 @@ func TestNewMapInMapFromFD(t *testing.T) {
 	if err != nil {
 		t.Fatal("Can't create a new nested map from an FD")
 	}
-	another.Close()
-}
+	another.Close()
+}
+
+func TestPerfEventArray(t *testing.T) {
+	specs := []*MapSpec{
+		{Type: PerfEventArray},
+		{Type: PerfEventArray, KeySize: 4},
+		{Type: PerfEventArray, ValueSize: 4},
+	}
+
+	for _, spec := range specs {
+		m, err := NewMap(spec)
+		if err != nil {
+			t.Errorf("Can't create perf event array from %v: %s", spec, err)
+		} else {
+			m.Close()
+		}
+	}
+}
+
+func createMapInMap(t *testing.T, typ MapType) *Map {
+	t.Helper()
+
+	spec := &MapSpec{
+		Type:       typ,
+		KeySize:    4,
+		MaxEntries: 2,
+		InnerMap: &MapSpec{
+			Type:       Array,
+			KeySize:    4,
+			ValueSize:  4,
+			MaxEntries: 2,
+		},
+	}
+
+	m, err := NewMap(spec)
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestMapInMapValueSize(t *testing.T) {
+	spec := &MapSpec{
+		Type:       ArrayOfMaps,
+		KeySize:    4,
+		ValueSize:  0,
+		MaxEntries: 2,
+		InnerMap: &MapSpec{
+			Type:       Array,
+			KeySize:    4,
+			ValueSize:  4,
+			MaxEntries: 2,
+		},
+	}
+
+	m, err := NewMap(spec)
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Close()
+
+	spec.ValueSize = 4
+	m, err = NewMap(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Close()
+
+	spec.ValueSize = 1
+	if _, err := NewMap(spec); err == nil {
+		t.Fatal("Expected an error")
+	}
+}
+
+func TestIterateEmptyMap(t *testing.T) {
+	makeMap := func(t *testing.T, mapType MapType) *Map {
+		m, err := NewMap(&MapSpec{
+			Type:       mapType,
+			KeySize:    4,
+			ValueSize:  8,
+			MaxEntries: 2,
+		})
+		if errors.Is(err, unix.EINVAL) {
+			t.Skip(mapType, "is not supported")
+		}
+		if err != nil {
+			t.Fatal("Can't create map:", err)
+		}
+		t.Cleanup(func() { m.Close() })
+		return m
+	}
+
+	for _, mapType := range []MapType{
+		Hash,
+		SockHash,
+	} {
+		t.Run(mapType.String(), func(t *testing.T) {
+			m := makeMap(t, mapType)
+			entries := m.Iterate()
+
+			var key string
+			var value uint32
+			if entries.Next(&key, &value) != false {
+				t.Error("Empty hash should not be iterable")
+			}
+			if err := entries.Err(); err != nil {
+				t.Error("Empty hash shouldn't return an error:", err)
+			}
+		})
+	}
+
+	for _, mapType := range []MapType{
+		Array,
+		SockMap,
+	} {
+		t.Run(mapType.String(), func(t *testing.T) {
+			m := makeMap(t, mapType)
+			entries := m.Iterate()
+			var key string
+			var value uint32
+			for entries.Next(&key, &value) {
+				// Some empty arrays like sockmap don't return any keys.
+			}
+			if err := entries.Err(); err != nil {
+				t.Error("Empty array shouldn't return an error:", err)
+			}
+		})
+	}
+}
+
+func TestNewMapWithBTF(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "4.18", "BTF for maps")
+
+	type value struct {
+		Counter uint64
+		Flags   [2]uint32
+	}
+
+	var enc btf.Encoder
+	keyType, err := enc.Encode(uint32(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	valueType, err := enc.Encode(value{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := NewMapWithBTF(&MapSpec{
+		Type:       Hash,
+		KeySize:    4,
+		ValueSize:  16,
+		MaxEntries: 1,
+	}, keyType, valueType)
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	info, err := m.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, ok := info.BTFID()
+	if !ok {
+		t.Skip("Map has no BTF, kernel probably doesn't support it")
+	}
+
+	h, err := btf.NewHandleFromID(id)
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	spec, err := btf.HandleSpec(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	valueID, _ := info.BTFValueTypeID()
+	typ, err := spec.TypeByID(btf.TypeID(valueID))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s, ok := typ.(*btf.Struct); !ok || s.Name != "value" {
+		t.Error("Expected struct value, got", typ)
+	}
+}
+
+func TestMapDiff(t *testing.T) {
+	spec := &MapSpec{
+		Type:       Hash,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 2000,
+	}
+
+	live, err := NewMap(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer live.Close()
+
+	desired, err := NewMap(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer desired.Close()
+
+	// Use more entries than fit into a single batch.
+	for i := uint32(0); i < 1500; i++ {
+		if err := live.Put(i, i); err != nil {
+			t.Fatal(err)
+		}
+		if err := desired.Put(i, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := live.Delete(uint32(1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := desired.Delete(uint32(2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := desired.Put(uint32(3), uint32(42)); err != nil {
+		t.Fatal(err)
+	}
+
+	diffs, err := live.Diff(desired)
+	if err != nil {
+		t.Fatal("Can't diff maps:", err)
+	}
+
+	key := func(k uint32) []byte {
+		buf := make([]byte, 4)
+		internal.NativeEndian.PutUint32(buf, k)
+		return buf
+	}
+
+	want := []MapDiff{
+		{MapDiffAdded, key(1), nil, key(1)},
+		{MapDiffRemoved, key(2), key(2), nil},
+		{MapDiffChanged, key(3), key(3), key(42)},
+	}
+	qt.Assert(t, diffs, qt.DeepEquals, want)
+
+	diffs, err = live.Diff(live)
+	if err != nil {
+		t.Fatal("Can't diff map with itself:", err)
+	}
+	if len(diffs) != 0 {
+		t.Error("Expected no differences, got", diffs)
+	}
+
+	array, err := NewMap(&MapSpec{
+		Type:       Array,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer array.Close()
+
+	if _, err := live.Diff(array); err == nil {
+		t.Error("Diff accepts incompatible maps")
+	}
+}
+
+func TestMapDiffSmallKey(t *testing.T) {
+	// Hash maps resume batch lookups using a 4 byte bucket index, which
+	// is larger than the key.
+	spec := &MapSpec{
+		Type:       Hash,
+		KeySize:    2,
+		ValueSize:  4,
+		MaxEntries: 2000,
+	}
+
+	live, err := NewMap(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer live.Close()
 
-func TestPerfEventArray(t *testing.T) {
-	specs := []*MapSpec{
-		{Type: PerfEventArray},
-		{Type: PerfEventArray, KeySize: 4},
-		{Type: PerfEventArray, ValueSize: 4},
+	desired, err := NewMap(spec)
+	if err != nil {
+		t.Fatal(err)
 	}
+	defer desired.Close()
 
-	for _, spec := range specs {
-		m, err := NewMap(spec)
-		if err != nil {
-			t.Errorf("Can't create perf event array from %v: %s", spec, err)
-		} else {
-			m.Close()
+	for i := uint16(0); i < 1500; i++ {
+		if err := live.Put(i, uint32(i)); err != nil {
+			t.Fatal(err)
+		}
+		if err := desired.Put(i, uint32(i)); err != nil {
+			t.Fatal(err)
 		}
 	}
-}
 
-func createMapInMap(t *testing.T, typ MapType) *Map {
-	t.Helper()
-
-	spec := &MapSpec{
-		Type:       typ,
-		KeySize:    4,
-		MaxEntries: 2,
-		InnerMap: &MapSpec{
-			Type:       Array,
-			KeySize:    4,
-			ValueSize:  4,
-			MaxEntries: 2,
-		},
+	if err := desired.Put(uint16(1499), uint32(42)); err != nil {
+		t.Fatal(err)
 	}
 
-	m, err := NewMap(spec)
-	testutils.SkipIfNotSupported(t, err)
+	diffs, err := live.Diff(desired)
 	if err != nil {
-		t.Fatal(err)
+		t.Fatal("Can't diff maps:", err)
 	}
-	return m
+
+	key := make([]byte, 2)
+	internal.NativeEndian.PutUint16(key, 1499)
+	oldValue := make([]byte, 4)
+	internal.NativeEndian.PutUint32(oldValue, 1499)
+	newValue := make([]byte, 4)
+	internal.NativeEndian.PutUint32(newValue, 42)
+
+	want := []MapDiff{
+		{MapDiffChanged, key, oldValue, newValue},
+	}
+	qt.Assert(t, diffs, qt.DeepEquals, want)
 }
 
-func TestMapInMapValueSize(t *testing.T) {
+func TestMapDiffSparseProgramArray(t *testing.T) {
+	// Program arrays don't support batch lookups, and lookups of empty
+	// slots fail. Diff must skip over them instead of looping forever.
 	spec := &MapSpec{
-		Type:       ArrayOfMaps,
+		Type:       ProgramArray,
 		KeySize:    4,
-		ValueSize:  0,
-		MaxEntries: 2,
-		InnerMap: &MapSpec{
-			Type:       Array,
-			KeySize:    4,
-			ValueSize:  4,
-			MaxEntries: 2,
-		},
+		ValueSize:  4,
+		MaxEntries: 8,
 	}
 
-	m, err := NewMap(spec)
-	testutils.SkipIfNotSupported(t, err)
+	live, err := NewMap(spec)
 	if err != nil {
 		t.Fatal(err)
 	}
-	m.Close()
+	defer live.Close()
 
-	spec.ValueSize = 4
-	m, err = NewMap(spec)
+	desired, err := NewMap(spec)
 	if err != nil {
 		t.Fatal(err)
 	}
-	m.Close()
+	defer desired.Close()
 
-	spec.ValueSize = 1
-	if _, err := NewMap(spec); err == nil {
-		t.Fatal("Expected an error")
-	}
-}
+	prog := createSocketFilter(t)
+	defer prog.Close()
 
-func TestIterateEmptyMap(t *testing.T) {
-	makeMap := func(t *testing.T, mapType MapType) *Map {
-		m, err := NewMap(&MapSpec{
-			Type:       mapType,
-			KeySize:    4,
-			ValueSize:  8,
-			MaxEntries: 2,
-		})
-		if errors.Is(err, unix.EINVAL) {
-			t.Skip(mapType, "is not supported")
-		}
-		if err != nil {
-			t.Fatal("Can't create map:", err)
+	for _, m := range []*Map{live, desired} {
+		if err := m.Put(uint32(2), prog); err != nil {
+			t.Fatal(err)
 		}
-		t.Cleanup(func() { m.Close() })
-		return m
+	}
+	if err := desired.Put(uint32(5), prog); err != nil {
+		t.Fatal(err)
 	}
 
-	for _, mapType := range []MapType{
-		Hash,
-		SockHash,
-	} {
-		t.Run(mapType.String(), func(t *testing.T) {
-			m := makeMap(t, mapType)
-			entries := m.Iterate()
-
-			var key string
-			var value uint32
-			if entries.Next(&key, &value) != false {
-				t.Error("Empty hash should not be iterable")
-			}
-			if err := entries.Err(); err != nil {
-				t.Error("Empty hash shouldn't return an error:", err)
-			}
-		})
+	diffs, err := live.Diff(desired)
+	if err != nil {
+		t.Fatal("Can't diff maps:", err)
 	}
 
-	for _, mapType := range []MapType{
-		Array,
-		SockMap,
-	} {
-		t.Run(mapType.String(), func(t *testing.T) {
-			m := makeMap(t, mapType)
-			entries := m.Iterate()
-			var key string
-			var value uint32
-			for entries.Next(&key, &value) {
-				// Some empty arrays like sockmap don't return any keys.
-			}
-			if err := entries.Err(); err != nil {
-				t.Error("Empty array shouldn't return an error:", err)
-			}
-		})
+	if len(diffs) != 1 {
+		t.Fatalf("Expected one difference, got %v", diffs)
+	}
+	if diffs[0].Kind != MapDiffAdded {
+		t.Error("Expected MapDiffAdded, got", diffs[0].Kind)
+	}
+	if key := internal.NativeEndian.Uint32(diffs[0].Key); key != 5 {
+		t.Error("Expected key 5, got", key)
 	}
 }
 
@@ -910,6 +1528,96 @@ func TestMapIterate(t *testing.T) {
 	}
 }
 
+func TestMapIterateBatch(t *testing.T) {
+	for _, typ := range []MapType{Hash, PerCPUHash} {
+		t.Run(typ.String(), func(t *testing.T) {
+			m, err := NewMap(&MapSpec{
+				Type:       typ,
+				KeySize:    4,
+				ValueSize:  4,
+				MaxEntries: 1000,
+			})
+			testutils.SkipIfNotSupported(t, err)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer m.Close()
+
+			for i := uint32(0); i < 1000; i++ {
+				var value interface{} = i
+				if typ.hasPerCPUValue() {
+					value = []uint32{i}
+				}
+				if err := m.Put(i, value); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for _, batch := range []bool{true, false} {
+				entries := m.Iterate()
+				if !batch {
+					entries.batch.enabled = false
+				}
+
+				var (
+					key    uint32
+					value  uint32
+					values []uint32
+					seen   = make(map[uint32]bool)
+				)
+
+				valueOut := interface{}(&value)
+				if typ.hasPerCPUValue() {
+					valueOut = &values
+				}
+
+				for entries.Next(&key, valueOut) {
+					if typ.hasPerCPUValue() {
+						value = values[0]
+					}
+					if value != key {
+						t.Fatalf("Expected value %d for key %d, got %d", key, key, value)
+					}
+					seen[key] = true
+				}
+				if err := entries.Err(); err != nil {
+					t.Fatal(err)
+				}
+
+				if len(seen) != 1000 {
+					t.Errorf("Expected 1000 keys with batch=%v, got %d", batch, len(seen))
+				}
+				if batch && haveBatchAPI() == nil && !entries.batch.enabled {
+					t.Error("Iterator didn't use batch lookups")
+				}
+			}
+		})
+	}
+}
+
+func TestMapIterateContext(t *testing.T) {
+	arr := createArray(t)
+	defer arr.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var key, value uint32
+	entries := arr.IterateContext(ctx)
+	if !entries.Next(&key, &value) {
+		t.Fatal("Can't get first entry:", entries.Err())
+	}
+
+	cancel()
+
+	if entries.Next(&key, &value) {
+		t.Fatal("Next returns true after context was cancelled")
+	}
+	if err := entries.Err(); !errors.Is(err, context.Canceled) {
+		t.Fatal("Expected context.Canceled, got", err)
+	}
+}
+
 func TestNotExist(t *testing.T) {
 	hash := createHash()
 	defer hash.Close()
@@ -948,6 +1656,48 @@ func TestExist(t *testing.T) {
 	if err := hash.Update("hello", uint32(42), UpdateNoExist); !errors.Is(err, ErrKeyExist) {
 		t.Error("Updating existing key doesn't return ErrKeyExist")
 	}
+
+	if err := hash.Insert("hello", uint32(42)); !errors.Is(err, ErrKeyExist) {
+		t.Error("Inserting existing key doesn't return ErrKeyExist")
+	}
+
+	if err := hash.Replace("hello", uint32(42)); err != nil {
+		t.Error("Can't replace existing key:", err)
+	}
+
+	if err := hash.Replace("world", uint32(42)); !errors.Is(err, ErrKeyNotExist) {
+		t.Error("Replacing non-existing key doesn't return ErrKeyNotExist")
+	}
+
+	if err := hash.Insert("world", uint32(42)); err != nil {
+		t.Error("Can't insert new key:", err)
+	}
+}
+
+func TestMapGetOrInsert(t *testing.T) {
+	hash := createHash()
+	defer hash.Close()
+
+	value := uint32(21)
+	existed, err := hash.GetOrInsert("hello", &value)
+	if err != nil {
+		t.Fatal("Can't insert key:", err)
+	}
+	if existed {
+		t.Error("GetOrInsert reports a new key as existing")
+	}
+
+	value = 42
+	existed, err = hash.GetOrInsert("hello", &value)
+	if err != nil {
+		t.Fatal("Can't get key:", err)
+	}
+	if !existed {
+		t.Error("GetOrInsert doesn't report existing key")
+	}
+	if value != 21 {
+		t.Error("Expected existing value 21, got", value)
+	}
 }
 
 func TestIterateMapInMap(t *testing.T) {
@@ -1034,6 +1784,35 @@ func TestPerCPUMarshaling(t *testing.T) {
 	}
 }
 
+func TestPossibleCPU(t *testing.T) {
+	numCPU, err := PossibleCPU()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if numCPU < 1 {
+		t.Fatal("Expected at least one possible CPU, got", numCPU)
+	}
+
+	arr, err := NewMap(&MapSpec{
+		Type:       PerCPUArray,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer arr.Close()
+
+	var values []uint32
+	if err := arr.Lookup(uint32(0), &values); err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != numCPU {
+		t.Errorf("Expected %d values, got %d", numCPU, len(values))
+	}
+}
+
 type bpfCgroupStorageKey struct {
 	CgroupInodeId uint64
 	AttachType    AttachType
@@ -1203,6 +1982,31 @@ func TestMapName(t *testing.T) {
 	if name := internal.CString(info.name[:]); name != "test" {
 		t.Error("Expected name to be test, got", name)
 	}
+
+	if name := m.Name(); name != "test" {
+		t.Error("Expected Name to return test, got", name)
+	}
+
+	long, err := NewMap(&MapSpec{
+		Name:       "a_very_long_map_name",
+		Type:       Array,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer long.Close()
+
+	info, err = bpfGetMapInfoByFD(long.fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if name, want := long.Name(), internal.CString(info.name[:]); name != want {
+		t.Errorf("Expected Name to return %q like the kernel, got %q", want, name)
+	}
 }
 
 func TestMapFromFD(t *testing.T) {
@@ -1289,8 +2093,17 @@ func TestMapFreeze(t *testing.T) {
 		t.Fatal("Can't freeze map:", err)
 	}
 
-	if err := arr.Put(uint32(0), uint32(1)); err == nil {
-		t.Error("Freeze doesn't prevent modification from user space")
+	if err := arr.Put(uint32(0), uint32(1)); !errors.Is(err, unix.EPERM) {
+		t.Error("Freeze doesn't prevent modification from user space:", err)
+	}
+
+	if err := arr.Delete(uint32(0)); !errors.Is(err, unix.EPERM) {
+		t.Error("Freeze doesn't prevent deletion from user space:", err)
+	}
+
+	var v uint32
+	if err := arr.Lookup(uint32(0), &v); err != nil {
+		t.Error("Can't lookup element in frozen map:", err)
 	}
 }
 
@@ -1405,6 +2218,55 @@ func TestMapPinning(t *testing.T) {
 	}
 }
 
+func TestMapSpecCompatible(t *testing.T) {
+	spec := &MapSpec{Type: PerfEventArray}
+
+	m, err := NewMap(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	if err := spec.Compatible(m); err != nil {
+		t.Error("Spec with defaulted fields isn't compatible:", err)
+	}
+
+	spec = &MapSpec{Type: PerfEventArray, MaxEntries: m.MaxEntries() + 1}
+	if err := spec.Compatible(m); !errors.Is(err, ErrMapIncompatible) {
+		t.Error("Expected ErrMapIncompatible for mismatched MaxEntries, got", err)
+	}
+
+	spec = &MapSpec{Type: Hash, KeySize: 4, ValueSize: 4}
+	if err := spec.Compatible(m); !errors.Is(err, ErrMapIncompatible) {
+		t.Error("Expected ErrMapIncompatible for mismatched Type, got", err)
+	}
+
+	spec = &MapSpec{
+		Type:       Hash,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 2,
+		Flags:      unix.BPF_F_NO_PREALLOC,
+	}
+	hash, err := NewMap(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hash.Close()
+
+	// Maps without preallocation still can't hold more than MaxEntries
+	// elements, so a larger map isn't a substitute.
+	spec.MaxEntries = 1
+	if err := spec.Compatible(hash); !errors.Is(err, ErrMapIncompatible) {
+		t.Error("Expected ErrMapIncompatible for map with more entries, got", err)
+	}
+
+	spec.MaxEntries = 3
+	if err := spec.Compatible(hash); !errors.Is(err, ErrMapIncompatible) {
+		t.Error("Expected ErrMapIncompatible for map with fewer entries, got", err)
+	}
+}
+
 type benchValue struct {
 	ID      uint32
 	Val16   uint16
@@ -1678,6 +2540,20 @@ func ExampleMap_NextKey() {
 	// Order of keys is non-deterministic due to randomized map seed
 }
 
+// ExampleMapGetNextID demonstrates how to enumerate all maps loaded
+// into the kernel, similar to bpftool map list.
+func ExampleMapIDIterator() {
+	it := NewMapIDIterator()
+	for it.Next() {
+		m := it.Map()
+		fmt.Println(m)
+		m.Close()
+	}
+	if err := it.Err(); err != nil {
+		panic(err)
+	}
+}
+
 // ExampleMap_Iterate demonstrates how to iterate over all entries
 // in a map.
 func ExampleMap_Iterate() {
@@ -122,6 +122,15 @@ func unmarshalBytes(data interface{}, buf []byte) error {
 	}
 }
 
+// PossibleCPU returns the number of possible CPUs, as read from
+// /sys/devices/system/cpu/possible. Values of per-CPU maps contain one
+// element per possible CPU.
+//
+// The result is cached after the first call.
+func PossibleCPU() (int, error) {
+	return internal.PossibleCPUs()
+}
+
 // marshalPerCPUValue encodes a slice containing one value per
 // possible CPU into a buffer of bytes.
 //
@@ -145,7 +154,7 @@ func marshalPerCPUValue(slice interface{}, elemLength int) (internal.Pointer, er
 		return internal.Pointer{}, fmt.Errorf("per-CPU value exceeds number of CPUs")
 	}
 
-	alignedElemLength := align(elemLength, 8)
+	alignedElemLength := internal.Align(elemLength, 8)
 	buf := make([]byte, alignedElemLength*possibleCPUs)
 
 	for i := 0; i < sliceLen; i++ {
@@ -215,7 +224,3 @@ func unmarshalPerCPUValue(slicePtr interface{}, elemLength int, buf []byte) erro
 	reflect.ValueOf(slicePtr).Elem().Set(slice)
 	return nil
 }
-
-func align(n, alignment int) int {
-	return (int(n) + alignment - 1) / alignment * alignment
-}
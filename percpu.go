@@ -0,0 +1,59 @@
+package ebpf
+
+import (
+	"fmt"
+)
+
+// PerCPUMap is a map which stores one value per possible CPU, like PerCPUHash
+// or PerCPUArray.
+//
+// Map.Lookup and Map.Update handle per-CPU maps as well if they are passed
+// a *[]T or []T respectively. PerCPUMap is useful when the value type is
+// only known at runtime.
+type PerCPUMap struct {
+	m *Map
+}
+
+// NewPerCPUMap wraps a Map which has a per-CPU value.
+//
+// The map isn't copied, closing it invalidates the PerCPUMap.
+func NewPerCPUMap(m *Map) (*PerCPUMap, error) {
+	if !m.Type().hasPerCPUValue() {
+		return nil, fmt.Errorf("%s doesn't have per-CPU values", m)
+	}
+	return &PerCPUMap{m}, nil
+}
+
+// Map returns the underlying Map.
+func (pm *PerCPUMap) Map() *Map {
+	return pm.m
+}
+
+// Lookup retrieves the values for key.
+//
+// The result contains one element per possible CPU, see PossibleCPU.
+// Each element is a []byte of the map's value size, with the padding
+// the kernel adds to align values to 8 bytes removed.
+//
+// Returns ErrKeyNotExist if the key doesn't exist.
+func (pm *PerCPUMap) Lookup(key interface{}) ([]interface{}, error) {
+	var raw [][]byte
+	if err := pm.m.Lookup(key, &raw); err != nil {
+		return nil, err
+	}
+
+	values := make([]interface{}, 0, len(raw))
+	for _, value := range raw {
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// Update sets the values for key.
+//
+// values contains a value for each CPU, which are marshaled individually.
+// CPUs without a value are set to zero. Returns an error if there are more
+// values than possible CPUs.
+func (pm *PerCPUMap) Update(key interface{}, values []interface{}, flags MapUpdateFlags) error {
+	return pm.m.Update(key, values, flags)
+}
@@ -0,0 +1,70 @@
+package ebpf
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPerCPUMap(t *testing.T) {
+	m, err := NewMap(&MapSpec{
+		Type:       PerCPUHash,
+		KeySize:    4,
+		ValueSize:  5,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	pm, err := NewPerCPUMap(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := pm.Lookup(uint32(0)); err == nil {
+		t.Fatal("Lookup of missing key doesn't return an error")
+	}
+
+	cpus, err := PossibleCPU()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The value size isn't a multiple of 8, which requires padding.
+	values := make([]interface{}, cpus)
+	for i := range values {
+		values[i] = []byte{byte(i), 1, 2, 3, 4}
+	}
+	if err := pm.Update(uint32(0), values, UpdateAny); err != nil {
+		t.Fatal("Can't update:", err)
+	}
+
+	got, err := pm.Lookup(uint32(0))
+	if err != nil {
+		t.Fatal("Can't lookup:", err)
+	}
+	if len(got) != cpus {
+		t.Fatalf("Expected %d values, got %d", cpus, len(got))
+	}
+
+	for i, value := range got {
+		if want := values[i].([]byte); !bytes.Equal(value.([]byte), want) {
+			t.Errorf("CPU %d: expected %q, got %q", i, want, value)
+		}
+	}
+
+	tooMany := make([]interface{}, cpus+1)
+	for i := range tooMany {
+		tooMany[i] = make([]byte, 5)
+	}
+	if err := pm.Update(uint32(0), tooMany, UpdateAny); err == nil {
+		t.Error("Update accepts more values than CPUs")
+	}
+
+	hash := createHash()
+	defer hash.Close()
+	if _, err := NewPerCPUMap(hash); err == nil {
+		t.Error("NewPerCPUMap accepts a map without per-CPU values")
+	}
+}
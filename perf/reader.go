@@ -153,6 +153,8 @@ type Reader struct {
 	// Read calls, which would otherwise need to be interrupted.
 	pauseMu  sync.Mutex
 	pauseFds []int
+
+	onLostSamples func(count uint64)
 }
 
 // ReaderOptions control the behaviour of the user
@@ -162,6 +164,11 @@ type ReaderOptions struct {
 	// Read will process data. Must be smaller than PerCPUBuffer.
 	// The default is to start processing as soon as data is available.
 	Watermark int
+
+	// OnLostSamples is called with the number of lost samples whenever
+	// Read encounters a record of lost samples, before the record is
+	// returned to the caller. It is invoked on the goroutine calling Read.
+	OnLostSamples func(count uint64)
 }
 
 // NewReader creates a new reader with default options.
@@ -247,10 +254,11 @@ func NewReaderWithOptions(array *ebpf.Map, perCPUBuffer int, opts ReaderOptions)
 		rings:   rings,
 		epollFd: epollFd,
 		// Allocate extra event for closeFd
-		epollEvents: make([]unix.EpollEvent, len(rings)+1),
-		epollRings:  make([]*perfEventRing, 0, len(rings)),
-		closeFd:     closeFd,
-		pauseFds:    pauseFds,
+		epollEvents:   make([]unix.EpollEvent, len(rings)+1),
+		epollRings:    make([]*perfEventRing, 0, len(rings)),
+		closeFd:       closeFd,
+		pauseFds:      pauseFds,
+		onLostSamples: opts.OnLostSamples,
 	}
 	if err = pr.Resume(); err != nil {
 		return nil, err
@@ -363,6 +371,10 @@ func (pr *Reader) Read() (Record, error) {
 			continue
 		}
 
+		if err == nil && record.LostSamples > 0 && pr.onLostSamples != nil {
+			pr.onLostSamples(record.LostSamples)
+		}
+
 		return record, err
 	}
 }
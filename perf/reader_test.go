@@ -202,7 +202,10 @@ func TestPerfReaderLostSample(t *testing.T) {
 	defer prog.Close()
 	defer events.Close()
 
-	rd, err := NewReader(events, pageSize)
+	var lost uint64
+	rd, err := NewReaderWithOptions(events, pageSize, ReaderOptions{
+		OnLostSamples: func(count uint64) { lost += count },
+	})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -228,6 +231,10 @@ func TestPerfReaderLostSample(t *testing.T) {
 			t.Fatal("Expected a record with LostSamples 1, got", record.LostSamples)
 		}
 	}
+
+	if lost != 1 {
+		t.Error("Expected OnLostSamples to report 1 lost sample, got", lost)
+	}
 }
 
 func TestPerfReaderClose(t *testing.T) {
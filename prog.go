@@ -7,13 +7,13 @@ import (
 	"fmt"
 	"io"
 	"math"
-	"path/filepath"
+	"os"
 	"strings"
 	"time"
 
 	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/btf"
 	"github.com/cilium/ebpf/internal"
-	"github.com/cilium/ebpf/internal/btf"
 	"github.com/cilium/ebpf/internal/unix"
 )
 
@@ -22,6 +22,10 @@ var ErrNotSupported = internal.ErrNotSupported
 
 var errUnsatisfiedReference = errors.New("unsatisfied reference")
 
+// VerifierError is returned by NewProgram and NewProgramWithOptions if the
+// verifier rejects a program. Use errors.As to retrieve it.
+type VerifierError = internal.VerifierError
+
 // ProgramID represents the unique ID of an eBPF program.
 type ProgramID uint32
 
@@ -39,11 +43,26 @@ const DefaultVerifierLogSize = 64 * 1024
 // ProgramOptions control loading a program into the kernel.
 type ProgramOptions struct {
 	// Controls the detail emitted by the kernel verifier. Set to non-zero
-	// to enable logging.
+	// to enable logging: 1 emits the basic log, 2 additionally logs the
+	// verifier state at each instruction.
+	//
+	// A LogLevel of 0 disables the log for successful loads. If loading
+	// fails the program is loaded again with LogLevel 1, so that the
+	// returned error contains the verifier log. Use LogDisabled to
+	// prevent this.
 	LogLevel uint32
 	// Controls the output buffer size for the verifier. Defaults to
-	// DefaultVerifierLogSize.
+	// DefaultVerifierLogSize. The buffer is only allocated if a log is
+	// requested.
+	//
+	// A negative value together with a LogLevel of 0 disables the log
+	// entirely, even if loading fails. Prefer LogDisabled.
 	LogSize int
+	// Disables the verifier log entirely, even if loading fails. Takes
+	// precedence over LogLevel and LogSize.
+	//
+	// Useful if no memory at all should be allocated for the log.
+	LogDisabled bool
 	// An ELF containing the target BTF for this program. It is used both to
 	// find the correct function to trace and to apply CO-RE relocations.
 	// This is useful in environments where the kernel BTF is not available
@@ -84,6 +103,14 @@ type ProgramSpec struct {
 	// detect this value automatically.
 	KernelVersion uint32
 
+	// RequiredKernelVersion is the minimum version of the kernel the
+	// program can be loaded on, in the format of KernelVersion. Loading
+	// fails early with an error wrapping ErrNotSupported on older kernels.
+	//
+	// Leave empty to only probe for support of the program type if the
+	// kernel rejects the program.
+	RequiredKernelVersion uint32
+
 	// The BTF associated with this program. Changing Instructions
 	// will most likely invalidate the contained data, and may
 	// result in errors when attempting to load it into the kernel.
@@ -118,6 +145,10 @@ func (ps *ProgramSpec) Tag() (string, error) {
 type Program struct {
 	// Contains the output of the kernel verifier if enabled,
 	// otherwise it is empty.
+	//
+	// The kernel only emits the log while loading a program, it can't be
+	// retrieved at a later point. Set ProgramOptions.LogLevel to capture
+	// the log of a program that is loaded successfully.
 	VerifierLog string
 
 	fd         *internal.FD
@@ -158,6 +189,13 @@ func newProgramWithOptions(spec *ProgramSpec, opts ProgramOptions, handles *hand
 		return nil, fmt.Errorf("can't load %s program on %s", spec.ByteOrder, internal.NativeEndian)
 	}
 
+	if spec.RequiredKernelVersion != 0 {
+		required := internal.NewVersionFromCode(spec.RequiredKernelVersion)
+		if err := checkKernelVersion(fmt.Sprintf("program %s", spec.Name), required); err != nil {
+			return nil, err
+		}
+	}
+
 	// Kernels before 5.0 (6c4fc209fcf9 "bpf: remove useless version check for prog load")
 	// require the version field to be set to the value of the KERNEL_VERSION
 	// macro for kprobe-type programs.
@@ -275,6 +313,15 @@ func newProgramWithOptions(spec *ProgramSpec, opts ProgramOptions, handles *hand
 		if target != nil {
 			attr.AttachBTFID = uint32(target.ID())
 		}
+		if spec.Type == StructOps {
+			// The kernel identifies the implemented member by its index.
+			target, member, err := resolveStructOpsMember(targetBTF, spec.AttachTo)
+			if err != nil {
+				return nil, err
+			}
+			attr.AttachBTFID = uint32(target.ID())
+			attr.ExpectedAttachType = uint32(member)
+		}
 		if spec.AttachTarget != nil {
 			attr.AttachProgFd = uint32(spec.AttachTarget.FD())
 		}
@@ -286,7 +333,7 @@ func newProgramWithOptions(spec *ProgramSpec, opts ProgramOptions, handles *hand
 	}
 
 	var logBuf []byte
-	if opts.LogLevel > 0 {
+	if opts.LogLevel > 0 && !opts.LogDisabled {
 		logBuf = make([]byte, logSize)
 		attr.LogLevel = opts.LogLevel
 		attr.LogSize = uint32(len(logBuf))
@@ -299,7 +346,7 @@ func newProgramWithOptions(spec *ProgramSpec, opts ProgramOptions, handles *hand
 	}
 
 	logErr := err
-	if opts.LogLevel == 0 && opts.LogSize >= 0 {
+	if opts.LogLevel == 0 && opts.LogSize >= 0 && !opts.LogDisabled {
 		// Re-run with the verifier enabled to get better error messages.
 		logBuf = make([]byte, logSize)
 		attr.LogLevel = 1
@@ -309,13 +356,22 @@ func newProgramWithOptions(spec *ProgramSpec, opts ProgramOptions, handles *hand
 		_, logErr = internal.BPFProgLoad(attr)
 	}
 
-	if errors.Is(logErr, unix.EPERM) && logBuf[0] == 0 {
+	if errors.Is(logErr, unix.EPERM) && len(logBuf) > 0 && logBuf[0] == 0 {
 		// EPERM due to RLIMIT_MEMLOCK happens before the verifier, so we can
 		// check that the log is empty to reduce false positives.
 		return nil, fmt.Errorf("load program: RLIMIT_MEMLOCK may be too low: %w", logErr)
 	}
 
+	loadErr := err
 	err = internal.ErrorWithLog(err, logBuf, logErr)
+	if errors.Is(loadErr, unix.EINVAL) {
+		// The kernel doesn't tell us whether it's simply too old, so probe
+		// for the program type. Distributions backport types to older
+		// kernels, which means the version alone isn't conclusive.
+		if perr := haveProgType(spec.Type); errors.Is(perr, ErrNotSupported) {
+			return nil, fmt.Errorf("load program: %w", perr)
+		}
+	}
 	if btfDisabled {
 		return nil, fmt.Errorf("load program without BTF: %w", err)
 	}
@@ -354,7 +410,7 @@ func newProgramFromFD(fd *internal.FD) (*Program, error) {
 		return nil, fmt.Errorf("discover program type: %w", err)
 	}
 
-	return &Program{"", fd, "", "", info.Type}, nil
+	return &Program{"", fd, info.Name, "", info.Type}, nil
 }
 
 func (p *Program) String() string {
@@ -364,6 +420,15 @@ func (p *Program) String() string {
 	return fmt.Sprintf("%s(%v)", p.typ, p.fd)
 }
 
+// Name returns the name of the program.
+//
+// This is the name supplied via ProgramSpec.Name, truncated to the 15
+// characters the kernel keeps, or the name reported by the kernel for
+// programs obtained by ID, from a raw fd or from a pin.
+func (p *Program) Name() string {
+	return internal.TruncateObjName(p.name)
+}
+
 // Type returns the underlying type of the program.
 func (p *Program) Type() ProgramType {
 	return p.typ
@@ -414,6 +479,9 @@ func (p *Program) Clone() (*Program, error) {
 // Calling Pin on a previously pinned program will overwrite the path, except when
 // the new path already exists. Re-pinning across filesystems is not supported.
 //
+// Missing parent directories of fileName are created. The program itself
+// is pinned atomically, other processes never observe a partial pin.
+//
 // This requires bpffs to be mounted above fileName. See https://docs.cilium.io/en/k8s-doc/admin/#admin-mount-bpffs
 func (p *Program) Pin(fileName string) error {
 	if err := internal.Pin(p.pinnedPath, fileName, p.fd); err != nil {
@@ -425,6 +493,9 @@ func (p *Program) Pin(fileName string) error {
 
 // Unpin removes the persisted state for the Program from the BPF virtual filesystem.
 //
+// The Program itself remains loaded, since Unpin doesn't close the underlying
+// file descriptor.
+//
 // Failed calls to Unpin will not alter the state returned by IsPinned.
 //
 // Unpinning an unpinned Program returns nil.
@@ -529,12 +600,160 @@ func (p *Program) testRun(in []byte, repeat int, reset func()) (uint32, []byte,
 		return 0, nil, 0, fmt.Errorf("missing input")
 	}
 
-	if uint(len(in)) > math.MaxUint32 {
-		return 0, nil, 0, fmt.Errorf("input is too long")
+	res, err := p.run(&RunOptions{
+		Data:   in,
+		Repeat: uint32(repeat),
+		Reset:  reset,
+	})
+	if err != nil {
+		return 0, nil, 0, err
+	}
+
+	return res.ReturnValue, res.DataOut, res.Duration, nil
+}
+
+// RunOptions control the execution of a Program via Run.
+type RunOptions struct {
+	// Data is the input packet. May be empty for program types which
+	// don't take a packet.
+	Data []byte
+	// DataOut receives the packet after the program has run. It must be at
+	// least 258 bytes longer than Data, since programs may grow the packet
+	// and older kernels don't respect the size of the buffer. A buffer is
+	// allocated if DataOut is nil.
+	DataOut []byte
+	// Context is the raw context passed to the program, for example a
+	// struct __sk_buff. Requires at least Linux 5.2.
+	Context []byte
+	// ContextOut receives the context after the program has run. It must be
+	// large enough to hold the whole context, which may be larger than
+	// Context. A buffer is allocated if Context is set and ContextOut is nil.
+	ContextOut []byte
+	// Repeat is the number of times the program is executed. Zero is the
+	// same as one.
+	Repeat uint32
+	// Flags are passed to the kernel, see BPF_F_TEST_*.
+	Flags uint32
+	// Reset is called whenever the syscall is interrupted and restarted.
+	// Use it to reset timers like testing.B.ResetTimer. Optional.
+	Reset func()
+}
+
+// RunResult is the outcome of Run.
+type RunResult struct {
+	// ReturnValue of the last execution of the program.
+	ReturnValue uint32
+	// Duration is the average time a single execution took.
+	Duration time.Duration
+	// DataOut is the packet after the program has run.
+	DataOut []byte
+	// ContextOut is the context after the program has run. Nil if no
+	// context was passed.
+	ContextOut []byte
+}
+
+// Run executes the Program in the kernel via BPF_PROG_TEST_RUN, without
+// attaching it to a hook.
+//
+// opts may be nil, which is the same as passing zero RunOptions.
+//
+// This function requires at least Linux 4.12.
+func (p *Program) Run(opts *RunOptions) (*RunResult, error) {
+	if opts == nil {
+		opts = &RunOptions{}
+	}
+
+	res, err := p.run(opts)
+	if err != nil {
+		return nil, fmt.Errorf("can't run program: %w", err)
+	}
+	return res, nil
+}
+
+// XDPRunOptions control the execution of an XDP Program via RunXDP.
+type XDPRunOptions struct {
+	// Data is the input packet.
+	Data []byte
+	// IngressIfindex is the interface the packet appears to arrive on.
+	// Optional, must refer to an existing interface if set.
+	IngressIfindex uint32
+	// RXQueue is the receive queue the packet appears to arrive on.
+	// Requires IngressIfindex to be set.
+	RXQueue uint32
+	// Repeat is the number of times the program is executed. Zero is the
+	// same as one.
+	Repeat uint32
+}
+
+// XDPRunResult is the outcome of RunXDP.
+type XDPRunResult struct {
+	// Action returned by the last execution of the program, for example
+	// XDP_DROP.
+	Action uint32
+	// Duration is the average time a single execution took.
+	Duration time.Duration
+	// Data is the packet after the program has run.
+	Data []byte
+}
+
+// xdpMdSize is the size of struct xdp_md.
+const xdpMdSize = 24
+
+// RunXDP executes an XDP Program via BPF_PROG_TEST_RUN, passing a
+// struct xdp_md describing the packet as context.
+//
+// opts may be nil, which is the same as passing zero XDPRunOptions.
+//
+// This function requires at least Linux 5.14.
+func (p *Program) RunXDP(opts *XDPRunOptions) (*XDPRunResult, error) {
+	if p.Type() != XDP {
+		return nil, fmt.Errorf("can't run program: %s is not an XDP program", p.Type())
+	}
+
+	if opts == nil {
+		opts = &XDPRunOptions{}
+	}
+
+	if uint(len(opts.Data)) > math.MaxUint32 {
+		return nil, fmt.Errorf("can't run program: input is too long")
+	}
+
+	// See struct xdp_md in include/uapi/linux/bpf.h. The kernel requires
+	// data_end to match the size of the packet, data and data_meta are
+	// offsets from the start of it.
+	ctx := make([]byte, xdpMdSize)
+	internal.NativeEndian.PutUint32(ctx[4:], uint32(len(opts.Data)))
+	internal.NativeEndian.PutUint32(ctx[12:], opts.IngressIfindex)
+	internal.NativeEndian.PutUint32(ctx[16:], opts.RXQueue)
+
+	res, err := p.Run(&RunOptions{
+		Data:       opts.Data,
+		Context:    ctx,
+		ContextOut: make([]byte, xdpMdSize),
+		Repeat:     opts.Repeat,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &XDPRunResult{
+		Action:   res.ReturnValue,
+		Duration: res.Duration,
+		Data:     res.DataOut,
+	}, nil
+}
+
+func (p *Program) run(opts *RunOptions) (*RunResult, error) {
+	if uint(len(opts.Data)) > math.MaxUint32 {
+		return nil, fmt.Errorf("input is too long")
+	}
+
+	if uint(len(opts.Context)) > math.MaxUint32 {
+		return nil, fmt.Errorf("context is too long")
 	}
 
 	if err := haveProgTestRun(); err != nil {
-		return 0, nil, 0, err
+		return nil, err
 	}
 
 	// Older kernels ignore the dataSizeOut argument when copying to user space.
@@ -542,20 +761,38 @@ func (p *Program) testRun(in []byte, repeat int, reset func()) (uint32, []byte,
 	// size will be. Hence we allocate an output buffer which we hope will always be large
 	// enough, and panic if the kernel wrote past the end of the allocation.
 	// See https://patchwork.ozlabs.org/cover/1006822/
-	out := make([]byte, len(in)+outputPad)
+	out := opts.DataOut
+	switch {
+	case out == nil && len(opts.Data) > 0:
+		out = make([]byte, len(opts.Data)+outputPad)
+	case out != nil && len(out) < len(opts.Data)+outputPad:
+		return nil, fmt.Errorf("output buffer must be at least %d bytes", len(opts.Data)+outputPad)
+	}
+
+	ctxOut := opts.ContextOut
+	if ctxOut == nil && len(opts.Context) > 0 {
+		// The kernel returns ENOSPC unless the buffer can hold the
+		// whole context. No context is larger than a page.
+		ctxOut = make([]byte, os.Getpagesize())
+	}
 
 	fd, err := p.fd.Value()
 	if err != nil {
-		return 0, nil, 0, err
+		return nil, err
 	}
 
 	attr := bpfProgTestRunAttr{
 		fd:          fd,
-		dataSizeIn:  uint32(len(in)),
+		dataSizeIn:  uint32(len(opts.Data)),
 		dataSizeOut: uint32(len(out)),
-		dataIn:      internal.NewSlicePointer(in),
+		dataIn:      internal.NewSlicePointer(opts.Data),
 		dataOut:     internal.NewSlicePointer(out),
-		repeat:      uint32(repeat),
+		repeat:      opts.Repeat,
+		ctxSizeIn:   uint32(len(opts.Context)),
+		ctxSizeOut:  uint32(len(ctxOut)),
+		ctxIn:       internal.NewSlicePointer(opts.Context),
+		ctxOut:      internal.NewSlicePointer(ctxOut),
+		flags:       opts.Flags,
 	}
 
 	for {
@@ -565,13 +802,13 @@ func (p *Program) testRun(in []byte, repeat int, reset func()) (uint32, []byte,
 		}
 
 		if errors.Is(err, unix.EINTR) {
-			if reset != nil {
-				reset()
+			if opts.Reset != nil {
+				opts.Reset()
 			}
 			continue
 		}
 
-		return 0, nil, 0, fmt.Errorf("can't run test: %w", err)
+		return nil, fmt.Errorf("can't run test: %w", err)
 	}
 
 	if int(attr.dataSizeOut) > cap(out) {
@@ -579,10 +816,18 @@ func (p *Program) testRun(in []byte, repeat int, reset func()) (uint32, []byte,
 		// and the kernel wrote past the end of our buffer.
 		panic("kernel wrote past end of output buffer")
 	}
-	out = out[:int(attr.dataSizeOut)]
 
-	total := time.Duration(attr.duration) * time.Nanosecond
-	return attr.retval, out, total, nil
+	res := &RunResult{
+		ReturnValue: attr.retval,
+		Duration:    time.Duration(attr.duration) * time.Nanosecond,
+		DataOut:     out[:int(attr.dataSizeOut)],
+	}
+
+	if ctxOut != nil {
+		res.ContextOut = ctxOut[:int(attr.ctxSizeOut)]
+	}
+
+	return res, nil
 }
 
 func unmarshalProgram(buf []byte) (*Program, error) {
@@ -669,13 +914,17 @@ func LoadPinnedProgram(fileName string, opts *LoadPinOptions) (*Program, error)
 		return nil, err
 	}
 
+	return newPinnedProgramFromFD(fd, fileName)
+}
+
+func newPinnedProgramFromFD(fd *internal.FD, fileName string) (*Program, error) {
 	info, err := newProgramInfoFromFd(fd)
 	if err != nil {
 		_ = fd.Close()
 		return nil, fmt.Errorf("info for %s: %w", fileName, err)
 	}
 
-	return &Program{"", fd, filepath.Base(fileName), fileName, info.Type}, nil
+	return &Program{"", fd, info.Name, fileName, info.Type}, nil
 }
 
 // SanitizeName replaces all invalid characters in name with replacement.
@@ -702,17 +951,136 @@ func ProgramGetNextID(startID ProgramID) (ProgramID, error) {
 	return ProgramID(id), err
 }
 
+// ProgramIterator enumerates all programs loaded into the kernel.
+//
+// Use NewProgramIterator to create one.
+type ProgramIterator struct {
+	id   ProgramID
+	prog *Program
+	err  error
+}
+
+// NewProgramIterator returns an iterator over all programs in the system.
+//
+// Requires CAP_SYS_ADMIN.
+func NewProgramIterator() *ProgramIterator {
+	return &ProgramIterator{}
+}
+
+// Next opens the next program.
+//
+// Programs which are unloaded between retrieving their ID and opening
+// them are skipped.
+//
+// Returns false if there are no more programs or if an error occurred.
+// You must check Err in that case.
+func (pi *ProgramIterator) Next() bool {
+	pi.prog = nil
+	if pi.err != nil {
+		return false
+	}
+
+	for {
+		id, err := ProgramGetNextID(pi.id)
+		if errors.Is(err, os.ErrNotExist) {
+			return false
+		}
+		if err != nil {
+			pi.err = fmt.Errorf("get next program id: %w", err)
+			return false
+		}
+		pi.id = id
+
+		prog, err := NewProgramFromID(id)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			pi.err = fmt.Errorf("open program %d: %w", id, err)
+			return false
+		}
+
+		pi.prog = prog
+		return true
+	}
+}
+
+// Program returns the program opened by the last call to Next.
+//
+// The caller must close the program.
+func (pi *ProgramIterator) Program() *Program {
+	return pi.prog
+}
+
+// Err returns the error which made Next return false, if any.
+func (pi *ProgramIterator) Err() error {
+	return pi.err
+}
+
 // ID returns the systemwide unique ID of the program.
 //
 // Deprecated: use ProgramInfo.ID() instead.
 func (p *Program) ID() (ProgramID, error) {
-	info, err := bpfGetProgInfoByFD(p.fd, nil)
-	if err != nil {
+	var info bpfProgInfo
+	if err := bpfGetProgInfoByFD(p.fd, &info); err != nil {
 		return ProgramID(0), err
 	}
 	return ProgramID(info.id), nil
 }
 
+// minimumKernelVersions contains the mainline version which introduced
+// a program type. It is reported when probing for the type fails.
+var minimumKernelVersions = map[ProgramType]internal.Version{
+	SocketFilter:          {3, 19},
+	Kprobe:                {4, 1},
+	SchedCLS:              {4, 1},
+	SchedACT:              {4, 1},
+	TracePoint:            {4, 7},
+	XDP:                   {4, 8},
+	PerfEvent:             {4, 9},
+	CGroupSKB:             {4, 10},
+	CGroupSock:            {4, 10},
+	LWTIn:                 {4, 10},
+	LWTOut:                {4, 10},
+	LWTXmit:               {4, 10},
+	SockOps:               {4, 13},
+	SkSKB:                 {4, 14},
+	CGroupDevice:          {4, 15},
+	SkMsg:                 {4, 17},
+	RawTracepoint:         {4, 17},
+	CGroupSockAddr:        {4, 17},
+	LWTSeg6Local:          {4, 18},
+	LircMode2:             {4, 18},
+	SkReuseport:           {4, 19},
+	FlowDissector:         {4, 20},
+	CGroupSysctl:          {5, 2},
+	RawTracepointWritable: {5, 2},
+	CGroupSockopt:         {5, 3},
+	Tracing:               {5, 5},
+	StructOps:             {5, 6},
+	Extension:             {5, 6},
+	LSM:                   {5, 7},
+	SkLookup:              {5, 9},
+	Syscall:               {5, 14},
+	Netfilter:             {6, 4},
+}
+
+// checkKernelVersion returns an error if the running kernel is older than
+// required.
+//
+// Returns nil if the version of the running kernel can't be determined.
+func checkKernelVersion(name string, required internal.Version) error {
+	v, err := internal.KernelVersion()
+	if err != nil || !v.Less(required) {
+		return nil
+	}
+
+	return &internal.UnsupportedFeatureError{
+		Name:           name,
+		MinimumVersion: required,
+	}
+}
+
 func resolveBTFType(spec *btf.Spec, name string, progType ProgramType, attachType AttachType) (btf.Type, error) {
 	type match struct {
 		p ProgramType
@@ -105,6 +105,121 @@ func TestProgramBenchmark(t *testing.T) {
 	}
 }
 
+func TestProgramRunOptions(t *testing.T) {
+	prog := createSocketFilter(t)
+	defer prog.Close()
+
+	data := make([]byte, 14)
+	_, err := prog.Run(&RunOptions{Data: data, DataOut: make([]byte, len(data))})
+	if err == nil {
+		t.Error("Run accepts an output buffer without padding")
+	}
+
+	res, err := prog.Run(&RunOptions{Data: data, DataOut: make([]byte, len(data)+outputPad)})
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.DataOut) != len(data) {
+		t.Errorf("Expected %d bytes of output, got %d", len(data), len(res.DataOut))
+	}
+
+	// Socket filters require a packet, but nil options mustn't panic.
+	_, _ = prog.Run(nil)
+}
+
+func TestProgramRunContext(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.2", "context for BPF_PROG_TEST_RUN")
+
+	// Return __sk_buff->mark.
+	prog, err := NewProgram(&ProgramSpec{
+		Type: SocketFilter,
+		Instructions: asm.Instructions{
+			asm.LoadMem(asm.R0, asm.R1, 8, asm.Word),
+			asm.Return(),
+		},
+		License: "MIT",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prog.Close()
+
+	// The fields len, pkt_type and mark of struct __sk_buff.
+	ctx := make([]byte, 12)
+	internal.NativeEndian.PutUint32(ctx[8:], 42)
+
+	res, err := prog.Run(&RunOptions{
+		Data:    make([]byte, 14),
+		Context: ctx,
+		Repeat:  2,
+	})
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.ReturnValue != 42 {
+		t.Error("Expected return value 42, got", res.ReturnValue)
+	}
+	if len(res.DataOut) != 14 {
+		t.Error("Expected 14 bytes of output, got", len(res.DataOut))
+	}
+	if len(res.ContextOut) < len(ctx) {
+		t.Fatalf("Expected at least %d bytes of context output, got %d", len(ctx), len(res.ContextOut))
+	}
+	if mark := internal.NativeEndian.Uint32(res.ContextOut[8:]); mark != 42 {
+		t.Error("Expected mark 42 in context output, got", mark)
+	}
+}
+
+func TestProgramRunXDP(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.14", "xdp_md context for BPF_PROG_TEST_RUN")
+
+	prog, err := NewProgram(&ProgramSpec{
+		Type: XDP,
+		Instructions: asm.Instructions{
+			// XDP_DROP
+			asm.LoadImm(asm.R0, 1, asm.DWord),
+			asm.Return(),
+		},
+		License: "MIT",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prog.Close()
+
+	res, err := prog.RunXDP(&XDPRunOptions{
+		Data:           make([]byte, 14),
+		IngressIfindex: 1,
+		Repeat:         2,
+	})
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Action != 1 {
+		t.Error("Expected XDP_DROP, got", res.Action)
+	}
+	if len(res.Data) != 14 {
+		t.Error("Expected 14 bytes of output, got", len(res.Data))
+	}
+
+	// The kernel rejects an empty packet, but nil options must not panic.
+	if _, err := prog.RunXDP(nil); err == nil {
+		t.Error("RunXDP accepts an empty packet")
+	}
+
+	filter := createSocketFilter(t)
+	defer filter.Close()
+
+	if _, err := filter.RunXDP(nil); err == nil {
+		t.Error("RunXDP accepts a socket filter")
+	}
+}
+
 func TestProgramTestRunInterrupt(t *testing.T) {
 	testutils.SkipOnOldKernel(t, "5.0", "EINTR from BPF_PROG_TEST_RUN")
 
@@ -214,6 +329,33 @@ func TestProgramPin(t *testing.T) {
 	}
 }
 
+func TestProgramPinCreatesDirectories(t *testing.T) {
+	prog := createSocketFilter(t)
+	defer prog.Close()
+
+	tmp := testutils.TempBPFFS(t)
+	path := filepath.Join(tmp, "foo", "bar", "program")
+
+	if err := prog.Pin(path); err != nil {
+		t.Fatal("Can't pin program to nested path:", err)
+	}
+
+	pinned, err := LoadPinnedProgram(path, nil)
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinned.Close()
+
+	if err := prog.Unpin(); err != nil {
+		t.Fatal("Can't unpin program:", err)
+	}
+
+	if _, err := prog.Info(); err != nil {
+		t.Error("Program isn't usable after Unpin:", err)
+	}
+}
+
 func TestProgramUnpin(t *testing.T) {
 	prog := createSocketFilter(t)
 	c := qt.New(t)
@@ -277,6 +419,81 @@ func TestProgramVerifierOutputOnError(t *testing.T) {
 	if !strings.Contains(err.Error(), "exit") {
 		t.Error("No verifier output in error message")
 	}
+
+	if errors.Is(err, ErrNotSupported) {
+		t.Error("Verifier rejection is reported as ErrNotSupported:", err)
+	}
+
+	var ve *VerifierError
+	if !errors.As(err, &ve) {
+		t.Error("Error is not a VerifierError:", err)
+	}
+}
+
+func TestProgramIterator(t *testing.T) {
+	prog := createSocketFilter(t)
+	defer prog.Close()
+
+	info, err := prog.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, ok := info.ID()
+	if !ok {
+		t.Skip("Program IDs are not supported")
+	}
+
+	var found bool
+	it := NewProgramIterator()
+	for it.Next() {
+		p := it.Program()
+		if pi, err := p.Info(); err == nil {
+			if id, _ := pi.ID(); id == want {
+				found = true
+			}
+		}
+		p.Close()
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !found {
+		t.Error("Iterator didn't return program", want)
+	}
+}
+
+func TestProgramLogDisabled(t *testing.T) {
+	prog, err := NewProgramWithOptions(socketFilterSpec, ProgramOptions{
+		LogLevel:    1,
+		LogDisabled: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prog.Close()
+
+	if prog.VerifierLog != "" {
+		t.Error("Expected no verifier log, got", prog.VerifierLog)
+	}
+
+	_, err = NewProgramWithOptions(&ProgramSpec{
+		Type: SocketFilter,
+		Instructions: asm.Instructions{
+			asm.Return(),
+		},
+		License: "MIT",
+	}, ProgramOptions{
+		LogDisabled: true,
+	})
+	if err == nil {
+		t.Fatal("Expected program to be invalid")
+	}
+
+	var ve *VerifierError
+	if errors.As(err, &ve) && ve.Log != "" {
+		t.Error("Expected no verifier log, got", ve.Log)
+	}
 }
 
 func TestProgramKernelVersion(t *testing.T) {
@@ -324,9 +541,35 @@ func TestProgramVerifierOutput(t *testing.T) {
 		t.Fatal("Expected an error from invalid program")
 	}
 
-	var ve *internal.VerifierError
+	var ve *VerifierError
 	if !errors.As(err, &ve) {
-		t.Error("Error is not a VerifierError")
+		t.Fatal("Error is not a VerifierError")
+	}
+
+	if ve.Log == "" {
+		t.Error("VerifierError has no log")
+	}
+	if !strings.Contains(ve.Message, "exit") {
+		t.Error("Expected message to mention the missing exit, got", ve.Message)
+	}
+	if strings.Contains(err.Error(), "\n") {
+		t.Error("Error() isn't a single line:", err)
+	}
+
+	_, err = NewProgram(&ProgramSpec{
+		Type: SocketFilter,
+		Instructions: asm.Instructions{
+			asm.Mov.Imm(asm.R0, 0),
+			asm.LoadMem(asm.R0, asm.R2, 0, asm.Word),
+			asm.Return(),
+		},
+		License: "MIT",
+	})
+	if !errors.As(err, &ve) {
+		t.Fatal("Error is not a VerifierError")
+	}
+	if ve.Line != 1 {
+		t.Error("Expected the error on instruction 1, got", ve.Line)
 	}
 }
 
@@ -355,14 +598,55 @@ func TestProgramName(t *testing.T) {
 	prog := createSocketFilter(t)
 	defer prog.Close()
 
-	info, err := bpfGetProgInfoByFD(prog.fd, nil)
-	if err != nil {
+	var info bpfProgInfo
+	if err := bpfGetProgInfoByFD(prog.fd, &info); err != nil {
 		t.Fatal(err)
 	}
 
 	if name := internal.CString(info.name[:]); name != "test" {
 		t.Errorf("Name is not test, got '%s'", name)
 	}
+
+	if name := prog.Name(); name != "test" {
+		t.Errorf("Name() is not test, got '%s'", name)
+	}
+
+	path := filepath.Join(testutils.TempBPFFS(t), "pinned")
+	if err := prog.Pin(path); err != nil {
+		t.Fatal(err)
+	}
+
+	pinned, err := LoadPinnedProgram(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pinned.Close()
+
+	if name := pinned.Name(); name != "test" {
+		t.Errorf("Name() of pinned program is not test, got '%s'", name)
+	}
+}
+
+func TestProgramRequiredKernelVersion(t *testing.T) {
+	spec := socketFilterSpec.Copy()
+	spec.RequiredKernelVersion = internal.Version{255, 0, 0}.Kernel()
+
+	_, err := NewProgram(spec)
+	if !errors.Is(err, ErrNotSupported) {
+		t.Fatal("Expected ErrNotSupported, got", err)
+	}
+
+	var ufe *internal.UnsupportedFeatureError
+	if !errors.As(err, &ufe) || ufe.MinimumVersion != (internal.Version{255, 0, 0}) {
+		t.Error("Expected UnsupportedFeatureError with minimum version, got", err)
+	}
+
+	spec.RequiredKernelVersion = internal.Version{3, 19, 0}.Kernel()
+	prog, err := NewProgram(spec)
+	if err != nil {
+		t.Fatal("Can't load program with satisfied version:", err)
+	}
+	prog.Close()
 }
 
 func TestSanitizeName(t *testing.T) {
@@ -436,6 +720,7 @@ func TestProgramMarshaling(t *testing.T) {
 
 func TestProgramFromFD(t *testing.T) {
 	prog, err := NewProgram(&ProgramSpec{
+		Name: "test",
 		Type: SocketFilter,
 		Instructions: asm.Instructions{
 			asm.LoadImm(asm.R0, 0, asm.DWord),
@@ -462,6 +747,14 @@ func TestProgramFromFD(t *testing.T) {
 	// inadvertently, leading to spurious test failures.
 	// To avoid this we have to "leak" one of the programs.
 	prog2.fd.Forget()
+
+	if prog2.Type() != SocketFilter {
+		t.Error("Expected type SocketFilter, got", prog2.Type())
+	}
+
+	if haveObjName() == nil && prog2.name != "test" {
+		t.Errorf("Expected name test, got %q", prog2.name)
+	}
 }
 
 func TestHaveProgTestRun(t *testing.T) {
@@ -530,9 +823,25 @@ func TestNewProgramFromID(t *testing.T) {
 		t.Fatal("Could not get ID of program:", err)
 	}
 
-	if _, err = NewProgramFromID(next); err != nil {
+	prog2, err := NewProgramFromID(next)
+	if err != nil {
 		t.Fatalf("Can't get FD for program ID %d: %v", uint32(next), err)
 	}
+	defer prog2.Close()
+
+	info, err := prog.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info2, err := prog2.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Tag != info2.Tag {
+		t.Errorf("Expected tag %s, got %s", info.Tag, info2.Tag)
+	}
 
 	// As there can be multiple programs, we use max(uint32) as ProgramID to trigger an expected error.
 	_, err = NewProgramFromID(ProgramID(math.MaxUint32))
@@ -778,6 +1087,20 @@ func ExampleProgram_unmarshalFromMap() {
 	}
 }
 
+// ExampleProgramGetNextID demonstrates how to enumerate all programs
+// loaded into the kernel, similar to bpftool prog list.
+func ExampleProgramIterator() {
+	it := NewProgramIterator()
+	for it.Next() {
+		prog := it.Program()
+		fmt.Println(prog)
+		prog.Close()
+	}
+	if err := it.Err(); err != nil {
+		panic(err)
+	}
+}
+
 func ExampleProgramSpec_Tag() {
 	spec := &ProgramSpec{
 		Type: SocketFilter,
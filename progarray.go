@@ -0,0 +1,56 @@
+package ebpf
+
+import (
+	"fmt"
+)
+
+// ProgramArrayMap stores programs by index, for use with tail calls.
+//
+// It translates between *Program and the file descriptors and IDs used
+// by the kernel.
+type ProgramArrayMap struct {
+	m *Map
+}
+
+// NewProgramArrayMap wraps a Map of type ProgramArray.
+//
+// The map isn't copied, closing it invalidates the ProgramArrayMap.
+func NewProgramArrayMap(m *Map) (*ProgramArrayMap, error) {
+	if m.Type() != ProgramArray {
+		return nil, fmt.Errorf("%s is not a program array", m)
+	}
+	return &ProgramArrayMap{m}, nil
+}
+
+// Map returns the underlying Map.
+func (pa *ProgramArrayMap) Map() *Map {
+	return pa.m
+}
+
+// Put stores prog at index, replacing any previous program.
+func (pa *ProgramArrayMap) Put(index uint32, prog *Program) error {
+	if prog == nil {
+		return fmt.Errorf("put index %d: program is nil", index)
+	}
+	return pa.m.Put(index, prog)
+}
+
+// Get returns the program stored at index.
+//
+// The caller must close the returned program.
+//
+// Returns ErrKeyNotExist if no program is stored at index.
+func (pa *ProgramArrayMap) Get(index uint32) (*Program, error) {
+	var prog *Program
+	if err := pa.m.Lookup(index, &prog); err != nil {
+		return nil, err
+	}
+	return prog, nil
+}
+
+// Delete removes the program stored at index.
+//
+// Returns ErrKeyNotExist if no program is stored at index.
+func (pa *ProgramArrayMap) Delete(index uint32) error {
+	return pa.m.Delete(index)
+}
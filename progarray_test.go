@@ -0,0 +1,66 @@
+package ebpf
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestProgramArrayMap(t *testing.T) {
+	arr, err := NewMap(&MapSpec{
+		Type:       ProgramArray,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer arr.Close()
+
+	pa, err := NewProgramArrayMap(arr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prog := createSocketFilter(t)
+	defer prog.Close()
+
+	if err := pa.Put(1, prog); err != nil {
+		t.Fatal("Can't put program:", err)
+	}
+
+	got, err := pa.Get(1)
+	if err != nil {
+		t.Fatal("Can't get program:", err)
+	}
+	defer got.Close()
+
+	want, err := prog.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := got.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Tag != want.Tag {
+		t.Errorf("Expected program with tag %s, got %s", want.Tag, info.Tag)
+	}
+
+	if _, err := pa.Get(0); !errors.Is(err, ErrKeyNotExist) {
+		t.Error("Expected ErrKeyNotExist for empty index, got", err)
+	}
+
+	if err := pa.Delete(1); err != nil {
+		t.Fatal("Can't delete program:", err)
+	}
+	if _, err := pa.Get(1); !errors.Is(err, ErrKeyNotExist) {
+		t.Error("Expected ErrKeyNotExist after delete, got", err)
+	}
+
+	hash := createHash()
+	defer hash.Close()
+	if _, err := NewProgramArrayMap(hash); err == nil {
+		t.Error("NewProgramArrayMap accepts a hash map")
+	}
+}
@@ -0,0 +1,91 @@
+package ebpf
+
+import (
+	"fmt"
+)
+
+// QueueMap is a FIFO of values.
+//
+// Requires at least Linux 4.20.
+type QueueMap struct {
+	m *Map
+}
+
+// NewQueueMap wraps a Map of type Queue.
+//
+// The map isn't copied, closing it invalidates the QueueMap.
+func NewQueueMap(m *Map) (*QueueMap, error) {
+	if m.Type() != Queue {
+		return nil, fmt.Errorf("%s is not a queue", m)
+	}
+	return &QueueMap{m}, nil
+}
+
+// Map returns the underlying Map.
+func (q *QueueMap) Map() *Map {
+	return q.m
+}
+
+// Push appends a value to the end of the queue.
+//
+// Returns an error if the queue is full.
+func (q *QueueMap) Push(value interface{}) error {
+	return q.m.Update(nil, value, UpdateAny)
+}
+
+// Pop removes the value at the front of the queue.
+//
+// Returns ErrKeyNotExist if the queue is empty.
+func (q *QueueMap) Pop(valueOut interface{}) error {
+	return q.m.LookupAndDelete(nil, valueOut)
+}
+
+// Peek retrieves the value at the front of the queue without removing it.
+//
+// Returns ErrKeyNotExist if the queue is empty.
+func (q *QueueMap) Peek(valueOut interface{}) error {
+	return q.m.Lookup(nil, valueOut)
+}
+
+// StackMap is a LIFO of values.
+//
+// Requires at least Linux 4.20.
+type StackMap struct {
+	m *Map
+}
+
+// NewStackMap wraps a Map of type Stack.
+//
+// The map isn't copied, closing it invalidates the StackMap.
+func NewStackMap(m *Map) (*StackMap, error) {
+	if m.Type() != Stack {
+		return nil, fmt.Errorf("%s is not a stack", m)
+	}
+	return &StackMap{m}, nil
+}
+
+// Map returns the underlying Map.
+func (s *StackMap) Map() *Map {
+	return s.m
+}
+
+// Push adds a value to the top of the stack.
+//
+// Returns an error if the stack is full.
+func (s *StackMap) Push(value interface{}) error {
+	return s.m.Update(nil, value, UpdateAny)
+}
+
+// Pop removes the value at the top of the stack.
+//
+// Returns ErrKeyNotExist if the stack is empty.
+func (s *StackMap) Pop(valueOut interface{}) error {
+	return s.m.LookupAndDelete(nil, valueOut)
+}
+
+// Peek retrieves the value at the top of the stack without removing it.
+//
+// Returns ErrKeyNotExist if the stack is empty.
+func (s *StackMap) Peek(valueOut interface{}) error {
+	return s.m.Lookup(nil, valueOut)
+}
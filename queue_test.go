@@ -0,0 +1,112 @@
+package ebpf
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cilium/ebpf/internal/testutils"
+)
+
+func TestQueueMap(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "4.20", "map type queue")
+
+	m, err := NewMap(&MapSpec{
+		Type:       Queue,
+		ValueSize:  4,
+		MaxEntries: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	q, err := NewQueueMap(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []uint32{1, 2} {
+		if err := q.Push(v); err != nil {
+			t.Fatal("Can't push:", err)
+		}
+	}
+
+	var v uint32
+	if err := q.Peek(&v); err != nil {
+		t.Fatal("Can't peek:", err)
+	}
+	if v != 1 {
+		t.Error("Expected to peek 1, got", v)
+	}
+
+	for _, want := range []uint32{1, 2} {
+		if err := q.Pop(&v); err != nil {
+			t.Fatal("Can't pop:", err)
+		}
+		if v != want {
+			t.Errorf("Expected to pop %d, got %d", want, v)
+		}
+	}
+
+	if err := q.Pop(&v); !errors.Is(err, ErrKeyNotExist) {
+		t.Error("Expected ErrKeyNotExist from empty queue, got", err)
+	}
+
+	if _, err := m.NextKeyBytes(nil); err == nil {
+		t.Error("NextKey doesn't return an error for a queue")
+	}
+
+	if _, err := NewStackMap(m); err == nil {
+		t.Error("NewStackMap accepts a queue")
+	}
+}
+
+func TestStackMap(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "4.20", "map type stack")
+
+	m, err := NewMap(&MapSpec{
+		Type:       Stack,
+		ValueSize:  4,
+		MaxEntries: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	s, err := NewStackMap(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []uint32{1, 2} {
+		if err := s.Push(v); err != nil {
+			t.Fatal("Can't push:", err)
+		}
+	}
+
+	var v uint32
+	if err := s.Peek(&v); err != nil {
+		t.Fatal("Can't peek:", err)
+	}
+	if v != 2 {
+		t.Error("Expected to peek 2, got", v)
+	}
+
+	for _, want := range []uint32{2, 1} {
+		if err := s.Pop(&v); err != nil {
+			t.Fatal("Can't pop:", err)
+		}
+		if v != want {
+			t.Errorf("Expected to pop %d, got %d", want, v)
+		}
+	}
+
+	if err := s.Pop(&v); !errors.Is(err, ErrKeyNotExist) {
+		t.Error("Expected ErrKeyNotExist from empty stack, got", err)
+	}
+
+	if _, err := NewQueueMap(m); err == nil {
+		t.Error("NewQueueMap accepts a stack")
+	}
+}
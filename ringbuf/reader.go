@@ -0,0 +1,353 @@
+package ringbuf
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/unix"
+)
+
+var (
+	errClosed  = errors.New("ringbuf reader was closed")
+	errDiscard = errors.New("sample discarded")
+	errBusy    = errors.New("sample not committed yet")
+	errEOR     = errors.New("end of ring")
+)
+
+// ringbufHeader from 'struct bpf_ringbuf_hdr' in kernel/bpf/ringbuf.c
+type ringbufHeader struct {
+	Len   uint32
+	PgOff uint32
+}
+
+const (
+	ringbufHeaderSize = 8
+
+	ringbufBusyBit    = 1 << 31
+	ringbufDiscardBit = 1 << 30
+)
+
+func (rh *ringbufHeader) isBusy() bool {
+	return rh.Len&ringbufBusyBit != 0
+}
+
+func (rh *ringbufHeader) isDiscard() bool {
+	return rh.Len&ringbufDiscardBit != 0
+}
+
+func (rh *ringbufHeader) dataLen() int {
+	return int(rh.Len & ^uint32(ringbufBusyBit|ringbufDiscardBit))
+}
+
+// RingBufRecord contains a sample submitted via bpf_ringbuf_output or
+// bpf_ringbuf_submit.
+type RingBufRecord struct {
+	RawSample []byte
+}
+
+// NB: Has to be preceded by a call to ring.loadConsumer.
+func readRecord(rd *ringReader, rec *RingBufRecord, header []byte) error {
+	if _, err := io.ReadFull(rd, header); err == io.EOF {
+		return errEOR
+	} else if err != nil {
+		return fmt.Errorf("can't read event header: %w", err)
+	}
+
+	rh := ringbufHeader{
+		Len:   internal.NativeEndian.Uint32(header[0:4]),
+		PgOff: internal.NativeEndian.Uint32(header[4:8]),
+	}
+
+	if rh.isBusy() {
+		// The next sample in the ring is not committed yet. Don't update
+		// the consumer position, so that the sample is read again later.
+		return errBusy
+	}
+
+	// Samples are padded to 8 byte alignment.
+	dataLenAligned := uint64(internal.Align(rh.dataLen(), 8))
+
+	if rh.isDiscard() {
+		// Skip discarded samples without copying them out of the ring.
+		rd.skipRead(dataLenAligned)
+		rd.storeConsumer()
+		return errDiscard
+	}
+
+	if cap(rec.RawSample) < int(dataLenAligned) {
+		rec.RawSample = make([]byte, dataLenAligned)
+	} else {
+		rec.RawSample = rec.RawSample[:dataLenAligned]
+	}
+
+	if _, err := io.ReadFull(rd, rec.RawSample); err != nil {
+		return fmt.Errorf("can't read sample: %w", err)
+	}
+
+	rd.storeConsumer()
+	rec.RawSample = rec.RawSample[:rh.dataLen()]
+	return nil
+}
+
+// RingBuffer allows reading bpf_ringbuf_output
+// from user space.
+type RingBuffer struct {
+	// mu protects read/write access to the RingBuffer structure.
+	mu sync.Mutex
+
+	// Keep a reference to the map alive, since the ring is only
+	// valid as long as the map exists.
+	ringbufMap *ebpf.Map
+	ring       *ringbufEventRing
+
+	epollFd     int
+	epollEvents []unix.EpollEvent
+	// Eventfd for closing
+	closeFd int
+	// Ensure we only close once
+	closeOnce sync.Once
+
+	header []byte
+}
+
+// New creates a new BPF ring buffer reader.
+//
+// ringbufMap must be a RingBuf map.
+func New(ringbufMap *ebpf.Map) (rb *RingBuffer, err error) {
+	if ringbufMap.Type() != ebpf.RingBuf {
+		return nil, fmt.Errorf("invalid Map type: %s", ringbufMap.Type())
+	}
+
+	maxEntries := int(ringbufMap.MaxEntries())
+	if maxEntries == 0 || (maxEntries&(maxEntries-1)) != 0 {
+		return nil, fmt.Errorf("ringbuffer map size %d is zero or not a power of two", maxEntries)
+	}
+
+	epollFd, err := unix.EpollCreate1(unix.EPOLL_CLOEXEC)
+	if err != nil {
+		return nil, fmt.Errorf("can't create epoll fd: %v", err)
+	}
+
+	var (
+		fds  = []int{epollFd}
+		ring *ringbufEventRing
+	)
+
+	defer func() {
+		if err != nil {
+			for _, fd := range fds {
+				unix.Close(fd)
+			}
+			if ring != nil {
+				ring.Close()
+			}
+		}
+	}()
+
+	ringbufMap, err = ringbufMap.Clone()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			ringbufMap.Close()
+		}
+	}()
+
+	if err := addToEpoll(epollFd, ringbufMap.FD()); err != nil {
+		return nil, err
+	}
+
+	ring, err = newRingBufEventRing(ringbufMap.FD(), maxEntries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ringbuf ring: %w", err)
+	}
+
+	closeFd, err := unix.Eventfd(0, unix.O_CLOEXEC|unix.O_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	fds = append(fds, closeFd)
+
+	if err := addToEpoll(epollFd, closeFd); err != nil {
+		return nil, err
+	}
+
+	rb = &RingBuffer{
+		ringbufMap: ringbufMap,
+		ring:       ring,
+		epollFd:    epollFd,
+		// Allocate extra event for closeFd
+		epollEvents: make([]unix.EpollEvent, 2),
+		closeFd:     closeFd,
+		header:      make([]byte, ringbufHeaderSize),
+	}
+	runtime.SetFinalizer(rb, (*RingBuffer).Close)
+	return rb, nil
+}
+
+func addToEpoll(epollfd, fd int) error {
+	event := unix.EpollEvent{
+		Events: unix.EPOLLIN,
+		Fd:     int32(fd),
+	}
+
+	if err := unix.EpollCtl(epollfd, unix.EPOLL_CTL_ADD, fd, &event); err != nil {
+		return fmt.Errorf("can't add fd to epoll: %v", err)
+	}
+	return nil
+}
+
+// Close frees resources used by the reader.
+//
+// It interrupts calls to Read, ReadInto and Poll.
+func (rb *RingBuffer) Close() error {
+	var err error
+	rb.closeOnce.Do(func() {
+		runtime.SetFinalizer(rb, nil)
+
+		// Interrupt Read() via the event fd.
+		var value [8]byte
+		internal.NativeEndian.PutUint64(value[:], 1)
+		_, err = unix.Write(rb.closeFd, value[:])
+		if err != nil {
+			err = fmt.Errorf("can't write event fd: %v", err)
+			return
+		}
+
+		// Acquire the lock. This ensures that Read isn't running.
+		rb.mu.Lock()
+		defer rb.mu.Unlock()
+
+		unix.Close(rb.epollFd)
+		unix.Close(rb.closeFd)
+		rb.epollFd, rb.closeFd = -1, -1
+
+		if rb.ring != nil {
+			rb.ring.Close()
+		}
+		rb.ring = nil
+
+		rb.ringbufMap.Close()
+	})
+	if err != nil {
+		return fmt.Errorf("close RingBuffer: %w", err)
+	}
+	return nil
+}
+
+// Read the next record from the BPF ringbuf.
+//
+// The function blocks until a record is available. The returned slice
+// is newly allocated for each call, use ReadInto to avoid this.
+//
+// Calling Close interrupts the function.
+func (rb *RingBuffer) Read() ([]byte, error) {
+	var rec RingBufRecord
+	if err := rb.ReadInto(&rec); err != nil {
+		return nil, err
+	}
+	return rec.RawSample, nil
+}
+
+// ReadInto is like Read except that it allows reusing the RawSample
+// buffer of rec between calls.
+func (rb *RingBuffer) ReadInto(rec *RingBufRecord) error {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	if rb.ring == nil {
+		return errClosed
+	}
+
+	for {
+		rb.ring.loadConsumer()
+
+		err := readRecord(rb.ring.ringReader, rec, rb.header)
+		if err == errDiscard {
+			continue
+		}
+
+		if err == errBusy || err == errEOR {
+			// Wait for the kernel to submit more data.
+			if err := rb.wait(-1); err != nil {
+				return err
+			}
+			continue
+		}
+
+		return err
+	}
+}
+
+// Poll waits until a record is available or the timeout expires.
+//
+// A negative timeout blocks indefinitely. Returns an error wrapping
+// os.ErrDeadlineExceeded if no record became available in time.
+//
+// Calling Close interrupts the function.
+func (rb *RingBuffer) Poll(timeout time.Duration) error {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	if rb.ring == nil {
+		return errClosed
+	}
+
+	if !rb.ring.isEmpty() {
+		return nil
+	}
+
+	msec := -1
+	if timeout >= 0 {
+		msec = int(timeout / time.Millisecond)
+	}
+
+	return rb.wait(msec)
+}
+
+// wait blocks until the kernel notifies the reader of new data.
+//
+// NB: Has to be called with mu held.
+func (rb *RingBuffer) wait(msec int) error {
+	for {
+		nEvents, err := unix.EpollWait(rb.epollFd, rb.epollEvents, msec)
+		if temp, ok := err.(temporaryError); ok && temp.Temporary() {
+			// Retry the syscall if we we're interrupted, see https://github.com/golang/go/issues/20400
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if nEvents == 0 {
+			return fmt.Errorf("poll ringbuf: %w", os.ErrDeadlineExceeded)
+		}
+
+		for _, event := range rb.epollEvents[:nEvents] {
+			if int(event.Fd) == rb.closeFd {
+				return errClosed
+			}
+		}
+
+		return nil
+	}
+}
+
+type temporaryError interface {
+	Temporary() bool
+}
+
+// IsClosed returns true if the error occurred because
+// a RingBuffer was closed.
+func IsClosed(err error) bool {
+	return errors.Is(err, errClosed)
+}
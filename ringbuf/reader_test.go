@@ -0,0 +1,246 @@
+package ringbuf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal/testutils"
+)
+
+func TestRingBuffer(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.8", "BPF ring buffer")
+
+	prog, events := mustOutputSamplesProg(t, 0, 5, 17)
+	defer prog.Close()
+	defer events.Close()
+
+	rb, err := New(events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rb.Close()
+
+	if err := rb.Poll(0); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatal("Expected Poll on empty ring to time out, got", err)
+	}
+
+	ret, _, err := prog.Test(make([]byte, 14))
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if errno := syscall.Errno(-int32(ret)); errno != 0 {
+		t.Fatal("Expected 0 as return value, got", errno)
+	}
+
+	if err := rb.Poll(time.Second); err != nil {
+		t.Fatal("Can't poll ring:", err)
+	}
+
+	// The discarded sample is skipped.
+	for _, size := range []int{5, 17} {
+		sample, err := rb.Read()
+		if err != nil {
+			t.Fatal("Can't read samples:", err)
+		}
+
+		want := make([]byte, size)
+		for i := range want {
+			want[i] = byte(i + 1)
+		}
+		if !bytes.Equal(sample, want) {
+			t.Errorf("Expected sample %v, got %v", want, sample)
+		}
+	}
+
+	if err := rb.Poll(0); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatal("Expected ring to be empty, got", err)
+	}
+}
+
+func TestRingBufferReadInto(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.8", "BPF ring buffer")
+
+	prog, events := mustOutputSamplesProg(t, 17, 5)
+	defer prog.Close()
+	defer events.Close()
+
+	rb, err := New(events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rb.Close()
+
+	if _, _, err := prog.Test(make([]byte, 14)); err != nil {
+		t.Fatal(err)
+	}
+
+	var rec RingBufRecord
+	if err := rb.ReadInto(&rec); err != nil {
+		t.Fatal("Can't read first sample:", err)
+	}
+	if len(rec.RawSample) != 17 {
+		t.Fatal("Expected first sample to have length 17, got", len(rec.RawSample))
+	}
+	buf := rec.RawSample
+
+	if err := rb.ReadInto(&rec); err != nil {
+		t.Fatal("Can't read second sample:", err)
+	}
+	if len(rec.RawSample) != 5 {
+		t.Fatal("Expected second sample to have length 5, got", len(rec.RawSample))
+	}
+	if &rec.RawSample[0] != &buf[0] {
+		t.Error("ReadInto doesn't reuse the sample buffer")
+	}
+}
+
+func TestRingBufferClose(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.8", "BPF ring buffer")
+
+	prog, events := mustOutputSamplesProg(t)
+	defer prog.Close()
+	defer events.Close()
+
+	rb, err := New(events)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := rb.Read()
+		errs <- err
+	}()
+
+	// Give the goroutine a chance to block in Read.
+	time.Sleep(10 * time.Millisecond)
+
+	if err := rb.Close(); err != nil {
+		t.Fatal("Can't close reader:", err)
+	}
+
+	select {
+	case err := <-errs:
+		if !IsClosed(err) {
+			t.Fatal("Expected Read to return a closed error, got", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close doesn't interrupt Read")
+	}
+
+	if err := rb.Close(); err != nil {
+		t.Fatal("Second Close returns an error:", err)
+	}
+
+	if _, err := rb.Read(); !IsClosed(err) {
+		t.Fatal("Expected Read on closed reader to fail, got", err)
+	}
+}
+
+func TestNewInvalidMap(t *testing.T) {
+	m, err := ebpf.NewMap(&ebpf.MapSpec{
+		Type:       ebpf.Array,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	if _, err := New(m); err == nil {
+		t.Fatal("Expected an error when using an Array")
+	}
+}
+
+// outputSamplesProg returns a program which submits a sample of each given
+// size to the returned ring buffer. A sample of size zero is reserved and
+// then discarded instead.
+func outputSamplesProg(sampleSizes ...int) (*ebpf.Program, *ebpf.Map, error) {
+	events, err := ebpf.NewMap(&ebpf.MapSpec{
+		Type:       ebpf.RingBuf,
+		MaxEntries: 4096,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var maxSampleSize int
+	for _, sampleSize := range sampleSizes {
+		if sampleSize > maxSampleSize {
+			maxSampleSize = sampleSize
+		}
+	}
+
+	// Fill a buffer on the stack with 1, 2, 3 ...
+	bufSize := (maxSampleSize/8 + 1) * 8
+	var insns asm.Instructions
+	for i := 0; i < bufSize; i++ {
+		insns = append(insns,
+			asm.Mov.Imm(asm.R0, int32(i+1)),
+			asm.StoreMem(asm.RFP, int16(i-bufSize), asm.R0, asm.Byte),
+		)
+	}
+
+	for i, sampleSize := range sampleSizes {
+		if sampleSize == 0 {
+			skip := fmt.Sprintf("skip_discard_%d", i)
+			insns = append(insns,
+				asm.LoadMapPtr(asm.R1, events.FD()),
+				asm.Mov.Imm(asm.R2, 8),
+				asm.Mov.Imm(asm.R3, 0),
+				asm.FnRingbufReserve.Call(),
+				asm.JEq.Imm(asm.R0, 0, skip),
+				asm.Mov.Reg(asm.R1, asm.R0),
+				asm.Mov.Imm(asm.R2, 0),
+				asm.FnRingbufDiscard.Call(),
+				asm.Mov.Imm(asm.R0, 0).Sym(skip),
+			)
+			continue
+		}
+
+		insns = append(insns,
+			asm.LoadMapPtr(asm.R1, events.FD()),
+			asm.Mov.Reg(asm.R2, asm.RFP),
+			asm.Add.Imm(asm.R2, int32(-bufSize)),
+			asm.Mov.Imm(asm.R3, int32(sampleSize)),
+			asm.Mov.Imm(asm.R4, 0),
+			asm.FnRingbufOutput.Call(),
+		)
+	}
+
+	insns = append(insns, asm.Return())
+
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		License:      "MIT",
+		Type:         ebpf.XDP,
+		Instructions: insns,
+	})
+	if err != nil {
+		events.Close()
+		return nil, nil, err
+	}
+
+	return prog, events, nil
+}
+
+func mustOutputSamplesProg(tb testing.TB, sampleSizes ...int) (*ebpf.Program, *ebpf.Map) {
+	tb.Helper()
+
+	prog, events, err := outputSamplesProg(sampleSizes...)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return prog, events
+}
@@ -0,0 +1,122 @@
+package ringbuf
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/cilium/ebpf/internal/unix"
+)
+
+// ringbufEventRing is the consumer page followed by the producer page and
+// the data pages of a BPF ring buffer.
+type ringbufEventRing struct {
+	prod []byte
+	cons []byte
+	*ringReader
+}
+
+func newRingBufEventRing(mapFD, size int) (*ringbufEventRing, error) {
+	pageSize := os.Getpagesize()
+
+	cons, err := unix.Mmap(mapFD, 0, pageSize, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	if err != nil {
+		return nil, fmt.Errorf("can't mmap consumer page: %w", err)
+	}
+
+	// The kernel maps the data pages twice in a row, which means that
+	// records wrapping around the end of the ring can be read contiguously.
+	prod, err := unix.Mmap(mapFD, int64(pageSize), pageSize+2*size, unix.PROT_READ, unix.MAP_SHARED)
+	if err != nil {
+		_ = unix.Munmap(cons)
+		return nil, fmt.Errorf("can't mmap data pages: %w", err)
+	}
+
+	consPos := (*uint64)(unsafe.Pointer(&cons[0]))
+	prodPos := (*uint64)(unsafe.Pointer(&prod[0]))
+
+	ring := &ringbufEventRing{
+		prod:       prod,
+		cons:       cons,
+		ringReader: newRingReader(consPos, prodPos, prod[pageSize:]),
+	}
+	runtime.SetFinalizer(ring, (*ringbufEventRing).Close)
+
+	return ring, nil
+}
+
+func (ring *ringbufEventRing) Close() {
+	runtime.SetFinalizer(ring, nil)
+
+	_ = unix.Munmap(ring.prod)
+	_ = unix.Munmap(ring.cons)
+
+	ring.prod = nil
+	ring.cons = nil
+}
+
+type ringReader struct {
+	prodPos, consPos *uint64
+	cons             uint64
+	mask             uint64
+	ring             []byte
+}
+
+func newRingReader(consPos, prodPos *uint64, ring []byte) *ringReader {
+	return &ringReader{
+		prodPos: prodPos,
+		consPos: consPos,
+		cons:    atomic.LoadUint64(consPos),
+		// cap is always a power of two, and the data is mapped twice.
+		mask: uint64(cap(ring)/2 - 1),
+		ring: ring,
+	}
+}
+
+func (rr *ringReader) isEmpty() bool {
+	cons := atomic.LoadUint64(rr.consPos)
+	prod := atomic.LoadUint64(rr.prodPos)
+	return prod == cons
+}
+
+func (rr *ringReader) loadConsumer() {
+	rr.cons = atomic.LoadUint64(rr.consPos)
+}
+
+func (rr *ringReader) storeConsumer() {
+	// Commit the new consumer position. This lets the kernel know that
+	// the ring buffer has been consumed.
+	atomic.StoreUint64(rr.consPos, rr.cons)
+}
+
+// clamp delta to 'end' if 'start+delta' is beyond 'end'
+func clamp(start, end, delta uint64) uint64 {
+	if remainder := end - start; delta > remainder {
+		return remainder
+	}
+	return delta
+}
+
+func (rr *ringReader) skipRead(skipBytes uint64) {
+	rr.cons += clamp(rr.cons, atomic.LoadUint64(rr.prodPos), skipBytes)
+}
+
+func (rr *ringReader) Read(p []byte) (int, error) {
+	prod := atomic.LoadUint64(rr.prodPos)
+
+	n := clamp(rr.cons, prod, uint64(len(p)))
+
+	start := rr.cons & rr.mask
+
+	copy(p, rr.ring[start:start+n])
+	rr.cons += n
+
+	if prod == rr.cons {
+		return int(n), io.EOF
+	}
+
+	return int(n), nil
+}
@@ -0,0 +1,61 @@
+package ringbuf
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/cilium/ebpf/internal"
+)
+
+func TestRingBufferReadRecord(t *testing.T) {
+	// Two copies of the data pages, like the kernel maps them.
+	const size = 64
+	ring := make([]byte, 2*size)
+
+	var (
+		prod, cons uint64
+		header     = make([]byte, ringbufHeaderSize)
+	)
+
+	putRecord := func(flags uint32, data []byte) {
+		start := prod % size
+		internal.NativeEndian.PutUint32(ring[start:], uint32(len(data))|flags)
+		copy(ring[start+ringbufHeaderSize:], data)
+		prod += uint64(ringbufHeaderSize + internal.Align(len(data), 8))
+	}
+
+	rd := newRingReader(&cons, &prod, ring)
+
+	var rec RingBufRecord
+	if err := readRecord(rd, &rec, header); err != errEOR {
+		t.Fatal("Expected errEOR on empty ring, got", err)
+	}
+
+	putRecord(ringbufDiscardBit, []byte{1, 2, 3})
+	putRecord(0, []byte{4, 5, 6, 7, 8})
+	putRecord(ringbufBusyBit, []byte{9})
+
+	rd.loadConsumer()
+	if err := readRecord(rd, &rec, header); err != errDiscard {
+		t.Fatal("Expected errDiscard, got", err)
+	}
+	if cons != 16 {
+		t.Fatal("Expected consumer position to skip discarded record, got", cons)
+	}
+
+	rd.loadConsumer()
+	if err := readRecord(rd, &rec, header); err != nil {
+		t.Fatal("Can't read record:", err)
+	}
+	if want := []byte{4, 5, 6, 7, 8}; !bytes.Equal(rec.RawSample, want) {
+		t.Errorf("Expected %v, got %v", want, rec.RawSample)
+	}
+
+	rd.loadConsumer()
+	if err := readRecord(rd, &rec, header); err != errBusy {
+		t.Fatal("Expected errBusy, got", err)
+	}
+	if cons != 32 {
+		t.Fatal("Busy record shouldn't advance the consumer position, got", cons)
+	}
+}
@@ -0,0 +1,495 @@
+package ebpf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/cilium/ebpf/internal"
+)
+
+// snapshotVersion is the version of the encoding of a MapSnapshot.
+const snapshotVersion = 2
+
+// MapSnapshot is a copy of the contents of a Map at a point in time.
+//
+// Use MarshalBinary and UnmarshalBinary to store or transmit a
+// snapshot. The encoding is msgpack, see MarshalBinary.
+//
+// Keys and values are stored as is, which means that they are in the byte
+// order of the host the snapshot was taken on. A snapshot can only be
+// restored on a host with the same byte order.
+type MapSnapshot struct {
+	Type      MapType
+	KeySize   uint32
+	ValueSize uint32
+	// ByteOrder of the host the snapshot was taken on.
+	ByteOrder binary.ByteOrder
+	Entries   []MapSnapshotEntry
+}
+
+// MapSnapshotEntry is a key-value pair in a MapSnapshot.
+type MapSnapshotEntry struct {
+	Key []byte
+	// Value of the entry. For per-CPU maps it contains the values of all
+	// possible CPUs one after the other, each ValueSize bytes long.
+	Value []byte
+}
+
+// Snapshot captures all key-value pairs of the map.
+//
+// The map may be modified concurrently, in which case the snapshot
+// doesn't reflect a consistent state. Maps which hold file descriptors,
+// like ProgramArray or HashOfMaps, can't be captured.
+func (m *Map) Snapshot() (*MapSnapshot, error) {
+	if err := m.canSnapshot(); err != nil {
+		return nil, fmt.Errorf("snapshot: %w", err)
+	}
+
+	snap := &MapSnapshot{
+		Type:      m.typ,
+		KeySize:   m.keySize,
+		ValueSize: m.valueSize,
+		ByteOrder: internal.NativeEndian,
+	}
+
+	var (
+		key      []byte
+		iter     = m.Iterate()
+		perCPU   = m.typ.hasPerCPUValue()
+		value    []byte
+		perValue [][]byte
+	)
+
+	for {
+		var ok bool
+		if perCPU {
+			ok = iter.Next(&key, &perValue)
+		} else {
+			ok = iter.Next(&key, &value)
+		}
+		if !ok {
+			break
+		}
+
+		if perCPU {
+			value = bytes.Join(perValue, nil)
+		}
+
+		snap.Entries = append(snap.Entries, MapSnapshotEntry{
+			Key:   append([]byte(nil), key...),
+			Value: append([]byte(nil), value...),
+		})
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("snapshot: %w", err)
+	}
+
+	return snap, nil
+}
+
+// Restore updates the map with the entries of a snapshot.
+//
+// Entries which exist in the map but not in the snapshot are left
+// untouched. The snapshot must have been taken from a map with the same
+// type, key and value size, on a host with the same byte order.
+func (m *Map) Restore(snap *MapSnapshot) error {
+	if err := m.canSnapshot(); err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+
+	if snap.ByteOrder != nil && snap.ByteOrder != internal.NativeEndian {
+		return fmt.Errorf("restore: snapshot is %s, host is %s", snap.ByteOrder, internal.NativeEndian)
+	}
+
+	if snap.Type != m.typ || snap.KeySize != m.keySize || snap.ValueSize != m.valueSize {
+		return fmt.Errorf("restore: snapshot of %s(keySize=%d, valueSize=%d) doesn't match %s",
+			snap.Type, snap.KeySize, snap.ValueSize, m)
+	}
+
+	for _, entry := range snap.Entries {
+		var value interface{} = entry.Value
+		if m.typ.hasPerCPUValue() {
+			var err error
+			value, err = splitPerCPUValue(entry.Value, int(m.valueSize))
+			if err != nil {
+				return fmt.Errorf("restore: key %x: %w", entry.Key, err)
+			}
+		}
+
+		if err := m.Put(entry.Key, value); err != nil {
+			return fmt.Errorf("restore: key %x: %w", entry.Key, err)
+		}
+	}
+
+	return nil
+}
+
+func (m *Map) canSnapshot() error {
+	switch {
+	case m.typ.canStoreMap(), m.typ.canStoreProgram():
+		return fmt.Errorf("%s holds file descriptors", m.typ)
+	case m.typ == PerfEventArray, m.typ == RingBuf:
+		return fmt.Errorf("%s can't be iterated", m.typ)
+	}
+	return nil
+}
+
+func splitPerCPUValue(buf []byte, elemLength int) ([][]byte, error) {
+	if elemLength == 0 || len(buf)%elemLength != 0 {
+		return nil, fmt.Errorf("per-CPU value of %d bytes isn't a multiple of %d", len(buf), elemLength)
+	}
+
+	values := make([][]byte, 0, len(buf)/elemLength)
+	for len(buf) > 0 {
+		values = append(values, buf[:elemLength])
+		buf = buf[elemLength:]
+	}
+	return values, nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+//
+// The snapshot is encoded as a msgpack array, so that it can be decoded in
+// other languages:
+//
+//     [
+//         version     uint, currently 2
+//         byte order  str, "little" or "big"
+//         type        uint
+//         key size    uint
+//         value size  uint
+//         entries     array of [key bin, value bin]
+//     ]
+func (snap *MapSnapshot) MarshalBinary() ([]byte, error) {
+	var order string
+	switch snap.ByteOrder {
+	case binary.LittleEndian:
+		order = "little"
+	case binary.BigEndian:
+		order = "big"
+	default:
+		return nil, fmt.Errorf("unsupported byte order %v", snap.ByteOrder)
+	}
+
+	var enc msgpackEncoder
+	enc.array(6)
+	enc.uint(snapshotVersion)
+	enc.str(order)
+	enc.uint(uint64(snap.Type))
+	enc.uint(uint64(snap.KeySize))
+	enc.uint(uint64(snap.ValueSize))
+
+	enc.array(len(snap.Entries))
+	for i, entry := range snap.Entries {
+		if len(entry.Key) != int(snap.KeySize) {
+			return nil, fmt.Errorf("entry %d: key has %d bytes instead of %d", i, len(entry.Key), snap.KeySize)
+		}
+
+		enc.array(2)
+		enc.bin(entry.Key)
+		enc.bin(entry.Value)
+	}
+
+	return enc.buf, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (snap *MapSnapshot) UnmarshalBinary(data []byte) error {
+	dec := msgpackDecoder{data}
+
+	if n, err := dec.array(); err != nil {
+		return fmt.Errorf("read header: %w", err)
+	} else if n != 6 {
+		return fmt.Errorf("header has %d fields instead of 6", n)
+	}
+
+	version, err := dec.uint()
+	if err != nil {
+		return fmt.Errorf("read version: %w", err)
+	}
+	if version != snapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d", version)
+	}
+
+	order, err := dec.str()
+	if err != nil {
+		return fmt.Errorf("read byte order: %w", err)
+	}
+
+	var byteOrder binary.ByteOrder
+	switch order {
+	case "little":
+		byteOrder = binary.LittleEndian
+	case "big":
+		byteOrder = binary.BigEndian
+	default:
+		return fmt.Errorf("unsupported byte order %q", order)
+	}
+
+	var header [3]uint32
+	for i := range header {
+		v, err := dec.uint()
+		if err != nil {
+			return fmt.Errorf("read header: %w", err)
+		}
+		if v > math.MaxUint32 {
+			return fmt.Errorf("header field %d overflows uint32", i)
+		}
+		header[i] = uint32(v)
+	}
+
+	count, err := dec.array()
+	if err != nil {
+		return fmt.Errorf("read entries: %w", err)
+	}
+
+	keySize := header[1]
+	var entries []MapSnapshotEntry
+	for i := 0; i < count; i++ {
+		if n, err := dec.array(); err != nil {
+			return fmt.Errorf("entry %d: %w", i, err)
+		} else if n != 2 {
+			return fmt.Errorf("entry %d: has %d fields instead of 2", i, n)
+		}
+
+		key, err := dec.bin()
+		if err != nil {
+			return fmt.Errorf("entry %d: read key: %w", i, err)
+		}
+		if len(key) != int(keySize) {
+			return fmt.Errorf("entry %d: key has %d bytes instead of %d", i, len(key), keySize)
+		}
+
+		value, err := dec.bin()
+		if err != nil {
+			return fmt.Errorf("entry %d: read value: %w", i, err)
+		}
+
+		entries = append(entries, MapSnapshotEntry{key, value})
+	}
+
+	if len(dec.buf) != 0 {
+		return fmt.Errorf("%d trailing bytes", len(dec.buf))
+	}
+
+	*snap = MapSnapshot{
+		Type:      MapType(header[0]),
+		KeySize:   keySize,
+		ValueSize: header[2],
+		ByteOrder: byteOrder,
+		Entries:   entries,
+	}
+	return nil
+}
+
+// msgpackEncoder writes the subset of msgpack used by MapSnapshot.
+type msgpackEncoder struct {
+	buf []byte
+}
+
+func (enc *msgpackEncoder) array(n int) {
+	switch {
+	case n < 16:
+		enc.buf = append(enc.buf, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		enc.buf = append(enc.buf, 0xdc)
+		enc.buf = appendUint(enc.buf, uint64(n), 2)
+	default:
+		enc.buf = append(enc.buf, 0xdd)
+		enc.buf = appendUint(enc.buf, uint64(n), 4)
+	}
+}
+
+func (enc *msgpackEncoder) uint(v uint64) {
+	switch {
+	case v < 128:
+		enc.buf = append(enc.buf, byte(v))
+	case v <= math.MaxUint8:
+		enc.buf = append(enc.buf, 0xcc, byte(v))
+	case v <= math.MaxUint16:
+		enc.buf = append(enc.buf, 0xcd)
+		enc.buf = appendUint(enc.buf, v, 2)
+	case v <= math.MaxUint32:
+		enc.buf = append(enc.buf, 0xce)
+		enc.buf = appendUint(enc.buf, v, 4)
+	default:
+		enc.buf = append(enc.buf, 0xcf)
+		enc.buf = appendUint(enc.buf, v, 8)
+	}
+}
+
+// str encodes a string shorter than 32 bytes.
+func (enc *msgpackEncoder) str(s string) {
+	enc.buf = append(enc.buf, 0xa0|byte(len(s)))
+	enc.buf = append(enc.buf, s...)
+}
+
+func (enc *msgpackEncoder) bin(b []byte) {
+	switch {
+	case len(b) <= math.MaxUint8:
+		enc.buf = append(enc.buf, 0xc4, byte(len(b)))
+	case len(b) <= math.MaxUint16:
+		enc.buf = append(enc.buf, 0xc5)
+		enc.buf = appendUint(enc.buf, uint64(len(b)), 2)
+	default:
+		enc.buf = append(enc.buf, 0xc6)
+		enc.buf = appendUint(enc.buf, uint64(len(b)), 4)
+	}
+	enc.buf = append(enc.buf, b...)
+}
+
+// appendUint appends the n least significant bytes of v in big endian.
+func appendUint(buf []byte, v uint64, n int) []byte {
+	for i := n - 1; i >= 0; i-- {
+		buf = append(buf, byte(v>>(8*i)))
+	}
+	return buf
+}
+
+// msgpackDecoder reads the subset of msgpack used by MapSnapshot.
+//
+// Integers and lengths may use any of the encodings msgpack allows, not
+// just the shortest one.
+type msgpackDecoder struct {
+	buf []byte
+}
+
+var errMsgpackTruncated = errors.New("unexpected end of data")
+
+func (dec *msgpackDecoder) next(n int) ([]byte, error) {
+	if n < 0 || n > len(dec.buf) {
+		return nil, errMsgpackTruncated
+	}
+	b := dec.buf[:n]
+	dec.buf = dec.buf[n:]
+	return b, nil
+}
+
+func (dec *msgpackDecoder) readUint(n int) (uint64, error) {
+	b, err := dec.next(n)
+	if err != nil {
+		return 0, err
+	}
+
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v, nil
+}
+
+func (dec *msgpackDecoder) tag() (byte, error) {
+	b, err := dec.next(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+func (dec *msgpackDecoder) array() (int, error) {
+	tag, err := dec.tag()
+	if err != nil {
+		return 0, err
+	}
+
+	var n uint64
+	switch {
+	case tag&0xf0 == 0x90:
+		return int(tag & 0x0f), nil
+	case tag == 0xdc:
+		n, err = dec.readUint(2)
+	case tag == 0xdd:
+		n, err = dec.readUint(4)
+	default:
+		return 0, fmt.Errorf("expected array, got type %#x", tag)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	// Every element takes at least one byte.
+	if n > uint64(len(dec.buf)) {
+		return 0, errMsgpackTruncated
+	}
+	return int(n), nil
+}
+
+func (dec *msgpackDecoder) uint() (uint64, error) {
+	tag, err := dec.tag()
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case tag < 0x80:
+		return uint64(tag), nil
+	case tag == 0xcc:
+		return dec.readUint(1)
+	case tag == 0xcd:
+		return dec.readUint(2)
+	case tag == 0xce:
+		return dec.readUint(4)
+	case tag == 0xcf:
+		return dec.readUint(8)
+	default:
+		return 0, fmt.Errorf("expected unsigned integer, got type %#x", tag)
+	}
+}
+
+func (dec *msgpackDecoder) str() (string, error) {
+	tag, err := dec.tag()
+	if err != nil {
+		return "", err
+	}
+
+	var n uint64
+	switch {
+	case tag&0xe0 == 0xa0:
+		n = uint64(tag & 0x1f)
+	case tag == 0xd9:
+		n, err = dec.readUint(1)
+	case tag == 0xda:
+		n, err = dec.readUint(2)
+	case tag == 0xdb:
+		n, err = dec.readUint(4)
+	default:
+		return "", fmt.Errorf("expected string, got type %#x", tag)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	b, err := dec.next(int(n))
+	return string(b), err
+}
+
+func (dec *msgpackDecoder) bin() ([]byte, error) {
+	tag, err := dec.tag()
+	if err != nil {
+		return nil, err
+	}
+
+	var n uint64
+	switch tag {
+	case 0xc4:
+		n, err = dec.readUint(1)
+	case 0xc5:
+		n, err = dec.readUint(2)
+	case 0xc6:
+		n, err = dec.readUint(4)
+	default:
+		return nil, fmt.Errorf("expected binary, got type %#x", tag)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := dec.next(int(n))
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), b...), nil
+}
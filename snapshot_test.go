@@ -0,0 +1,236 @@
+package ebpf
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/cilium/ebpf/internal"
+	qt "github.com/frankban/quicktest"
+)
+
+func TestMapSnapshot(t *testing.T) {
+	for _, typ := range []MapType{Hash, Array, PerCPUHash} {
+		t.Run(typ.String(), func(t *testing.T) {
+			spec := &MapSpec{
+				Type:       typ,
+				KeySize:    4,
+				ValueSize:  4,
+				MaxEntries: 2,
+			}
+
+			m, err := NewMap(spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer m.Close()
+
+			put := func(m *Map, key, value uint32) {
+				t.Helper()
+
+				var err error
+				if typ.hasPerCPUValue() {
+					err = m.Put(key, []uint32{value})
+				} else {
+					err = m.Put(key, value)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			put(m, 0, 42)
+			put(m, 1, 4242)
+
+			snap, err := m.Snapshot()
+			if err != nil {
+				t.Fatal("Can't take snapshot:", err)
+			}
+			if len(snap.Entries) != 2 {
+				t.Fatal("Expected 2 entries, got", len(snap.Entries))
+			}
+
+			buf, err := snap.MarshalBinary()
+			if err != nil {
+				t.Fatal("Can't marshal snapshot:", err)
+			}
+
+			var decoded MapSnapshot
+			if err := decoded.UnmarshalBinary(buf); err != nil {
+				t.Fatal("Can't unmarshal snapshot:", err)
+			}
+			qt.Assert(t, &decoded, qt.DeepEquals, snap)
+
+			restored, err := NewMap(spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer restored.Close()
+
+			if err := restored.Restore(&decoded); err != nil {
+				t.Fatal("Can't restore snapshot:", err)
+			}
+
+			for key, want := range map[uint32]uint32{0: 42, 1: 4242} {
+				var got uint32
+				if typ.hasPerCPUValue() {
+					var values []uint32
+					err = restored.Lookup(key, &values)
+					got = values[0]
+				} else {
+					err = restored.Lookup(key, &got)
+				}
+				if err != nil {
+					t.Fatal("Can't look up key", key, err)
+				}
+				if got != want {
+					t.Errorf("Expected %d for key %d, got %d", want, key, got)
+				}
+			}
+		})
+	}
+}
+
+func TestMapSnapshotIncompatible(t *testing.T) {
+	m, err := NewMap(&MapSpec{
+		Type:       Hash,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	snap := &MapSnapshot{Type: Hash, KeySize: 4, ValueSize: 8}
+	if err := m.Restore(snap); err == nil {
+		t.Error("Restore accepts snapshot with different value size")
+	}
+
+	progs, err := NewMap(&MapSpec{
+		Type:       ProgramArray,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer progs.Close()
+
+	if _, err := progs.Snapshot(); err == nil {
+		t.Error("Snapshot accepts ProgramArray")
+	}
+}
+
+func TestMapSnapshotUnmarshalInvalid(t *testing.T) {
+	snap := &MapSnapshot{
+		Type:      Hash,
+		KeySize:   4,
+		ValueSize: 4,
+		ByteOrder: binary.LittleEndian,
+		Entries: []MapSnapshotEntry{
+			{Key: []byte{1, 2, 3, 4}, Value: []byte{5, 6, 7, 8}},
+		},
+	}
+
+	buf, err := snap.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < len(buf); i++ {
+		var decoded MapSnapshot
+		if err := decoded.UnmarshalBinary(buf[:i]); err == nil {
+			t.Errorf("Truncated snapshot of %d bytes doesn't return an error", i)
+		}
+	}
+
+	var decoded MapSnapshot
+	if err := decoded.UnmarshalBinary(append(buf, 0)); err == nil {
+		t.Error("Snapshot with trailing data doesn't return an error")
+	}
+
+	// The key size is the fifth byte.
+	wrongKeySize := append([]byte(nil), buf...)
+	wrongKeySize[4] = 8
+	if err := decoded.UnmarshalBinary(wrongKeySize); err == nil {
+		t.Error("Snapshot with a key size not matching the keys doesn't return an error")
+	}
+}
+
+func TestMapSnapshotEncoding(t *testing.T) {
+	snap := &MapSnapshot{
+		Type:      Hash,
+		KeySize:   1,
+		ValueSize: 2,
+		ByteOrder: binary.LittleEndian,
+		Entries: []MapSnapshotEntry{
+			{Key: []byte{1}, Value: []byte{2, 3}},
+		},
+	}
+
+	buf, err := snap.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []byte{
+		// Array of 6: version, byte order, type, key size, value size
+		0x96, 0x02, 0xa6, 'l', 'i', 't', 't', 'l', 'e', 0x01, 0x01, 0x02,
+		// Array of 1 entry: [key, value]
+		0x91, 0x92, 0xc4, 0x01, 0x01, 0xc4, 0x02, 0x02, 0x03,
+	}
+	qt.Assert(t, buf, qt.DeepEquals, want)
+
+	// Other encoders may not use the shortest encoding for integers.
+	long := []byte{
+		0x96,
+		0xce, 0x00, 0x00, 0x00, 0x02,
+		0xa3, 'b', 'i', 'g',
+		0xcc, 0x01,
+		0xcd, 0x00, 0x01,
+		0xcf, 0, 0, 0, 0, 0, 0, 0, 0x02,
+		0xdc, 0x00, 0x01,
+		0x92,
+		0xc5, 0x00, 0x01, 0x01,
+		0xc6, 0x00, 0x00, 0x00, 0x02, 0x02, 0x03,
+	}
+
+	var decoded MapSnapshot
+	if err := decoded.UnmarshalBinary(long); err != nil {
+		t.Fatal("Can't decode snapshot with long integers:", err)
+	}
+	snap.ByteOrder = binary.BigEndian
+	qt.Assert(t, &decoded, qt.DeepEquals, snap)
+}
+
+func TestMapSnapshotByteOrder(t *testing.T) {
+	m, err := NewMap(&MapSpec{
+		Type:       Hash,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	snap, err := m.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snap.ByteOrder != internal.NativeEndian {
+		t.Errorf("Snapshot has byte order %s, expected %s", snap.ByteOrder, internal.NativeEndian)
+	}
+
+	if internal.NativeEndian == binary.LittleEndian {
+		snap.ByteOrder = binary.BigEndian
+	} else {
+		snap.ByteOrder = binary.LittleEndian
+	}
+	if err := m.Restore(snap); err == nil {
+		t.Error("Restore accepts snapshot with foreign byte order")
+	}
+}
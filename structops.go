@@ -0,0 +1,285 @@
+package ebpf
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cilium/ebpf/btf"
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/unix"
+)
+
+// structOpsValuePrefix is prepended to the name of a kernel struct to
+// find the type of the corresponding struct_ops map value.
+const structOpsValuePrefix = "bpf_struct_ops_"
+
+// StructOpsSpec describes a kernel struct, like tcp_congestion_ops, which
+// is implemented by BPF programs.
+//
+// It is defined by a variable in the .struct_ops section of an ELF.
+type StructOpsSpec struct {
+	// Name of the variable defining the struct.
+	Name string
+	// TypeName is the name of the struct in the kernel.
+	TypeName string
+	// Type is the struct as defined in the ELF. Its layout may differ from
+	// the kernel, members are matched by name.
+	Type *btf.Struct
+	// Data is the initial value of the struct, laid out according to Type.
+	Data []byte
+	// Programs maps members of the struct to the name of the program
+	// implementing them.
+	Programs map[string]string
+}
+
+// Copy returns a copy of the spec.
+func (sos *StructOpsSpec) Copy() *StructOpsSpec {
+	if sos == nil {
+		return nil
+	}
+
+	cpy := *sos
+	cpy.Data = make([]byte, len(sos.Data))
+	copy(cpy.Data, sos.Data)
+
+	cpy.Programs = make(map[string]string, len(sos.Programs))
+	for member, prog := range sos.Programs {
+		cpy.Programs[member] = prog
+	}
+
+	return &cpy
+}
+
+// NewStructOpsMap creates a struct_ops map from a spec and registers it
+// with the kernel.
+//
+// progs must contain the programs named in spec.Programs, keyed by name.
+// Usually this is Collection.Programs. The programs must be loaded with
+// AttachTo set to "<struct>:<member>", which LoadCollectionSpec does
+// automatically.
+//
+// The struct is unregistered once the map is closed and no other
+// references to it exist.
+//
+// Requires at least Linux 5.6 and kernel BTF.
+func NewStructOpsMap(spec *StructOpsSpec, progs map[string]*Program) (*Map, error) {
+	if err := haveStructOps(); err != nil {
+		return nil, err
+	}
+
+	kernel, err := btf.LoadKernelSpec()
+	if err != nil {
+		return nil, fmt.Errorf("struct_ops %s: load kernel spec: %w", spec.Name, err)
+	}
+
+	progFD := func(name string) (int, error) {
+		prog := progs[name]
+		if prog == nil {
+			return 0, fmt.Errorf("missing program %s", name)
+		}
+		return prog.FD(), nil
+	}
+
+	valueType, value, err := spec.kernelValue(kernel, progFD)
+	if err != nil {
+		return nil, fmt.Errorf("struct_ops %s: %w", spec.Name, err)
+	}
+
+	attr := internal.BPFMapCreateAttr{
+		MapType:               uint32(StructOpsMap),
+		KeySize:               4,
+		ValueSize:             valueType.Size,
+		MaxEntries:            1,
+		BTFVmlinuxValueTypeID: uint32(valueType.ID()),
+	}
+
+	if haveObjName() == nil {
+		attr.MapName = internal.NewBPFObjName(spec.Name)
+	}
+
+	fd, err := internal.BPFMapCreate(&attr)
+	if err != nil {
+		if errors.Is(err, unix.EPERM) {
+			return nil, fmt.Errorf("struct_ops %s: map create: RLIMIT_MEMLOCK may be too low: %w", spec.Name, err)
+		}
+		return nil, fmt.Errorf("struct_ops %s: map create: %w", spec.Name, err)
+	}
+
+	m, err := newMap(fd, spec.Name, StructOpsMap, attr.KeySize, attr.ValueSize, attr.MaxEntries, attr.Flags)
+	if err != nil {
+		fd.Close()
+		return nil, fmt.Errorf("struct_ops %s: %w", spec.Name, err)
+	}
+
+	// Updating the only element registers the struct.
+	if err := m.Update(uint32(0), value, UpdateAny); err != nil {
+		m.Close()
+		return nil, fmt.Errorf("struct_ops %s: register: %w", spec.Name, err)
+	}
+
+	return m, nil
+}
+
+// kernelValue converts Data into the layout of the struct_ops map value
+// found in kernel BTF, and fills in the fds of the programs.
+//
+// Returns the type of the map value and its contents.
+func (sos *StructOpsSpec) kernelValue(kernel *btf.Spec, progFD func(string) (int, error)) (*btf.Struct, []byte, error) {
+	if sos.Type == nil {
+		return nil, nil, errors.New("missing type")
+	}
+
+	if len(sos.Data) != int(sos.Type.Size) {
+		return nil, nil, fmt.Errorf("data has %d bytes, type has %d", len(sos.Data), sos.Type.Size)
+	}
+
+	kernType, err := findStructOpsType(kernel, sos.TypeName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	valueType := new(btf.Struct)
+	if err := kernel.FindType(structOpsValuePrefix+sos.TypeName, valueType); err != nil {
+		return nil, nil, fmt.Errorf("find struct_ops value: %w", err)
+	}
+
+	// The kernel struct is embedded in the map value in a member called data.
+	var dataOffset = -1
+	for _, member := range valueType.Members {
+		if member.Name == "data" {
+			dataOffset = int(member.Offset / 8)
+			break
+		}
+	}
+	if dataOffset < 0 {
+		return nil, nil, fmt.Errorf("%s has no data member", valueType)
+	}
+
+	kernMembers := make(map[string]btf.Member, len(kernType.Members))
+	for _, member := range kernType.Members {
+		kernMembers[string(member.Name)] = member
+	}
+
+	for name := range sos.Programs {
+		if _, ok := kernMembers[name]; !ok {
+			return nil, nil, fmt.Errorf("member %s isn't supported by the kernel: %w", name, ErrNotSupported)
+		}
+	}
+
+	value := make([]byte, valueType.Size)
+	for _, member := range sos.Type.Members {
+		name := string(member.Name)
+		if member.BitfieldSize > 0 || member.Offset%8 != 0 {
+			return nil, nil, fmt.Errorf("member %s: bitfields are not supported: %w", name, ErrNotSupported)
+		}
+
+		size, err := btf.Sizeof(member.Type)
+		if err != nil {
+			return nil, nil, fmt.Errorf("member %s: %w", name, err)
+		}
+
+		offset := int(member.Offset / 8)
+		if offset+size > len(sos.Data) {
+			return nil, nil, fmt.Errorf("member %s is out of bounds", name)
+		}
+		data := sos.Data[offset : offset+size]
+
+		kernMember, ok := kernMembers[name]
+		if !ok {
+			if !isZero(data) {
+				return nil, nil, fmt.Errorf("member %s isn't supported by the kernel: %w", name, ErrNotSupported)
+			}
+			continue
+		}
+
+		kernSize, err := btf.Sizeof(kernMember.Type)
+		if err != nil {
+			return nil, nil, fmt.Errorf("member %s: %w", name, err)
+		}
+
+		kernOffset := dataOffset + int(kernMember.Offset/8)
+		if kernMember.BitfieldSize > 0 || kernMember.Offset%8 != 0 || kernOffset+kernSize > len(value) {
+			return nil, nil, fmt.Errorf("member %s: unsupported layout in the kernel: %w", name, ErrNotSupported)
+		}
+		kernData := value[kernOffset : kernOffset+kernSize]
+
+		if progName, ok := sos.Programs[name]; ok {
+			if kernSize != 8 {
+				return nil, nil, fmt.Errorf("member %s: kernel member isn't a function pointer", name)
+			}
+
+			fd, err := progFD(progName)
+			if err != nil {
+				return nil, nil, fmt.Errorf("member %s: %w", name, err)
+			}
+
+			internal.NativeEndian.PutUint64(kernData, uint64(fd))
+			continue
+		}
+
+		if size != kernSize {
+			return nil, nil, fmt.Errorf("member %s: size %d doesn't match kernel size %d", name, size, kernSize)
+		}
+		copy(kernData, data)
+	}
+
+	return valueType, value, nil
+}
+
+// resolveStructOpsMember finds the kernel struct and the index of the
+// member implemented by a struct_ops program.
+//
+// attachTo must be of the form "<struct>:<member>".
+func resolveStructOpsMember(kernel *btf.Spec, attachTo string) (*btf.Struct, int, error) {
+	parts := strings.SplitN(attachTo, ":", 2)
+	if len(parts) != 2 {
+		return nil, 0, fmt.Errorf("struct_ops: AttachTo %q isn't of the form <struct>:<member>", attachTo)
+	}
+
+	if kernel == nil {
+		var err error
+		kernel, err = btf.LoadKernelSpec()
+		if err != nil {
+			return nil, 0, fmt.Errorf("load kernel spec: %w", err)
+		}
+	}
+
+	typ, err := findStructOpsType(kernel, parts[0])
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for i, member := range typ.Members {
+		if string(member.Name) == parts[1] {
+			return typ, i, nil
+		}
+	}
+
+	return nil, 0, &internal.UnsupportedFeatureError{
+		Name: fmt.Sprintf("struct_ops %s", attachTo),
+	}
+}
+
+func findStructOpsType(kernel *btf.Spec, name string) (*btf.Struct, error) {
+	typ := new(btf.Struct)
+	err := kernel.FindType(name, typ)
+	if errors.Is(err, btf.ErrNotFound) {
+		return nil, &internal.UnsupportedFeatureError{
+			Name: fmt.Sprintf("struct_ops %s", name),
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find struct_ops %s: %w", name, err)
+	}
+	return typ, nil
+}
+
+func isZero(buf []byte) bool {
+	for _, b := range buf {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
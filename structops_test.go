@@ -0,0 +1,171 @@
+package ebpf
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/cilium/ebpf/btf"
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/testutils"
+)
+
+// structOpsKernelSpec returns BTF resembling the kernel definition of
+// tcp_congestion_ops.
+func structOpsKernelSpec(tb testing.TB) *btf.Spec {
+	tb.Helper()
+
+	u32 := &btf.Int{Name: "u32", Size: 4}
+	ptr := &btf.Pointer{Target: (*btf.Void)(nil)}
+
+	kernType := &btf.Struct{
+		Name: "tcp_congestion_ops",
+		Size: 24,
+		Members: []btf.Member{
+			{Name: "flags", Type: u32, Offset: 0},
+			{Name: "ssthresh", Type: ptr, Offset: 64},
+			{Name: "cong_avoid", Type: ptr, Offset: 128},
+		},
+	}
+
+	valueType := &btf.Struct{
+		Name: structOpsValuePrefix + "tcp_congestion_ops",
+		Size: 32,
+		Members: []btf.Member{
+			{Name: "refcnt", Type: u32, Offset: 0},
+			{Name: "data", Type: kernType, Offset: 64},
+		},
+	}
+
+	m, err := btf.NewMapFromTypes(kernType, valueType)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return btf.MapSpec(m)
+}
+
+func TestStructOpsKernelValue(t *testing.T) {
+	kernel := structOpsKernelSpec(t)
+
+	u32 := &btf.Int{Name: "u32", Size: 4}
+	spec := &StructOpsSpec{
+		Name:     "my_ops",
+		TypeName: "tcp_congestion_ops",
+		Type: &btf.Struct{
+			Name: "tcp_congestion_ops",
+			Size: 16,
+			Members: []btf.Member{
+				{Name: "ssthresh", Type: &btf.Pointer{Target: (*btf.Void)(nil)}, Offset: 0},
+				{Name: "flags", Type: u32, Offset: 64},
+				// Not present in the kernel.
+				{Name: "extra", Type: u32, Offset: 96},
+			},
+		},
+		Data:     make([]byte, 16),
+		Programs: map[string]string{"ssthresh": "my_ssthresh"},
+	}
+	internal.NativeEndian.PutUint32(spec.Data[8:], 3)
+
+	progFD := func(name string) (int, error) {
+		if name != "my_ssthresh" {
+			t.Fatal("Unexpected program", name)
+		}
+		return 42, nil
+	}
+
+	valueType, value, err := spec.kernelValue(kernel, progFD)
+	if err != nil {
+		t.Fatal("Can't convert value:", err)
+	}
+
+	if valueType.Name != structOpsValuePrefix+"tcp_congestion_ops" {
+		t.Error("Unexpected value type", valueType)
+	}
+
+	want := make([]byte, 32)
+	internal.NativeEndian.PutUint32(want[8:], 3)
+	internal.NativeEndian.PutUint64(want[16:], 42)
+	if !bytes.Equal(value, want) {
+		t.Errorf("Expected value %v, got %v", want, value)
+	}
+
+	extra := spec.Copy()
+	internal.NativeEndian.PutUint32(extra.Data[12:], 1)
+	if _, _, err := extra.kernelValue(kernel, progFD); !errors.Is(err, ErrNotSupported) {
+		t.Error("Expected ErrNotSupported for member missing in the kernel, got", err)
+	}
+
+	missing := spec.Copy()
+	missing.Programs["extra"] = "my_ssthresh"
+	if _, _, err := missing.kernelValue(kernel, progFD); !errors.Is(err, ErrNotSupported) {
+		t.Error("Expected ErrNotSupported for program missing in the kernel, got", err)
+	}
+
+	unknown := spec.Copy()
+	unknown.TypeName = "sched_ext_ops"
+	if _, _, err := unknown.kernelValue(kernel, progFD); !errors.Is(err, ErrNotSupported) {
+		t.Error("Expected ErrNotSupported for unknown struct, got", err)
+	}
+}
+
+func TestResolveStructOpsMember(t *testing.T) {
+	kernel := structOpsKernelSpec(t)
+
+	typ, member, err := resolveStructOpsMember(kernel, "tcp_congestion_ops:cong_avoid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ.Name != "tcp_congestion_ops" {
+		t.Error("Unexpected type", typ)
+	}
+	if member != 2 {
+		t.Error("Expected member index 2, got", member)
+	}
+
+	if _, _, err := resolveStructOpsMember(kernel, "tcp_congestion_ops:foo"); !errors.Is(err, ErrNotSupported) {
+		t.Error("Expected ErrNotSupported for missing member, got", err)
+	}
+
+	if _, _, err := resolveStructOpsMember(kernel, "tcp_congestion_ops"); err == nil {
+		t.Error("Accepted AttachTo without member")
+	}
+}
+
+func TestNewStructOpsMap(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.6", "struct_ops maps")
+
+	// struct_ops programs are resolved against vmlinux BTF.
+	if _, err := btf.LoadKernelSpec(); err != nil {
+		t.Skip("Can't load kernel BTF:", err)
+	}
+
+	testutils.Files(t, testutils.Glob(t, "testdata/struct_ops-*.elf"), func(t *testing.T, file string) {
+		spec, err := LoadCollectionSpec(file)
+		if err != nil {
+			t.Fatal("Can't parse ELF:", err)
+		}
+
+		if spec.ByteOrder != internal.NativeEndian {
+			return
+		}
+
+		coll, err := NewCollection(spec)
+		testutils.SkipIfNotSupported(t, err)
+		if err != nil {
+			t.Fatal("Can't create collection:", err)
+		}
+		defer coll.Close()
+
+		m, err := NewStructOpsMap(spec.StructOps["my_ops"], coll.Programs)
+		testutils.SkipIfNotSupported(t, err)
+		if err != nil {
+			t.Fatal("Can't create struct_ops map:", err)
+		}
+		defer m.Close()
+
+		if m.Type() != StructOpsMap {
+			t.Error("Expected type StructOpsMap, got", m.Type())
+		}
+	})
+}
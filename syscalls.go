@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"sync"
 	"unsafe"
 
 	"github.com/cilium/ebpf/asm"
@@ -120,6 +121,12 @@ type bpfProgTestRunAttr struct {
 	dataOut     internal.Pointer
 	repeat      uint32
 	duration    uint32
+	ctxSizeIn   uint32
+	ctxSizeOut  uint32
+	ctxIn       internal.Pointer
+	ctxOut      internal.Pointer
+	flags       uint32
+	cpu         uint32
 }
 
 type bpfMapFreezeAttr struct {
@@ -204,6 +211,113 @@ var haveInnerMaps = internal.FeatureTest("inner maps", "5.10", func() error {
 	return nil
 })
 
+var haveStructOps = internal.FeatureTest("struct_ops maps", "5.6", func() error {
+	_, err := internal.BPFMapCreate(&internal.BPFMapCreateAttr{
+		MapType:    uint32(StructOpsMap),
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+		// Invalid type ID.
+		BTFVmlinuxValueTypeID: ^uint32(0),
+	})
+	if errors.Is(err, unix.EINVAL) {
+		return internal.ErrNotSupported
+	}
+	if errors.Is(err, unix.ENOTSUPP) {
+		return nil
+	}
+	return err
+})
+
+var progTypeTests = struct {
+	sync.Mutex
+	tests map[ProgramType]func() error
+}{tests: make(map[ProgramType]func() error)}
+
+// haveProgType probes whether the kernel supports a program type by
+// loading a minimal program.
+//
+// Returns nil for types which can't be probed, since they require BTF.
+func haveProgType(typ ProgramType) error {
+	progTypeTests.Lock()
+	defer progTypeTests.Unlock()
+
+	if test, ok := progTypeTests.tests[typ]; ok {
+		return test()
+	}
+
+	required, ok := minimumKernelVersions[typ]
+	if !ok {
+		return nil
+	}
+
+	version := fmt.Sprintf("%d.%d.%d", required[0], required[1], required[2])
+	test := internal.FeatureTest(fmt.Sprintf("%s program", typ), version, func() error {
+		return probeProgType(typ)
+	})
+	progTypeTests.tests[typ] = test
+	return test()
+}
+
+func probeProgType(typ ProgramType) error {
+	var (
+		attachType AttachType
+		flags      uint32
+	)
+
+	switch typ {
+	case Tracing, StructOps, Extension, LSM:
+		return nil
+	case CGroupSockAddr:
+		attachType = AttachCGroupInet4Connect
+	case CGroupSockopt:
+		attachType = AttachCGroupGetsockopt
+	case SkLookup:
+		attachType = AttachSkLookup
+	case Syscall:
+		flags = unix.BPF_F_SLEEPABLE
+	case Netfilter:
+		attachType = AttachNetfilter
+	}
+
+	insns := asm.Instructions{
+		asm.LoadImm(asm.R0, 0, asm.DWord),
+		asm.Return(),
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, len(insns)*asm.InstructionSize))
+	if err := insns.Marshal(buf, internal.NativeEndian); err != nil {
+		return err
+	}
+	bytecode := buf.Bytes()
+
+	// Kernels before 5.0 require the version of the running kernel for
+	// kprobes.
+	v, err := internal.KernelVersion()
+	if err != nil {
+		return err
+	}
+
+	fd, err := internal.BPFProgLoad(&internal.BPFProgLoadAttr{
+		ProgType:           uint32(typ),
+		Instructions:       internal.NewSlicePointer(bytecode),
+		InsCount:           uint32(len(bytecode) / asm.InstructionSize),
+		ExpectedAttachType: uint32(attachType),
+		ProgFlags:          flags,
+		License:            internal.NewStringPointer("GPL"),
+		KernelVersion:      v.Kernel(),
+	})
+	// E2BIG means the kernel doesn't know about some of the attributes,
+	// and is therefore too old for the type.
+	if errors.Is(err, unix.EINVAL) || errors.Is(err, unix.E2BIG) {
+		return internal.ErrNotSupported
+	}
+	if err != nil {
+		return err
+	}
+	_ = fd.Close()
+	return nil
+}
+
 func bpfMapLookupElem(m *internal.FD, key, valueOut internal.Pointer) error {
 	fd, err := m.Value()
 	if err != nil {
@@ -347,17 +461,13 @@ func bpfMapFreeze(m *internal.FD) error {
 	return err
 }
 
-func bpfGetProgInfoByFD(fd *internal.FD, ids []MapID) (*bpfProgInfo, error) {
-	var info bpfProgInfo
-	if len(ids) > 0 {
-		info.nr_map_ids = uint32(len(ids))
-		info.map_ids = internal.NewPointer(unsafe.Pointer(&ids[0]))
+// bpfGetProgInfoByFD populates info. Variable length fields are only
+// retrieved if info contains a buffer for them.
+func bpfGetProgInfoByFD(fd *internal.FD, info *bpfProgInfo) error {
+	if err := internal.BPFObjGetInfoByFD(fd, unsafe.Pointer(info), unsafe.Sizeof(*info)); err != nil {
+		return fmt.Errorf("can't get program info: %w", err)
 	}
-
-	if err := internal.BPFObjGetInfoByFD(fd, unsafe.Pointer(&info), unsafe.Sizeof(info)); err != nil {
-		return nil, fmt.Errorf("can't get program info: %w", err)
-	}
-	return &info, nil
+	return nil
 }
 
 func bpfGetMapInfoByFD(fd *internal.FD) (*bpfMapInfo, error) {
@@ -409,31 +519,7 @@ var objNameAllowsDot = internal.FeatureTest("dot in object names", "5.2", func()
 	return nil
 })
 
-var haveBatchAPI = internal.FeatureTest("map batch api", "5.6", func() error {
-	var maxEntries uint32 = 2
-	attr := internal.BPFMapCreateAttr{
-		MapType:    uint32(Hash),
-		KeySize:    4,
-		ValueSize:  4,
-		MaxEntries: maxEntries,
-	}
-
-	fd, err := internal.BPFMapCreate(&attr)
-	if err != nil {
-		return internal.ErrNotSupported
-	}
-	defer fd.Close()
-	keys := []uint32{1, 2}
-	values := []uint32{3, 4}
-	kp, _ := marshalPtr(keys, 8)
-	vp, _ := marshalPtr(values, 8)
-	nilPtr := internal.NewPointer(nil)
-	_, err = bpfMapBatch(internal.BPF_MAP_UPDATE_BATCH, fd, nilPtr, nilPtr, kp, vp, maxEntries, nil)
-	if err != nil {
-		return internal.ErrNotSupported
-	}
-	return nil
-})
+var haveBatchAPI = internal.HaveBatchAPI
 
 var haveProbeReadKernel = internal.FeatureTest("bpf_probe_read_kernel", "5.5", func() error {
 	insns := asm.Instructions{
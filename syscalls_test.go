@@ -51,6 +51,19 @@ func TestHaveInnerMaps(t *testing.T) {
 	testutils.CheckFeatureTest(t, haveInnerMaps)
 }
 
+func TestHaveStructOps(t *testing.T) {
+	testutils.CheckFeatureTest(t, haveStructOps)
+}
+
 func TestHaveProbeReadKernel(t *testing.T) {
 	testutils.CheckFeatureTest(t, haveProbeReadKernel)
 }
+
+func TestHaveProgType(t *testing.T) {
+	for _, typ := range []ProgramType{SocketFilter, Kprobe, XDP, CGroupSockopt, SkLookup, Syscall} {
+		typ := typ
+		t.Run(typ.String(), func(t *testing.T) {
+			testutils.CheckFeatureTest(t, func() error { return haveProgType(typ) })
+		})
+	}
+}
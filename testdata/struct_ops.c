@@ -0,0 +1,35 @@
+/* This file excercises the ELF loader for struct_ops. */
+
+#include "common.h"
+
+char __license[] __section("license") = "GPL";
+
+/* A subset of struct tcp_congestion_ops. Members are matched to the kernel
+ * definition by name, so the layout doesn't have to be the same.
+ */
+struct tcp_congestion_ops {
+	uint32_t flags;
+	uint32_t (*ssthresh)(void *sk);
+	void (*cong_avoid)(void *sk, uint32_t ack, uint32_t acked);
+	uint32_t (*undo_cwnd)(void *sk);
+	char name[16];
+};
+
+__section("struct_ops/my_ssthresh") uint32_t my_ssthresh(void *sk) {
+	return 2;
+}
+
+__section("struct_ops/my_cong_avoid") void my_cong_avoid(void *sk, uint32_t ack, uint32_t acked) {
+}
+
+__section("struct_ops/my_undo_cwnd") uint32_t my_undo_cwnd(void *sk) {
+	return 10;
+}
+
+__section(".struct_ops") struct tcp_congestion_ops my_ops = {
+	.flags      = 1,
+	.ssthresh   = (void *)my_ssthresh,
+	.cong_avoid = (void *)my_cong_avoid,
+	.undo_cwnd  = (void *)my_undo_cwnd,
+	.name       = "ebpf_test",
+};
@@ -0,0 +1,25 @@
+/* This file is compiled for the host instead of BPF. It's used to test
+ * resolving the call sites of inlined functions from DWARF. */
+
+volatile int sink;
+
+static inline __attribute__((always_inline)) void inlined_twice(int a) {
+	sink = a * 3;
+}
+
+static inline __attribute__((always_inline)) void inlined_once(int a) {
+	sink = a + 7;
+}
+
+__attribute__((noinline)) void caller(int a) {
+	inlined_twice(a);
+	sink = 0;
+	inlined_twice(a + 1);
+	inlined_once(a);
+}
+
+void _start(void) {
+	caller(sink);
+	for (;;)
+		;
+}
@@ -27,6 +27,10 @@ const (
 	// programs.  Thus, both the key_size and value_size must be
 	// exactly four bytes.  This map is used in conjunction with the
 	// TailCall helper.
+	//
+	// Map.Put accepts a *Program as value, and Map.Lookup can unmarshal
+	// into a **Program. The file descriptors are translated automatically.
+	// See also ProgramArrayMap.
 	ProgramArray
 	// PerfEventArray - A perf event array is used in conjunction with PerfEventRead
 	// and PerfEventOutput calls, to read the raw bpf_perf_data from the registers.
@@ -34,12 +38,16 @@ const (
 	// PerCPUHash - This data structure is useful for people who have high performance
 	// network needs and can reconcile adds at the end of some cycle, so that
 	// hashes can be lock free without the use of XAdd, which can be costly.
+	//
+	// See also PerCPUMap.
 	PerCPUHash
 	// PerCPUArray - This data structure is useful for people who have high performance
 	// network needs and can reconcile adds at the end of some cycle, so that
 	// hashes can be lock free without the use of XAdd, which can be costly.
 	// Each CPU gets a copy of this hash, the contents of all of which can be reconciled
 	// later.
+	//
+	// See also PerCPUMap.
 	PerCPUArray
 	// StackTrace - This holds whole user and kernel stack traces, it can be retrieved with
 	// GetStackID
@@ -83,8 +91,14 @@ const (
 	// PerCPUCGroupStorage - Special per CPU map for CGroups.
 	PerCPUCGroupStorage
 	// Queue - FIFO storage for BPF programs.
+	//
+	// Queues have no keys: push with Map.Put(nil, value), peek with
+	// Map.Lookup(nil, &value) and pop with Map.LookupAndDelete(nil, &value).
+	// See also QueueMap.
 	Queue
 	// Stack - LIFO storage for BPF programs.
+	//
+	// Stacks support the same operations as Queue. See also StackMap.
 	Stack
 	// SkStorage - Specialized map for local storage at SK for BPF programs.
 	SkStorage
@@ -99,6 +113,14 @@ const (
 	InodeStorage
 	// TaskStorage - Specialized local storage map for task_struct.
 	TaskStorage
+	// BloomFilter - Space efficient set which may return false positives.
+	// Values can be added but not removed, and the key size must be zero.
+	// See BloomFilterMap.
+	BloomFilter
+	// UserRingbuf - Similar to RingBuf, but written by user space and read by BPF programs.
+	UserRingbuf
+	// CgrpStorage - Specialized local storage map for cgroups.
+	CgrpStorage
 	// maxMapType - Bound enum of MapTypes, has to be last in enum.
 	maxMapType
 )
@@ -114,6 +136,29 @@ func (mt MapType) hasPerCPUValue() bool {
 	return mt == PerCPUHash || mt == PerCPUArray || mt == LRUCPUHash || mt == PerCPUCGroupStorage
 }
 
+// canBatchLookup returns true if the map type implements
+// BPF_MAP_LOOKUP_BATCH.
+func (mt MapType) canBatchLookup() bool {
+	switch mt {
+	case Hash, Array, PerCPUHash, PerCPUArray, LRUHash, LRUCPUHash:
+		return true
+	default:
+		return false
+	}
+}
+
+// hasFixedKeys returns true if all keys below MaxEntries exist, even if
+// no value has been stored for them.
+func (mt MapType) hasFixedKeys() bool {
+	switch mt {
+	case Array, PerCPUArray, ProgramArray, PerfEventArray, CGroupArray,
+		ArrayOfMaps, DevMap, CPUMap, XSKMap, ReusePortSockArray:
+		return true
+	default:
+		return false
+	}
+}
+
 // canStoreMap returns true if the map type accepts a map fd
 // for update and returns a map id for lookup.
 func (mt MapType) canStoreMap() bool {
@@ -167,6 +212,8 @@ const (
 	Extension
 	LSM
 	SkLookup
+	Syscall
+	Netfilter
 	maxProgramType
 )
 
@@ -217,6 +264,17 @@ const (
 	AttachXDPCPUMap
 	AttachSkLookup
 	AttachXDP
+	AttachSkSKBVerdict
+	AttachSkReuseportSelect
+	AttachSkReuseportSelectOrMigrate
+	AttachPerfEvent
+	AttachTraceKprobeMulti
+	AttachLSMCgroup
+	AttachStructOps
+	AttachNetfilter
+	AttachTCXIngress
+	AttachTCXEgress
+	AttachTraceUprobeMulti
 )
 
 // AttachFlags of the eBPF program used in BPF_PROG_ATTACH command
@@ -0,0 +1,162 @@
+package ebpf
+
+import "testing"
+
+func TestMapTypeABI(t *testing.T) {
+	// Values of enum bpf_map_type in include/uapi/linux/bpf.h.
+	abi := map[MapType]uint32{
+		UnspecifiedMap:      0,
+		Hash:                1,
+		Array:               2,
+		ProgramArray:        3,
+		PerfEventArray:      4,
+		PerCPUHash:          5,
+		PerCPUArray:         6,
+		StackTrace:          7,
+		CGroupArray:         8,
+		LRUHash:             9,
+		LRUCPUHash:          10,
+		LPMTrie:             11,
+		ArrayOfMaps:         12,
+		HashOfMaps:          13,
+		DevMap:              14,
+		SockMap:             15,
+		CPUMap:              16,
+		XSKMap:              17,
+		SockHash:            18,
+		CGroupStorage:       19,
+		ReusePortSockArray:  20,
+		PerCPUCGroupStorage: 21,
+		Queue:               22,
+		Stack:               23,
+		SkStorage:           24,
+		DevMapHash:          25,
+		StructOpsMap:        26,
+		RingBuf:             27,
+		InodeStorage:        28,
+		TaskStorage:         29,
+		BloomFilter:         30,
+		UserRingbuf:         31,
+		CgrpStorage:         32,
+	}
+
+	for mt, want := range abi {
+		if uint32(mt) != want {
+			t.Errorf("%s should be %d, got %d", mt, want, uint32(mt))
+		}
+	}
+
+	if got := len(abi); got != int(MapType(0).Max())+1 {
+		t.Errorf("Table has %d entries, but there are %d map types", got, MapType(0).Max()+1)
+	}
+}
+
+func TestProgramTypeABI(t *testing.T) {
+	// Values of enum bpf_prog_type in include/uapi/linux/bpf.h.
+	abi := map[ProgramType]uint32{
+		UnspecifiedProgram:    0,
+		SocketFilter:          1,
+		Kprobe:                2,
+		SchedCLS:              3,
+		SchedACT:              4,
+		TracePoint:            5,
+		XDP:                   6,
+		PerfEvent:             7,
+		CGroupSKB:             8,
+		CGroupSock:            9,
+		LWTIn:                 10,
+		LWTOut:                11,
+		LWTXmit:               12,
+		SockOps:               13,
+		SkSKB:                 14,
+		CGroupDevice:          15,
+		SkMsg:                 16,
+		RawTracepoint:         17,
+		CGroupSockAddr:        18,
+		LWTSeg6Local:          19,
+		LircMode2:             20,
+		SkReuseport:           21,
+		FlowDissector:         22,
+		CGroupSysctl:          23,
+		RawTracepointWritable: 24,
+		CGroupSockopt:         25,
+		Tracing:               26,
+		StructOps:             27,
+		Extension:             28,
+		LSM:                   29,
+		SkLookup:              30,
+		Syscall:               31,
+		Netfilter:             32,
+	}
+
+	for pt, want := range abi {
+		if uint32(pt) != want {
+			t.Errorf("%s should be %d, got %d", pt, want, uint32(pt))
+		}
+	}
+
+	if got := len(abi); got != int(ProgramType(0).Max())+1 {
+		t.Errorf("Table has %d entries, but there are %d program types", got, ProgramType(0).Max()+1)
+	}
+}
+
+func TestAttachTypeABI(t *testing.T) {
+	// Values of enum bpf_attach_type in include/uapi/linux/bpf.h.
+	abi := map[AttachType]uint32{
+		AttachCGroupInetIngress:          0,
+		AttachCGroupInetEgress:           1,
+		AttachCGroupInetSockCreate:       2,
+		AttachCGroupSockOps:              3,
+		AttachSkSKBStreamParser:          4,
+		AttachSkSKBStreamVerdict:         5,
+		AttachCGroupDevice:               6,
+		AttachSkMsgVerdict:               7,
+		AttachCGroupInet4Bind:            8,
+		AttachCGroupInet6Bind:            9,
+		AttachCGroupInet4Connect:         10,
+		AttachCGroupInet6Connect:         11,
+		AttachCGroupInet4PostBind:        12,
+		AttachCGroupInet6PostBind:        13,
+		AttachCGroupUDP4Sendmsg:          14,
+		AttachCGroupUDP6Sendmsg:          15,
+		AttachLircMode2:                  16,
+		AttachFlowDissector:              17,
+		AttachCGroupSysctl:               18,
+		AttachCGroupUDP4Recvmsg:          19,
+		AttachCGroupUDP6Recvmsg:          20,
+		AttachCGroupGetsockopt:           21,
+		AttachCGroupSetsockopt:           22,
+		AttachTraceRawTp:                 23,
+		AttachTraceFEntry:                24,
+		AttachTraceFExit:                 25,
+		AttachModifyReturn:               26,
+		AttachLSMMac:                     27,
+		AttachTraceIter:                  28,
+		AttachCgroupInet4GetPeername:     29,
+		AttachCgroupInet6GetPeername:     30,
+		AttachCgroupInet4GetSockname:     31,
+		AttachCgroupInet6GetSockname:     32,
+		AttachXDPDevMap:                  33,
+		AttachCgroupInetSockRelease:      34,
+		AttachXDPCPUMap:                  35,
+		AttachSkLookup:                   36,
+		AttachXDP:                        37,
+		AttachSkSKBVerdict:               38,
+		AttachSkReuseportSelect:          39,
+		AttachSkReuseportSelectOrMigrate: 40,
+		AttachPerfEvent:                  41,
+		AttachTraceKprobeMulti:           42,
+		AttachLSMCgroup:                  43,
+		AttachStructOps:                  44,
+		AttachNetfilter:                  45,
+		AttachTCXIngress:                 46,
+		AttachTCXEgress:                  47,
+		AttachTraceUprobeMulti:           48,
+	}
+
+	for at, want := range abi {
+		if uint32(at) != want {
+			t.Errorf("%s should be %d, got %d", at, want, uint32(at))
+		}
+	}
+}